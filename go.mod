@@ -1,11 +1,13 @@
 module github.com/Mynor2397/social-network
 
-go 1.14
+go 1.16
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/gorilla/handlers v1.4.2
 	github.com/hako/branca v0.0.0-20191227164554-3b9970524189
 	github.com/matryer/way v0.0.0-20180416093233-9632d0c407b0
 	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/config"
+	"github.com/Mynor2397/social-network/src/keyring"
+)
+
+//defaultKeyID es el id con el que entra token.key al keyring.
+const defaultKeyID = "default"
+
+const keysUsage = `uso: %s [banderas] keys <comando> [id]
+
+comandos:
+  list          muestra las llaves y cuál está activa
+  generate      agrega una llave nueva que solo verifica tokens
+  activate id   usa la llave id para firmar los tokens nuevos
+  rotate        agrega una llave nueva y la activa
+  retire id     quita una llave que ya no está activa
+
+Los comandos trabajan sobre el archivo token.keyring; si no existe se crea
+con token.key como llave %q. Los servidores recargan el archivo con SIGHUP.
+
+Para rotar sin cortar sesiones con varios servidores: generate, recargar
+todos, activate, recargar todos y, pasado token.lifespan, retire de la llave
+anterior. Con un solo servidor basta rotate.
+`
+
+//runKeys ejecuta el subcomando keys y devuelve el código de salida.
+func runKeys(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), keysUsage, os.Args[0], defaultKeyID)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd, withID := fs.Arg(0), cmdTakesID(fs.Arg(0))
+	if fs.NArg() == 0 || (withID && fs.NArg() != 2) || (!withID && fs.NArg() != 1) {
+		fs.Usage()
+		return 2
+	}
+
+	if cfg.Token.Keyring == "" {
+		fmt.Fprintln(os.Stderr, "defina token.keyring con la ruta del archivo de llaves")
+		return 2
+	}
+
+	f, err := keyring.Load(cfg.Token.Keyring)
+	if os.IsNotExist(err) {
+		f, err = legacyKeys(cfg)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	now := time.Now()
+	switch cmd {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREADA\tACTIVA")
+		for _, k := range f.Keys {
+			active := ""
+			if k.ID == f.Active {
+				active = "sí"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", k.ID, k.CreatedAt.Format("2006-01-02 15:04:05"), active)
+		}
+		w.Flush()
+		return 0
+	case "generate", "rotate":
+		var k keyring.Key
+		if k, err = keyring.GenerateKey(now); err != nil {
+			break
+		}
+		f.Keys = append(f.Keys, k)
+		if cmd == "rotate" {
+			f.Active = k.ID
+		}
+		fmt.Println(k.ID)
+	case "activate":
+		if _, ok := f.Key(fs.Arg(1)); !ok {
+			err = fmt.Errorf("no existe la llave %s", fs.Arg(1))
+			break
+		}
+		f.Active = fs.Arg(1)
+	case "retire":
+		id := fs.Arg(1)
+		if id == f.Active {
+			err = fmt.Errorf("no se puede quitar la llave activa %s", id)
+			break
+		}
+		keys := f.Keys[:0]
+		for _, k := range f.Keys {
+			if k.ID != id {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == len(f.Keys) {
+			err = fmt.Errorf("no existe la llave %s", id)
+			break
+		}
+		f.Keys = keys
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	if err == nil {
+		err = f.Save(cfg.Token.Keyring)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func cmdTakesID(cmd string) bool {
+	return cmd == "activate" || cmd == "retire"
+}
+
+//tokenKeys devuelve las llaves con las que arranca el servidor: las del
+//keyring o, si no hay, token.key.
+func tokenKeys(cfg config.Config) (keyring.File, error) {
+	if cfg.Token.Keyring == "" {
+		return legacyKeys(cfg)
+	}
+
+	return keyring.Load(cfg.Token.Keyring)
+}
+
+func legacyKeys(cfg config.Config) (keyring.File, error) {
+	f := keyring.File{
+		Active: defaultKeyID,
+		Keys:   []keyring.Key{{ID: defaultKeyID, Secret: cfg.Token.Key, CreatedAt: time.Now().UTC()}},
+	}
+
+	return f, f.Validate()
+}
+
+//reloadKeyringOnHangup vuelve a leer token.keyring cada vez que llega SIGHUP.
+//Si el archivo no es válido se siguen usando las llaves anteriores.
+func reloadKeyringOnHangup(cfg config.Config, k *keyring.Keyring) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		f, err := keyring.Load(cfg.Token.Keyring)
+		if err == nil {
+			err = k.Replace(f)
+		}
+
+		if err != nil {
+			log.Printf("no se pudo recargar el keyring: %v", err)
+			continue
+		}
+
+		log.Printf("keyring recargado, llave activa %s", f.Active)
+	}
+}
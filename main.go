@@ -1,45 +1,179 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/gorilla/handlers"
-	"github.com/hako/branca"
 
-	"github.com/Mynor2397/social-network/src/handler"
+	"github.com/Mynor2397/social-network/src/blob"
+	"github.com/Mynor2397/social-network/src/config"
+	handler "github.com/Mynor2397/social-network/src/handlers"
+	"github.com/Mynor2397/social-network/src/keyring"
+	"github.com/Mynor2397/social-network/src/mail"
+	"github.com/Mynor2397/social-network/src/memory"
 	"github.com/Mynor2397/social-network/src/mysql"
+	"github.com/Mynor2397/social-network/src/oidc"
+	"github.com/Mynor2397/social-network/src/password"
 	"github.com/Mynor2397/social-network/src/service"
 )
 
-var port = ":4545"
-
 func main() {
-	//Configuracion del archivo log
-	logfile, err := os.OpenFile("test.log", os.O_RDWR|os.O_APPEND, 0666)
+	//Carga y validación de la configuración
+	cfg, args, err := config.Load(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
 	if err != nil {
 		log.Fatalln(err.Error())
 	}
-	defer logfile.Close()
-	log.SetOutput(logfile)
 
-	//Configuración del token
-	codec := branca.NewBranca("supersecretkeyyoushouldnotcommit")
-	codec.SetTTL(uint32(service.TokenLifespan.Seconds()))
+	//Subcomandos
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrate(cfg, args[1:]))
+		case "keys":
+			os.Exit(runKeys(cfg, args[1:]))
+		case "unlock":
+			os.Exit(runUnlock(cfg, args[1:]))
+		case "roles":
+			os.Exit(runRoles(cfg, args[1:]))
+		case "oidc-fake":
+			os.Exit(runOIDCFake(cfg, args[1:]))
+		default:
+			log.Fatalf("subcomando desconocido: %s", args[0])
+		}
+	}
+
+	//Configuracion del archivo log
+	if cfg.Log.File != "" {
+		logfile, err := os.OpenFile(cfg.Log.File, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		defer logfile.Close()
+		log.SetOutput(logfile)
+	}
+
+	//Configuración de las llaves de los tokens
+	keys, err := tokenKeys(cfg)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	codec, err := keyring.New(keys, cfg.Token.Lifespan)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if cfg.Token.Keyring != "" {
+		go reloadKeyringOnHangup(cfg, codec)
+	}
 
 	//Configuración de las instancias del servicio
-	db := mysql.Connect()
-	s := service.New(db, codec)
-	h := handler.New(s)
+	var store service.Store = memory.New()
+	if cfg.Storage == "mysql" {
+		db, err := mysql.Connect(cfg.Database)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		store = mysql.NewStore(db)
+	}
+	var mailer service.Mailer = &mail.LogMailer{From: cfg.Mail.From}
+	if cfg.Mail.Driver == "file" {
+		mailer = &mail.FileMailer{From: cfg.Mail.From, Dir: cfg.Mail.Dir}
+	}
+
+	//Configuración de los proveedores OpenID Connect
+	var providers []service.IdentityProvider
+	if cfg.OIDC.Providers != "" {
+		pp, err := oidc.Load(cfg.OIDC.Providers)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		for _, p := range pp {
+			providers = append(providers, oidc.New(p))
+		}
+	}
+
+	//Configuración del hash de las contraseñas
+	hasher := password.Hasher{
+		Algorithm:     cfg.Password.Hash,
+		BcryptCost:    cfg.Password.BcryptCost,
+		Argon2Time:    uint32(cfg.Password.Argon2Time),
+		Argon2Memory:  uint32(cfg.Password.Argon2Memory),
+		Argon2Threads: uint8(cfg.Password.Argon2Threads),
+		Pepper:        cfg.Password.Pepper,
+	}
+
+	//Lista de contraseñas filtradas
+	var breached service.BreachedPasswords
+	if cfg.Password.BreachedList != "" {
+		l, err := password.LoadBreachList(cfg.Password.BreachedList)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Printf("%d contraseñas filtradas cargadas", l.Len())
+		breached = l
+	}
+
+	//Almacenamiento de las imágenes de perfil
+	blobs := &blob.Local{Dir: cfg.Media.Dir, BaseURL: cfg.Media.BaseURL}
+
+	//Almacenamiento privado de las exportaciones de datos
+	exports := &blob.Local{Dir: cfg.Export.Dir}
+
+	s := service.New(store, codec, mailer, service.Config{
+		TokenLifespan:            cfg.Token.Lifespan,
+		RefreshTokenLifespan:     cfg.Token.RefreshLifespan,
+		PasswordResetLifespan:    cfg.Password.ResetLifespan,
+		PasswordResetMaxRequests: cfg.Password.ResetMaxRequests,
+		PasswordResetWindow:      cfg.Password.ResetWindow,
+		PasswordHasher:           hasher,
+		PasswordMinLength:        cfg.Password.MinLength,
+		PasswordMinEntropy:       cfg.Password.MinEntropy,
+		BreachedPasswords:        breached,
+		UnverifiedRestrictions:   cfg.Verify.Restrict,
+		TwoFactorIssuer:          cfg.TwoFA.Issuer,
+		LoginMaxFailures:         cfg.Login.MaxFailures,
+		LoginIPMaxFailures:       cfg.Login.IPMaxFailures,
+		LoginLockout:             cfg.Login.Lockout,
+		LoginMaxLockout:          cfg.Login.MaxLockout,
+		LoginFailureWindow:       cfg.Login.FailureWindow,
+		IdentityProviders:        providers,
+		UsernameChangeCooldown:   cfg.Username.ChangeCooldown,
+		UsernameReservation:      cfg.Username.Reservation,
+		Blobs:                    blobs,
+		MaxImageSize:             cfg.Media.MaxImageSize,
+		DeletionGracePeriod:      cfg.Deletion.GracePeriod,
+		Exports:                  exports,
+		DataExportLifespan:       cfg.Export.Lifespan,
+	})
+	go purge(s, cfg.Deletion.PurgeInterval)
+	go processDataExports(s, cfg.Export.PollInterval)
 
-	fmt.Printf("Starting server on port %s", port)
+	mux := http.NewServeMux()
+	mux.Handle("/", handler.New(s))
+	if strings.HasPrefix(cfg.Media.BaseURL, "/") {
+		mux.Handle(strings.TrimSuffix(cfg.Media.BaseURL, "/")+"/", blobs.Handler())
+	}
+
+	fmt.Printf("Starting server on port %s", cfg.Server.Addr)
 	//Configuracion de los encabezados para peticiones cruzadas
 	headersOk := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
-	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "HEAD", "OPTIONS"})
-	originsOk := handlers.AllowedOrigins([]string{"*"})
-	if err := http.ListenAndServe(port, handlers.CORS(headersOk, methodsOk, originsOk)(h)); err != nil {
+	methodsOk := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"})
+	originsOk := handlers.AllowedOrigins(cfg.Server.AllowedOrigins)
+	server := &http.Server{
+		Addr:         cfg.Server.Addr,
+		Handler:      handlers.CORS(headersOk, methodsOk, originsOk)(mux),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	if err := server.ListenAndServe(); err != nil {
 		log.Fatalf("No se pudo iniciar el servidor: %v", err)
 	}
 }
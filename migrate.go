@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Mynor2397/social-network/src/config"
+	"github.com/Mynor2397/social-network/src/migrate"
+	"github.com/Mynor2397/social-network/src/mysql"
+)
+
+const migrateUsage = `uso: %s [banderas] migrate [-dry-run] <comando> [n]
+
+comandos:
+  up [n]     aplica n migraciones pendientes, todas si se omite n
+  down [n]   revierte las últimas n migraciones, una si se omite n
+  status     muestra las migraciones y cuándo se aplicaron
+
+La base de datos db.name debe existir antes de migrar.
+`
+
+//runMigrate ejecuta el subcomando migrate y devuelve el código de salida.
+func runMigrate(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "muestra el SQL sin ejecutarlo")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), migrateUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() == 0 || fs.NArg() > 2 {
+		fs.Usage()
+		return 2
+	}
+
+	n := 0
+	if fs.NArg() == 2 {
+		var err error
+		if n, err = strconv.Atoi(fs.Arg(1)); err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "n debe ser un entero positivo: %s\n", fs.Arg(1))
+			return 2
+		}
+	}
+
+	db, err := mysql.OpenForMigrations(cfg.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	m.DryRun = *dryRun
+
+	ctx := context.Background()
+	switch fs.Arg(0) {
+	case "up":
+		err = m.Up(ctx, n)
+	case "down":
+		err = m.Down(ctx, n)
+	case "status":
+		var ss []migrate.Status
+		if ss, err = m.Status(ctx); err == nil {
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "VERSION\tNOMBRE\tAPLICADA")
+			for _, s := range ss {
+				applied := "pendiente"
+				if s.AppliedAt != nil {
+					applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+				}
+				fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+			}
+			w.Flush()
+		}
+	default:
+		fs.Usage()
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Mynor2397/social-network/src/config"
+	"github.com/Mynor2397/social-network/src/oidc"
+)
+
+const oidcFakeUsage = `uso: %s [banderas] oidc-fake [-addr addr] [-email email]
+
+Levanta un proveedor OpenID Connect falso para probar el login en local.
+Aprueba todos los logins sin preguntar, con el email de login_hint o el de
+-email. Se configura como el proveedor fake de oidc.example.yaml.
+`
+
+//runOIDCFake ejecuta el subcomando oidc-fake y devuelve el código de salida.
+func runOIDCFake(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("oidc-fake", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:9999", "dirección en la que escucha")
+	clientID := fs.String("client_id", "network", "client_id que acepta")
+	clientSecret := fs.String("client_secret", "secret", "client_secret que acepta")
+	email := fs.String("email", "fake@network.local", "email de los logins sin login_hint")
+	unverified := fs.Bool("unverified", false, "devolver el email como no verificado")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), oidcFakeUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+
+	f, err := oidc.NewFake("http://"+*addr, *clientID, *clientSecret, *email)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	f.EmailVerified = !*unverified
+
+	fmt.Printf("proveedor falso en %s\n", f.Issuer)
+	if err = http.ListenAndServe(*addr, f); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
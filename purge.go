@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//purge borra cada interval las cuentas cuyo periodo de gracia terminó, las
+//exportaciones de datos que expiraron y los usernames de cuentas borradas
+//que ya no están reservados.
+func purge(s *service.Service, interval time.Duration) {
+	for range time.Tick(interval) {
+		drain("cuentas borradas", s.PurgeDeletedAccounts)
+		drain("exportaciones expiradas borradas", s.PurgeExpiredDataExports)
+		drain("usernames de cuentas borradas liberados", s.PurgeUsernameReservations)
+	}
+}
+
+//processDataExports genera cada interval las exportaciones de datos
+//pendientes, una tras otra.
+func processDataExports(s *service.Service, interval time.Duration) {
+	for range time.Tick(interval) {
+		for {
+			ok, err := s.ProcessDataExport(context.Background())
+			if err != nil {
+				log.Printf("no se pudo generar una exportación: %v", err)
+				break
+			}
+
+			if !ok {
+				break
+			}
+		}
+	}
+}
+
+//drain llama a fn mientras siga borrando algo.
+func drain(what string, fn func(context.Context) (int, error)) {
+	for {
+		n, err := fn(context.Background())
+		if n > 0 {
+			log.Printf("%d %s", n, what)
+		}
+
+		if err != nil {
+			log.Printf("no se pudo completar la limpieza (%s): %v", what, err)
+			return
+		}
+
+		if n == 0 {
+			return
+		}
+	}
+}
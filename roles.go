@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/config"
+	"github.com/Mynor2397/social-network/src/mysql"
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+const rolesUsage = `uso: %s [banderas] roles <comando> username [rol]
+
+comandos:
+  list username         muestra los roles del usuario
+  grant username rol    le da el rol al usuario
+  revoke username rol   le quita el rol al usuario
+
+Roles: %s. Sirve para nombrar al primer administrador; después los roles
+se cambian con PUT /api/admin/users/:username/roles. Los cambios quedan en
+el registro de auditoría sin actor.
+`
+
+//runRoles ejecuta el subcomando roles y devuelve el código de salida.
+func runRoles(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("roles", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), rolesUsage, os.Args[0],
+			strings.Join([]string{service.RoleAdmin, service.RoleModerator}, ", "))
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cmd := fs.Arg(0)
+	withRole := cmd == "grant" || cmd == "revoke"
+	if (cmd != "list" && !withRole) || (withRole && fs.NArg() != 3) || (!withRole && fs.NArg() != 2) {
+		fs.Usage()
+		return 2
+	}
+
+	if cfg.Storage != "mysql" {
+		fmt.Fprintln(os.Stderr, "roles solo sirve con storage mysql")
+		return 2
+	}
+
+	db, err := mysql.Connect(cfg.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	store := mysql.NewStore(db)
+	uid, err := store.UserIDByUsername(ctx, fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	roles, err := store.UserRoles(ctx, uid)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if cmd == "list" {
+		fmt.Println(strings.Join(roles, "\n"))
+		return 0
+	}
+
+	role := fs.Arg(2)
+	next := []string{}
+	for _, r := range roles {
+		if r != role {
+			next = append(next, r)
+		}
+	}
+	if cmd == "grant" {
+		next = append(next, role)
+	}
+
+	if roles, err = service.NormalizeRoles(next); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err = store.SetUserRoles(ctx, uid, roles); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	err = store.RecordAudit(ctx, service.AuditEntry{
+		Action:    "user.set_roles",
+		TargetID:  uid,
+		Details:   strings.Join(roles, ","),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
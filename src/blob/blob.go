@@ -0,0 +1,118 @@
+//Package blob guarda los archivos de la aplicación, como las imágenes de
+//perfil y las exportaciones de datos.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//ErrInvalidKey cuando la llave no es una ruta relativa limpia.
+var ErrInvalidKey = errors.New("llave de archivo inválida")
+
+//Local guarda los archivos en Dir, cada uno en la ruta de su llave, y los
+//sirve en BaseURL.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+var _ service.BlobStorage = (*Local)(nil)
+
+//Put implementa service.BlobStorage. Escribe en un archivo temporal y lo
+//renombra, así nunca se sirve un archivo a medias.
+func (l *Local) Put(ctx context.Context, key, contentType string, r io.Reader) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return fmt.Errorf("no se pudo crear el directorio: %v", err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err = f.Close(); err != nil {
+		return err
+	}
+
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+//Delete implementa service.BlobStorage.
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+//Open implementa service.BlobStorage.
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(name)
+}
+
+//URL implementa service.BlobStorage.
+func (l *Local) URL(key string) string {
+	return strings.TrimSuffix(l.BaseURL, "/") + "/" + key
+}
+
+//Handler sirve los archivos bajo la ruta de BaseURL, sin listar los
+//directorios.
+func (l *Local) Handler() http.Handler {
+	files := http.FileServer(http.Dir(l.Dir))
+	return http.StripPrefix(strings.TrimSuffix(l.BaseURL, "/"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") || strings.Contains(r.URL.Path, "/.") {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		files.ServeHTTP(w, r)
+	}))
+}
+
+//path devuelve la ruta del archivo de la llave, que no puede salirse de Dir.
+func (l *Local) path(key string) (string, error) {
+	if key == "" || path.Clean(key) != key || path.IsAbs(key) || strings.HasPrefix(key, "..") {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,339 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Config es la configuración tipada de la aplicación.
+//
+//Cada campo se puede definir, de menor a mayor precedencia, con su valor por
+//defecto, el archivo de configuración (YAML o TOML), una variable de entorno
+//y una bandera de línea de comandos. La etiqueta config arma los nombres:
+//el campo "db.user" se lee del archivo como db: {user: ...}, de la variable
+//NETWORK_DB_USER y de la bandera -db.user.
+type Config struct {
+	Storage  string   `config:"storage" usage:"almacenamiento: mysql o memory"`
+	Server   Server   `config:"server"`
+	Database Database `config:"db"`
+	Token    Token    `config:"token"`
+	Mail     Mail     `config:"mail"`
+	Password Password `config:"password"`
+	Verify   Verify   `config:"verification"`
+	TwoFA    TwoFA    `config:"two_factor"`
+	Login    Login    `config:"login"`
+	OIDC     OIDC     `config:"oidc"`
+	Username Username `config:"username"`
+	Media    Media    `config:"media"`
+	Deletion Deletion `config:"account_deletion"`
+	Export   Export   `config:"data_export"`
+	Log      Log      `config:"log"`
+}
+
+//Server configura el servidor HTTP.
+type Server struct {
+	Addr           string        `config:"addr" usage:"dirección en la que escucha el servidor HTTP"`
+	ReadTimeout    time.Duration `config:"read_timeout" usage:"tiempo máximo para leer una petición"`
+	WriteTimeout   time.Duration `config:"write_timeout" usage:"tiempo máximo para escribir una respuesta"`
+	IdleTimeout    time.Duration `config:"idle_timeout" usage:"tiempo máximo de una conexión keep-alive inactiva"`
+	AllowedOrigins []string      `config:"allowed_origins" usage:"orígenes permitidos para CORS, separados por coma"`
+}
+
+//Database configura la conexión a mysql.
+type Database struct {
+	User            string        `config:"user" usage:"usuario de mysql"`
+	Password        string        `config:"password" usage:"contraseña de mysql"`
+	Host            string        `config:"host" usage:"host del servidor mysql"`
+	Port            int           `config:"port" usage:"puerto del servidor mysql"`
+	Name            string        `config:"name" usage:"nombre de la base de datos"`
+	Charset         string        `config:"charset" usage:"juego de caracteres de la conexión"`
+	ParseTime       bool          `config:"parse_time" usage:"convertir DATETIME y TIMESTAMP a time.Time"`
+	Timeout         time.Duration `config:"timeout" usage:"tiempo máximo para abrir una conexión"`
+	ReadTimeout     time.Duration `config:"read_timeout" usage:"tiempo máximo de lectura"`
+	WriteTimeout    time.Duration `config:"write_timeout" usage:"tiempo máximo de escritura"`
+	MaxOpenConns    int           `config:"max_open_conns" usage:"máximo de conexiones abiertas (0 sin límite)"`
+	MaxIdleConns    int           `config:"max_idle_conns" usage:"máximo de conexiones inactivas en el pool"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime" usage:"tiempo máximo de vida de una conexión (0 sin límite)"`
+}
+
+//Token configura la emisión de tokens branca.
+type Token struct {
+	Key             string        `config:"key" usage:"llave de 32 bytes para firmar los tokens si no hay keyring"`
+	Keyring         string        `config:"keyring" usage:"archivo JSON con las llaves de los tokens, ver el subcomando keys"`
+	Lifespan        time.Duration `config:"lifespan" usage:"tiempo de vida de los tokens de acceso"`
+	RefreshLifespan time.Duration `config:"refresh_lifespan" usage:"tiempo de vida de los refresh tokens"`
+}
+
+//Mail configura el envío de correos.
+type Mail struct {
+	Driver string `config:"driver" usage:"cómo se entregan los correos: log o file"`
+	From   string `config:"from" usage:"remitente de los correos"`
+	Dir    string `config:"dir" usage:"directorio donde el driver file guarda los correos"`
+}
+
+//Password configura la recuperación de contraseñas.
+type Password struct {
+	ResetLifespan    time.Duration `config:"reset_lifespan" usage:"tiempo de vida de los tokens de recuperación"`
+	ResetMaxRequests int           `config:"reset_max_requests" usage:"solicitudes de recuperación permitidas por email en reset_window"`
+	ResetWindow      time.Duration `config:"reset_window" usage:"ventana para contar las solicitudes de recuperación"`
+	Hash             string        `config:"hash" usage:"algoritmo de las contraseñas nuevas: argon2id o bcrypt; las viejas se cambian al iniciar sesión"`
+	BcryptCost       int           `config:"bcrypt_cost" usage:"costo de bcrypt"`
+	Argon2Time       int           `config:"argon2_time" usage:"iteraciones de argon2id"`
+	Argon2Memory     int           `config:"argon2_memory" usage:"memoria de argon2id en KiB"`
+	Argon2Threads    int           `config:"argon2_threads" usage:"hilos de argon2id"`
+	MinLength        int           `config:"min_length" usage:"largo mínimo de las contraseñas nuevas"`
+	MinEntropy       int           `config:"min_entropy" usage:"bits de entropía estimada mínimos de las contraseñas nuevas"`
+	BreachedList     string        `config:"breached_list" usage:"archivo de hashes SHA-1 de contraseñas filtradas (HASH:CUENTA por línea), vacío para no revisar"`
+	Pepper           string        `config:"pepper" usage:"secreto que se mezcla con las contraseñas, mejor por variable de entorno; perderlo invalida las contraseñas"`
+}
+
+//Verify configura la verificación de email.
+type Verify struct {
+	Restrict []string `config:"restrict" usage:"acciones prohibidas sin el email verificado: login, follow"`
+}
+
+//TwoFA configura la verificación en dos pasos.
+type TwoFA struct {
+	Issuer string `config:"issuer" usage:"nombre de la cuenta en las aplicaciones de autenticación"`
+}
+
+//Login configura el bloqueo por intentos de login fallidos.
+type Login struct {
+	MaxFailures   int           `config:"max_failures" usage:"intentos fallidos seguidos que bloquean una cuenta"`
+	IPMaxFailures int           `config:"ip_max_failures" usage:"intentos fallidos seguidos que bloquean una IP"`
+	Lockout       time.Duration `config:"lockout" usage:"primer bloqueo, se duplica con cada fallo más"`
+	MaxLockout    time.Duration `config:"max_lockout" usage:"bloqueo máximo"`
+	FailureWindow time.Duration `config:"failure_window" usage:"cuánto se recuerdan los intentos fallidos desde el último"`
+}
+
+//OIDC configura el login con proveedores OpenID Connect.
+type OIDC struct {
+	Providers string `config:"providers" usage:"archivo YAML con los proveedores OpenID Connect, vacío para no usarlos"`
+}
+
+//Username configura los cambios de username.
+type Username struct {
+	ChangeCooldown time.Duration `config:"change_cooldown" usage:"tiempo mínimo entre dos cambios de username"`
+	Reservation    time.Duration `config:"reservation" usage:"cuánto tiempo nadie más puede usar un username que se dejó"`
+}
+
+//Media configura dónde se guardan las imágenes de perfil.
+type Media struct {
+	Dir          string `config:"dir" usage:"directorio donde se guardan las imágenes"`
+	BaseURL      string `config:"base_url" usage:"dirección pública de las imágenes; si es una ruta, como /media, las sirve este servidor"`
+	MaxImageSize int    `config:"max_image_size" usage:"lo más que puede pesar una imagen subida, en bytes"`
+}
+
+//Deletion configura el borrado de cuentas.
+type Deletion struct {
+	GracePeriod   time.Duration `config:"grace_period" usage:"cuánto tarda en borrarse una cuenta; iniciar sesión antes lo cancela"`
+	PurgeInterval time.Duration `config:"purge_interval" usage:"cada cuánto se borran las cuentas cuyo periodo de gracia terminó"`
+}
+
+//Export configura las exportaciones de datos de los usuarios.
+type Export struct {
+	Dir          string        `config:"dir" usage:"directorio privado donde se guardan los ZIP de las exportaciones"`
+	Lifespan     time.Duration `config:"lifespan" usage:"cuánto se guarda una exportación lista antes de borrarla"`
+	PollInterval time.Duration `config:"poll_interval" usage:"cada cuánto se buscan exportaciones pendientes"`
+}
+
+//Log configura la salida del log.
+type Log struct {
+	File string `config:"file" usage:"archivo de log, vacío para escribir en stderr"`
+}
+
+//devTokenKey es la llave de token.key por defecto. Es pública, así que
+//Validate solo la acepta con storage memory.
+const devTokenKey = "supersecretkeyyoushouldnotcommit"
+
+//Default devuelve la configuración con la que arranca la aplicación si no se
+//define nada más. Son los valores que usamos en desarrollo.
+func Default() Config {
+	return Config{
+		Storage: "mysql",
+		Server: Server{
+			Addr:           ":4545",
+			ReadTimeout:    15 * time.Second,
+			WriteTimeout:   15 * time.Second,
+			IdleTimeout:    60 * time.Second,
+			AllowedOrigins: []string{"*"},
+		},
+		Database: Database{
+			User:            "root",
+			Password:        "system",
+			Host:            "localhost",
+			Port:            3306,
+			Name:            "network",
+			Charset:         "utf8mb4",
+			ParseTime:       true,
+			Timeout:         5 * time.Second,
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Token: Token{
+			Key:             devTokenKey,
+			Lifespan:        time.Minute * 15,
+			RefreshLifespan: time.Hour * 24 * 14,
+		},
+		Mail: Mail{
+			Driver: "log",
+			From:   "no-reply@network.local",
+			Dir:    "mail",
+		},
+		Password: Password{
+			ResetLifespan:    time.Hour,
+			ResetMaxRequests: 3,
+			ResetWindow:      time.Hour,
+			Hash:             "argon2id",
+			BcryptCost:       10,
+			Argon2Time:       2,
+			Argon2Memory:     19 * 1024,
+			Argon2Threads:    1,
+			MinLength:        8,
+			MinEntropy:       40,
+		},
+		Verify: Verify{
+			Restrict: []string{"follow"},
+		},
+		TwoFA: TwoFA{
+			Issuer: "Network",
+		},
+		Login: Login{
+			MaxFailures:   5,
+			IPMaxFailures: 20,
+			Lockout:       time.Minute,
+			MaxLockout:    time.Hour,
+			FailureWindow: time.Hour,
+		},
+		Username: Username{
+			ChangeCooldown: time.Hour * 24 * 30,
+			Reservation:    time.Hour * 24 * 90,
+		},
+		Media: Media{
+			Dir:          "media",
+			BaseURL:      "/media",
+			MaxImageSize: 5 << 20,
+		},
+		Deletion: Deletion{
+			GracePeriod:   time.Hour * 24 * 30,
+			PurgeInterval: time.Hour,
+		},
+		Export: Export{
+			Dir:          "exports",
+			Lifespan:     time.Hour * 24 * 7,
+			PollInterval: time.Second * 5,
+		},
+		Log: Log{
+			File: "test.log",
+		},
+	}
+}
+
+//Validate revisa que la configuración se pueda usar y reporta todos los
+//problemas encontrados a la vez.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Storage == "mysql" || c.Storage == "memory", "storage debe ser mysql o memory, se recibió %q", c.Storage)
+
+	check(c.Server.Addr != "", "server.addr es obligatorio")
+	check(c.Server.ReadTimeout >= 0, "server.read_timeout no puede ser negativo")
+	check(c.Server.WriteTimeout >= 0, "server.write_timeout no puede ser negativo")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout no puede ser negativo")
+
+	check(c.Database.User != "", "db.user es obligatorio")
+	check(c.Database.Host != "", "db.host es obligatorio")
+	check(c.Database.Port > 0 && c.Database.Port < 65536, "db.port debe estar entre 1 y 65535, se recibió %d", c.Database.Port)
+	check(c.Database.Name != "", "db.name es obligatorio")
+	check(c.Database.Timeout >= 0, "db.timeout no puede ser negativo")
+	check(c.Database.ReadTimeout >= 0, "db.read_timeout no puede ser negativo")
+	check(c.Database.WriteTimeout >= 0, "db.write_timeout no puede ser negativo")
+	check(c.Database.MaxOpenConns >= 0, "db.max_open_conns no puede ser negativo")
+	check(c.Database.MaxIdleConns >= 0, "db.max_idle_conns no puede ser negativo")
+	check(c.Database.ConnMaxLifetime >= 0, "db.conn_max_lifetime no puede ser negativo")
+	check(c.Storage != "mysql" || c.Database.ParseTime, "db.parse_time debe ser true, el almacenamiento mysql lee las fechas como time.Time")
+
+	check(c.Token.Keyring != "" || len(c.Token.Key) == 32, "token.key debe tener 32 bytes, tiene %d", len(c.Token.Key))
+	check(c.Token.Keyring != "" || c.Token.Key != devTokenKey || c.Storage == "memory", "token.key no puede ser la llave de ejemplo con storage mysql, use otra o un keyring")
+	check(c.Token.Lifespan > 0, "token.lifespan debe ser mayor que cero")
+	check(c.Token.RefreshLifespan > c.Token.Lifespan, "token.refresh_lifespan debe ser mayor que token.lifespan")
+
+	check(c.Mail.Driver == "log" || c.Mail.Driver == "file", "mail.driver debe ser log o file, se recibió %q", c.Mail.Driver)
+	check(c.Mail.Driver != "file" || c.Mail.Dir != "", "mail.dir es obligatorio con el driver file")
+
+	check(c.Password.ResetLifespan > 0, "password.reset_lifespan debe ser mayor que cero")
+	check(c.Password.ResetMaxRequests > 0, "password.reset_max_requests debe ser mayor que cero")
+	check(c.Password.ResetWindow > 0, "password.reset_window debe ser mayor que cero")
+	check(c.Password.Hash == "argon2id" || c.Password.Hash == "bcrypt", "password.hash debe ser argon2id o bcrypt, se recibió %q", c.Password.Hash)
+	check(c.Password.BcryptCost >= 4 && c.Password.BcryptCost <= 31, "password.bcrypt_cost debe estar entre 4 y 31, se recibió %d", c.Password.BcryptCost)
+	check(c.Password.Argon2Time > 0, "password.argon2_time debe ser mayor que cero")
+	check(c.Password.Argon2Threads > 0 && c.Password.Argon2Threads < 256, "password.argon2_threads debe estar entre 1 y 255, se recibió %d", c.Password.Argon2Threads)
+	check(c.Password.Argon2Memory >= 8*c.Password.Argon2Threads, "password.argon2_memory debe ser al menos 8 KiB por hilo")
+	check(c.Password.MinLength > 0, "password.min_length debe ser mayor que cero")
+	check(c.Password.MinEntropy > 0, "password.min_entropy debe ser mayor que cero")
+
+	for _, r := range c.Verify.Restrict {
+		check(r == "login" || r == "follow", "verification.restrict solo acepta login y follow, se recibió %q", r)
+	}
+
+	check(c.TwoFA.Issuer != "" && !strings.Contains(c.TwoFA.Issuer, ":"), "two_factor.issuer es obligatorio y no puede tener dos puntos")
+
+	check(c.Login.MaxFailures > 0, "login.max_failures debe ser mayor que cero")
+	check(c.Login.IPMaxFailures > 0, "login.ip_max_failures debe ser mayor que cero")
+	check(c.Login.Lockout > 0, "login.lockout debe ser mayor que cero")
+	check(c.Login.MaxLockout >= c.Login.Lockout, "login.max_lockout no puede ser menor que login.lockout")
+	check(c.Login.FailureWindow >= c.Login.MaxLockout, "login.failure_window no puede ser menor que login.max_lockout")
+
+	check(c.Username.ChangeCooldown > 0, "username.change_cooldown debe ser mayor que cero")
+	check(c.Username.Reservation > 0, "username.reservation debe ser mayor que cero")
+
+	check(c.Media.Dir != "", "media.dir es obligatorio")
+	check(c.Media.BaseURL != "" && c.Media.BaseURL != "/" && !strings.HasPrefix(c.Media.BaseURL, "/api"), "media.base_url es obligatorio y no puede ser / ni estar bajo /api")
+	check(c.Media.MaxImageSize > 0, "media.max_image_size debe ser mayor que cero")
+
+	check(c.Deletion.GracePeriod > 0, "account_deletion.grace_period debe ser mayor que cero")
+	check(c.Deletion.PurgeInterval > 0, "account_deletion.purge_interval debe ser mayor que cero")
+
+	check(c.Export.Dir != "" && (c.Media.Dir == "" || !overlappingDirs(c.Export.Dir, c.Media.Dir)), "data_export.dir es obligatorio y no puede ser media.dir, estar dentro de él ni contenerlo")
+	check(c.Export.Lifespan > 0, "data_export.lifespan debe ser mayor que cero")
+	check(c.Export.PollInterval > 0, "data_export.poll_interval debe ser mayor que cero")
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return errors.New("configuración inválida:\n  " + strings.Join(problems, "\n  "))
+}
+
+//overlappingDirs dice si a y b son el mismo directorio o uno está dentro del
+//otro.
+func overlappingDirs(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+
+	return insideDir(absA, absB) || insideDir(absB, absA)
+}
+
+//insideDir dice si path es dir o está dentro de él.
+func insideDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
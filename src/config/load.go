@@ -0,0 +1,252 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+//EnvPrefix es el prefijo de las variables de entorno que lee Load.
+const EnvPrefix = "NETWORK_"
+
+//field es un valor configurable de Config identificado por su llave.
+type field struct {
+	key   string
+	usage string
+	value reflect.Value
+}
+
+//Load arma la configuración a partir de los valores por defecto, el archivo
+//indicado con -config (o NETWORK_CONFIG), las variables de entorno y las
+//banderas en args, en ese orden de precedencia. Devuelve los argumentos que
+//no son banderas, por ejemplo un subcomando.
+func Load(name string, args []string) (Config, []string, error) {
+	cfg := Default()
+	fields := collect(reflect.ValueOf(&cfg).Elem(), "")
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "archivo de configuración YAML o TOML")
+	flags := make(map[string]*string, len(fields))
+	for _, f := range fields {
+		flags[f.key] = fs.String(f.key, "", f.usage+" (por defecto "+format(f.value)+")")
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *path != "" {
+		values, err := readFile(*path)
+		if err != nil {
+			return cfg, nil, err
+		}
+
+		for _, f := range fields {
+			raw, ok := values[f.key]
+			if !ok {
+				continue
+			}
+
+			delete(values, f.key)
+			if err := set(f.value, raw); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %s: %v", *path, f.key, err)
+			}
+		}
+
+		if len(values) > 0 {
+			unknown := make([]string, 0, len(values))
+			for k := range values {
+				unknown = append(unknown, k)
+			}
+			sort.Strings(unknown)
+			return cfg, nil, fmt.Errorf("%s: llaves desconocidas: %s", *path, strings.Join(unknown, ", "))
+		}
+	}
+
+	for _, f := range fields {
+		raw, ok := os.LookupEnv(envName(f.key))
+		if !ok {
+			continue
+		}
+
+		if err := set(f.value, raw); err != nil {
+			return cfg, nil, fmt.Errorf("%s: %v", envName(f.key), err)
+		}
+	}
+
+	for _, f := range fields {
+		if !isSet(fs, f.key) {
+			continue
+		}
+
+		if err := set(f.value, *flags[f.key]); err != nil {
+			return cfg, nil, fmt.Errorf("-%s: %v", f.key, err)
+		}
+	}
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+func isSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(key, ".", "_", -1))
+}
+
+//collect recorre la estructura y devuelve los campos hoja con su llave.
+func collect(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, ok := sf.Tag.Lookup("config")
+		if !ok {
+			continue
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			out = append(out, collect(fv, key)...)
+			continue
+		}
+
+		out = append(out, field{key: key, usage: sf.Tag.Get("usage"), value: fv})
+	}
+
+	return out
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//set asigna el valor en texto al campo según su tipo.
+func set(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Slice:
+		var items []string
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				items = append(items, s)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("tipo no soportado %s", v.Type())
+	}
+
+	return nil
+}
+
+//format devuelve el valor del campo tal como lo recibiría set.
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+
+	return fmt.Sprint(v.Interface())
+}
+
+//readFile lee el archivo de configuración y lo aplana a llaves con puntos.
+func readFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo leer el archivo de configuración: %v", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("formato de configuración no soportado: %s", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo interpretar %s: %v", path, err)
+	}
+
+	out := make(map[string]string)
+	flatten(out, "", doc)
+	return out, nil
+}
+
+func flatten(out map[string]string, prefix string, v interface{}) {
+	join := func(k interface{}) string {
+		if prefix == "" {
+			return fmt.Sprint(k)
+		}
+		return prefix + "." + fmt.Sprint(k)
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			flatten(out, join(k), item)
+		}
+	case map[interface{}]interface{}:
+		for k, item := range v {
+			flatten(out, join(k), item)
+		}
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
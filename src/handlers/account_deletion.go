@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type deleteAccountInput struct {
+	Password string `json:"password,omitempty"`
+}
+
+func (h *handler) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	var in deleteAccountInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.DeleteAccount(r.Context(), in.Password)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if e, ok := err.(*service.LoginThrottledError); ok {
+		respondThrottled(w, e)
+		return
+	}
+
+	if err == service.ErrInvalidCurrentPassword {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusAccepted)
+}
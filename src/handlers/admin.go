@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/matryer/way"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//withPermission deja pasar solo a los usuarios autenticados cuyo rol tiene
+//el permiso perm.
+func (h *handler) withPermission(perm string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, err := h.Can(r.Context(), perm)
+		if err == service.ErrUnauthenticated {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if err != nil {
+			respondError(w, err)
+			return
+		}
+
+		if !ok {
+			http.Error(w, service.ErrForbidden.Error(), http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+//respondAdminError responde los errores comunes de las acciones sobre un
+//usuario. Devuelve false si err es nil.
+func respondAdminError(w http.ResponseWriter, err error) bool {
+	switch err {
+	case nil:
+		return false
+	case service.ErrUnauthenticated:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case service.ErrForbidden:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case service.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case service.ErrCannotModerateSelf:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case service.ErrInvalideUsername, service.ErrInvalidRole, service.ErrInvalidReason:
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		respondError(w, err)
+	}
+
+	return true
+}
+
+func (h *handler) adminUsers(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	search := q.Get("search")
+	first, _ := strconv.Atoi(q.Get("first"))
+	after := q.Get("after")
+	uu, err := h.AdminUsers(r.Context(), search, first, after)
+	if respondAdminError(w, err) {
+		return
+	}
+
+	respond(w, uu, http.StatusOK)
+}
+
+type suspendUserInput struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func (h *handler) suspendUser(w http.ResponseWriter, r *http.Request) {
+	var in suspendUserInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	err := h.SuspendUser(ctx, way.Param(ctx, "username"), in.Reason)
+	if respondAdminError(w, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) unsuspendUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.UnsuspendUser(ctx, way.Param(ctx, "username"))
+	if respondAdminError(w, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) forcePasswordReset(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.ForcePasswordReset(ctx, way.Param(ctx, "username"))
+	if respondAdminError(w, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) adminUnlockLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.AdminUnlockLogin(ctx, way.Param(ctx, "username"))
+	if respondAdminError(w, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.DeleteUser(ctx, way.Param(ctx, "username"))
+	if respondAdminError(w, err) {
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type setUserRolesInput struct {
+	Roles []string `json:"roles"`
+}
+
+func (h *handler) setUserRoles(w http.ResponseWriter, r *http.Request) {
+	var in setUserRolesInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	roles, err := h.SetUserRoles(ctx, way.Param(ctx, "username"), in.Roles)
+	if respondAdminError(w, err) {
+		return
+	}
+
+	respond(w, setUserRolesInput{Roles: roles}, http.StatusOK)
+}
+
+func (h *handler) auditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	first, _ := strconv.Atoi(q.Get("first"))
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+	ee, err := h.AuditLog(r.Context(), first, before)
+	if respondAdminError(w, err) {
+		return
+	}
+
+	respond(w, ee, http.StatusOK)
+}
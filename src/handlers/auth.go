@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strings"
 
@@ -29,8 +30,13 @@ func (h *handler) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err == service.ErrUserNotFound {
-		http.Error(w, err.Error(), http.StatusNotFound)
+	if err == service.ErrInvalidCredentials {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if e, ok := err.(*service.LoginThrottledError); ok {
+		respondThrottled(w, e)
 		return
 	}
 
@@ -39,6 +45,38 @@ func (h *handler) login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err == service.ErrEmailNotVerified || err == service.ErrAccountSuspended {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusOK)
+}
+
+type refreshTokenInput struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func (h *handler) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var in refreshTokenInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.RefreshToken(r.Context(), in.RefreshToken)
+	if err == service.ErrInvalidRefreshToken || err == service.ErrRefreshTokenReused {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	if err != nil {
 		respondError(w, err)
 		return
@@ -47,6 +85,48 @@ func (h *handler) login(w http.ResponseWriter, r *http.Request) {
 	respond(w, out, http.StatusOK)
 }
 
+type logoutInput struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func (h *handler) logout(w http.ResponseWriter, r *http.Request) {
+	var in logoutInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.Logout(r.Context(), in.RefreshToken)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) logoutAll(w http.ResponseWriter, r *http.Request) {
+	err := h.LogoutAll(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (h *handler) withAuth(next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -55,28 +135,48 @@ func (h *handler) withAuth(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
+		ctx := context.WithValue(r.Context(), service.KeyClientInfo, clientInfo(r))
 		a := r.Header.Get("Authorization")
 
-		if !strings.HasPrefix(a, "Bearer") {
-			next.ServeHTTP(w, r)
+		//un "Bearer " sin token llega como "Bearer", sin el espacio
+		if a != "Bearer" && !strings.HasPrefix(a, "Bearer ") {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		token := strings.TrimSpace(strings.TrimPrefix(a, "Bearer "))
+		if a == "Bearer" || token == "" {
+			http.Error(w, service.ErrUnauthenticated.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		token := a[7:]
-		uid, err := h.AuthUserID(token)
+		p, err := h.AuthUserID(ctx, token)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, service.KeyAuthUser, uid)
+		ctx = context.WithValue(ctx, service.KeyPrincipal, p)
 		next.ServeHTTP(w, r.WithContext(ctx))
 
 	})
 
 }
 
+//withScope deja pasar las peticiones sin autenticar, que resuelve cada
+//handler, y las autenticadas con un token que tiene el alcance scope.
+func withScope(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := service.PrincipalFromContext(r.Context())
+		if ok && !p.HasScope(scope) {
+			http.Error(w, service.ErrInsufficientScope.Error(), http.StatusForbidden)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
 func (h *handler) authUser(w http.ResponseWriter, r *http.Request) {
 
 	u, err := h.AuthUser(r.Context())
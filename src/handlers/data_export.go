@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/matryer/way"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//dataExportOutput agrega a la exportación la dirección de descarga, que
+//solo llega cuando está lista.
+type dataExportOutput struct {
+	service.DataExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+func (h *handler) requestDataExport(w http.ResponseWriter, r *http.Request) {
+	out, err := h.RequestDataExport(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrTooManyDataExports {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err == service.ErrDataExportsUnavailable {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, dataExportOutput{DataExport: out}, http.StatusAccepted)
+}
+
+func (h *handler) dataExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	out, err := h.DataExport(ctx, way.Param(ctx, "id"))
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrDataExportNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	res := dataExportOutput{DataExport: out}
+	if out.DownloadToken != "" {
+		res.DownloadURL = "/api/exports/" + url.PathEscape(out.ID) + "/download?token=" + url.QueryEscape(out.DownloadToken)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, res, http.StatusOK)
+}
+
+func (h *handler) downloadDataExport(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	f, err := h.DownloadDataExport(ctx, way.Param(ctx, "id"), r.URL.Query().Get("token"))
+	if err == service.ErrInvalidDownloadToken {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err == service.ErrDataExportsUnavailable {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="social-network-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, f)
+}
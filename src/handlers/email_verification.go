@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type verifyEmailInput struct {
+	Token string `json:"token,omitempty"`
+}
+
+func (h *handler) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	var in verifyEmailInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.VerifyEmail(r.Context(), in.Token)
+	if err == service.ErrInvalidVerificationToken {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type resendEmailVerificationInput struct {
+	Email string `json:"email,omitempty"`
+}
+
+func (h *handler) resendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	var in resendEmailVerificationInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.ResendEmailVerification(r.Context(), in.Email)
+	if err == service.ErrInvalideEmail {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrEmailAlreadyVerified {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err == service.ErrTooManyVerificationRequests {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err == service.ErrUserNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
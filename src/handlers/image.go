@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//errMissingImage cuando la petición no trae el campo image.
+var errMissingImage = errors.New("falta el campo image")
+
+func (h *handler) setAvatar(w http.ResponseWriter, r *http.Request) {
+	h.uploadImage(w, r, h.SetAvatar)
+}
+
+func (h *handler) setCover(w http.ResponseWriter, r *http.Request) {
+	h.uploadImage(w, r, h.SetCover)
+}
+
+func (h *handler) deleteAvatar(w http.ResponseWriter, r *http.Request) {
+	u, err := h.DeleteAvatar(r.Context())
+	respondImage(w, u, err)
+}
+
+func (h *handler) deleteCover(w http.ResponseWriter, r *http.Request) {
+	u, err := h.DeleteCover(r.Context())
+	respondImage(w, u, err)
+}
+
+//uploadImage lee el campo image de un formulario multipart/form-data sin
+//guardarlo en disco; el servicio corta la lectura al pasar el límite.
+func (h *handler) uploadImage(w http.ResponseWriter, r *http.Request,
+	set func(context.Context, io.Reader) (service.UserProfile, error)) {
+	defer r.Body.Close()
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			http.Error(w, errMissingImage.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if part.FormName() == "image" {
+			u, err := set(r.Context(), part)
+			respondImage(w, u, err)
+			return
+		}
+	}
+}
+
+func respondImage(w http.ResponseWriter, u service.UserProfile, err error) {
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrImageTooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err == service.ErrUnsupportedImage {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err == service.ErrImagesUnavailable {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, u, http.StatusOK)
+}
@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/matryer/way"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type createOAuthClientInput struct {
+	Name         string   `json:"name,omitempty"`
+	RedirectURIs []string `json:"redirect_uris,omitempty"`
+	Public       bool     `json:"public,omitempty"`
+}
+
+func (h *handler) createOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var in createOAuthClientInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.CreateOAuthClient(r.Context(), in.Name, in.RedirectURIs, in.Public)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrInvalidClientName || err == service.ErrInvalidRedirectURI {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrTooManyOAuthClients {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusCreated)
+}
+
+func (h *handler) oauthClients(w http.ResponseWriter, r *http.Request) {
+	cc, err := h.OAuthClients(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, cc, http.StatusOK)
+}
+
+func (h *handler) deleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.DeleteOAuthClient(ctx, way.Param(ctx, "id"))
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrOAuthClientNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) oauthConsents(w http.ResponseWriter, r *http.Request) {
+	cc, err := h.OAuthConsents(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, cc, http.StatusOK)
+}
+
+func (h *handler) revokeOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	err := h.RevokeOAuthConsent(ctx, way.Param(ctx, "client_id"))
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrOAuthConsentNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//authorizeInfo valida la solicitud de autorización que llega en la query y
+//devuelve lo que el front debe mostrar en la pantalla de consentimiento.
+func (h *handler) authorizeInfo(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	out, err := h.AuthorizeInfo(r.Context(), service.AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	})
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondOAuthError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusOK)
+}
+
+type authorizeInput struct {
+	service.AuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+type authorizeOutput struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+//authorize registra la decisión del usuario. El front redirige a la URI que
+//se devuelve.
+func (h *handler) authorize(w http.ResponseWriter, r *http.Request) {
+	var in authorizeInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	uri, err := h.Authorize(r.Context(), in.AuthorizeRequest, in.Approve)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondOAuthError(w, err)
+		return
+	}
+
+	respond(w, authorizeOutput{RedirectURI: uri}, http.StatusOK)
+}
+
+//oauthToken es el endpoint de tokens del RFC 6749. Recibe un formulario y
+//la aplicación se autentica con Basic o con client_id y client_secret.
+func (h *handler) oauthToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := oauthClientCredentials(r)
+	if !ok {
+		respondOAuthError(w, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	out, err := h.OAuthToken(r.Context(), service.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+	})
+	if err != nil {
+		respondOAuthError(w, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	respond(w, out, http.StatusOK)
+}
+
+//revokeOAuthToken es el endpoint de revocación del RFC 7009.
+func (h *handler) revokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := oauthClientCredentials(r)
+	if !ok {
+		respondOAuthError(w, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	err := h.RevokeOAuthToken(r.Context(), clientID, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		respondOAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+//introspectOAuthToken es el endpoint de introspección del RFC 7662.
+func (h *handler) introspectOAuthToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := oauthClientCredentials(r)
+	if !ok {
+		respondOAuthError(w, &service.OAuthError{Code: "invalid_request"})
+		return
+	}
+
+	out, err := h.IntrospectOAuthToken(r.Context(), clientID, clientSecret, r.PostForm.Get("token"))
+	if err != nil {
+		respondOAuthError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusOK)
+}
+
+//oauthClientCredentials lee el formulario y las credenciales de la
+//aplicación, primero de Basic y si no del formulario.
+func oauthClientCredentials(r *http.Request) (string, string, bool) {
+	if err := r.ParseForm(); err != nil {
+		return "", "", false
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), true
+	}
+
+	//el RFC 6749 pide codificar las credenciales como formulario antes de Basic
+	id, err := url.QueryUnescape(id)
+	if err != nil {
+		return "", "", false
+	}
+
+	secret, err = url.QueryUnescape(secret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return id, secret, true
+}
+
+//respondOAuthError responde los errores del protocolo como JSON, con 401
+//para invalid_client y 400 para los demás.
+func respondOAuthError(w http.ResponseWriter, err error) {
+	e, ok := err.(*service.OAuthError)
+	if !ok {
+		respondError(w, err)
+		return
+	}
+
+	status := http.StatusBadRequest
+	if e.Code == "invalid_client" {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		status = http.StatusUnauthorized
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respond(w, e, status)
+}
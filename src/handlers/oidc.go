@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/matryer/way"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//oidcStateCookie guarda el state en el navegador para comprobar que la
+//vuelta del proveedor es del mismo navegador que empezó el login.
+const oidcStateCookie = "oidc_state"
+
+func (h *handler) identityProviders(w http.ResponseWriter, r *http.Request) {
+	respond(w, h.IdentityProviders(), http.StatusOK)
+}
+
+//oidcAuthorize manda al usuario al proveedor.
+func (h *handler) oidcAuthorize(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := way.Param(ctx, "provider")
+	authURL, state, err := h.StartOIDCLogin(ctx, provider)
+	if err == service.ErrIdentityProviderNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/oidc/" + provider,
+		Expires:  time.Now().Add(10 * time.Minute),
+		Secure:   r.TLS != nil,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+//oidcCallback es la vuelta del proveedor. Responde como el login.
+func (h *handler) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	provider := way.Param(ctx, "provider")
+	q := r.URL.Query()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/api/oidc/" + provider,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	if e := q.Get("error"); e != "" {
+		http.Error(w, service.ErrOIDCLoginFailed.Error()+": "+e, http.StatusUnauthorized)
+		return
+	}
+
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil || c.Value != q.Get("state") {
+		http.Error(w, service.ErrInvalidOIDCState.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.FinishOIDCLogin(ctx, provider, q.Get("state"), q.Get("code"))
+	if err == service.ErrIdentityProviderNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err == service.ErrInvalidOIDCState {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err == service.ErrOIDCLoginFailed {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrOIDCEmailNotVerified || err == service.ErrEmailNotVerified || err == service.ErrAccountSuspended {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err == service.ErrOIDCAccountConflict {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusOK)
+}
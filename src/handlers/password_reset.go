@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type requestPasswordResetInput struct {
+	Email string `json:"email,omitempty"`
+}
+
+func (h *handler) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var in requestPasswordResetInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.RequestPasswordReset(r.Context(), in.Email)
+	if err == service.ErrInvalideEmail {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrTooManyResetRequests {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type resetPasswordInput struct {
+	Token    string `json:"token,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+func (h *handler) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var in resetPasswordInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.ResetPassword(r.Context(), in.Token, in.Password)
+	if err == service.ErrInvalidResetToken || err == service.ErrInvalidPassword {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if e, ok := err.(*service.PasswordPolicyError); ok {
+		respond(w, e, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
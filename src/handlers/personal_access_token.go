@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/matryer/way"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type createPersonalAccessTokenInput struct {
+	Name          string   `json:"name,omitempty"`
+	Scopes        []string `json:"scopes,omitempty"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty"`
+}
+
+func (h *handler) createPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	var in createPersonalAccessTokenInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.CreatePersonalAccessToken(r.Context(), in.Name, in.Scopes, in.ExpiresInDays)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrInvalidTokenName || err == service.ErrInvalidScope || err == service.ErrInvalidTokenExpiration {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrTooManyPersonalAccessTokens {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusCreated)
+}
+
+func (h *handler) personalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	tt, err := h.PersonalAccessTokens(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, tt, http.StatusOK)
+}
+
+func (h *handler) revokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := way.Param(ctx, "id")
+
+	err := h.RevokePersonalAccessToken(ctx, id)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrPersonalAccessTokenNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
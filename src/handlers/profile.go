@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+func (h *handler) updateProfile(w http.ResponseWriter, r *http.Request) {
+	var in service.ProfileUpdate
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.UpdateProfile(r.Context(), in)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrInvalidDisplayName ||
+		err == service.ErrInvalidBio ||
+		err == service.ErrInvalidLocation ||
+		err == service.ErrInvalidWebsite ||
+		err == service.ErrInvalidBirthday ||
+		err == service.ErrInvalideEmail ||
+		err == service.ErrInvalidPassword {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if e, ok := err.(*service.PasswordPolicyError); ok {
+		respond(w, e, http.StatusUnprocessableEntity)
+		return
+	}
+
+	if e, ok := err.(*service.LoginThrottledError); ok {
+		respondThrottled(w, e)
+		return
+	}
+
+	if err == service.ErrInvalidCurrentPassword {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err == service.ErrEmailTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err == service.ErrTooManyEmailChanges {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, u, http.StatusOK)
+}
+
+type confirmEmailChangeInput struct {
+	Token string `json:"token,omitempty"`
+}
+
+func (h *handler) confirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	var in confirmEmailChangeInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.ConfirmEmailChange(r.Context(), in.Token)
+	if err == service.ErrInvalidEmailChangeToken {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrEmailTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -17,12 +17,77 @@ func New(s *service.Service) http.Handler {
 	api := way.NewRouter()
 	h := &handler{s}
 
-	api.HandleFunc("POST", "/login", h.login)
-	api.HandleFunc("POST", "/users", h.createUser)
-	api.HandleFunc("GET", "/auth_user", h.authUser)
-	api.HandleFunc("GET", "/users", h.users)
-	api.HandleFunc("GET", "/users/:username", h.user)
-	api.HandleFunc("POST", "/users/:username/toggle_follow", h.toggleFollow)
+	//Cada ruta declara el alcance que necesita el token de la petición. Las
+	//de service.ScopeAll solo se pueden usar con el token de una sesión, no
+	//con un token personal.
+	handle := func(method, pattern, scope string, fn http.HandlerFunc) {
+		api.Handle(method, pattern, withScope(scope, fn))
+	}
+
+	//Las rutas de administración piden además un permiso del rol del usuario.
+	admin := func(method, pattern, perm string, fn http.HandlerFunc) {
+		handle(method, pattern, service.ScopeAll, h.withPermission(perm, fn))
+	}
+
+	handle("POST", "/login", service.ScopeAll, h.login)
+	handle("POST", "/login/2fa", service.ScopeAll, h.loginTwoFactor)
+	handle("POST", "/token/refresh", service.ScopeAll, h.refreshToken)
+	handle("POST", "/logout", service.ScopeAll, h.logout)
+	handle("POST", "/logout_all", service.ScopeAll, h.logoutAll)
+	handle("POST", "/verify_email", service.ScopeAll, h.verifyEmail)
+	handle("POST", "/verify_email/resend", service.ScopeAll, h.resendEmailVerification)
+	handle("POST", "/password_reset", service.ScopeAll, h.requestPasswordReset)
+	handle("POST", "/password_reset/confirm", service.ScopeAll, h.resetPassword)
+	handle("POST", "/2fa/enroll", service.ScopeAll, h.enrollTwoFactor)
+	handle("POST", "/2fa/confirm", service.ScopeAll, h.confirmTwoFactor)
+	handle("DELETE", "/2fa", service.ScopeAll, h.disableTwoFactor)
+	handle("GET", "/sessions", service.ScopeAll, h.sessions)
+	handle("DELETE", "/sessions/:id", service.ScopeAll, h.revokeSession)
+	handle("POST", "/personal_access_tokens", service.ScopeAll, h.createPersonalAccessToken)
+	handle("GET", "/personal_access_tokens", service.ScopeAll, h.personalAccessTokens)
+	handle("DELETE", "/personal_access_tokens/:id", service.ScopeAll, h.revokePersonalAccessToken)
+	handle("POST", "/oauth/clients", service.ScopeAll, h.createOAuthClient)
+	handle("GET", "/oauth/clients", service.ScopeAll, h.oauthClients)
+	handle("DELETE", "/oauth/clients/:id", service.ScopeAll, h.deleteOAuthClient)
+	handle("GET", "/oauth/consents", service.ScopeAll, h.oauthConsents)
+	handle("DELETE", "/oauth/consents/:client_id", service.ScopeAll, h.revokeOAuthConsent)
+	handle("GET", "/oauth/authorize", service.ScopeAll, h.authorizeInfo)
+	handle("POST", "/oauth/authorize", service.ScopeAll, h.authorize)
+	handle("POST", "/oauth/token", service.ScopeAll, h.oauthToken)
+	handle("POST", "/oauth/revoke", service.ScopeAll, h.revokeOAuthToken)
+	handle("POST", "/oauth/introspect", service.ScopeAll, h.introspectOAuthToken)
+	handle("GET", "/oidc/providers", service.ScopeAll, h.identityProviders)
+	handle("GET", "/oidc/:provider/authorize", service.ScopeAll, h.oidcAuthorize)
+	handle("GET", "/oidc/:provider/callback", service.ScopeAll, h.oidcCallback)
+	handle("POST", "/users", service.ScopeAll, h.createUser)
+	handle("GET", "/auth_user", service.ScopeUsersRead, h.authUser)
+	handle("PATCH", "/auth_user", service.ScopeAll, h.updateProfile)
+	handle("DELETE", "/auth_user", service.ScopeAll, h.deleteAccount)
+	handle("POST", "/auth_user/email/confirm", service.ScopeAll, h.confirmEmailChange)
+	handle("PUT", "/auth_user/username", service.ScopeAll, h.changeUsername)
+	handle("PUT", "/auth_user/avatar", service.ScopeAll, h.setAvatar)
+	handle("DELETE", "/auth_user/avatar", service.ScopeAll, h.deleteAvatar)
+	handle("PUT", "/auth_user/cover", service.ScopeAll, h.setCover)
+	handle("DELETE", "/auth_user/cover", service.ScopeAll, h.deleteCover)
+	handle("POST", "/auth_user/export", service.ScopeAll, h.requestDataExport)
+	handle("GET", "/auth_user/export/:id", service.ScopeAll, h.dataExport)
+	handle("GET", "/exports/:id/download", service.ScopeAll, h.downloadDataExport)
+	handle("GET", "/users", service.ScopeUsersRead, h.users)
+	handle("GET", "/users/:username", service.ScopeUsersRead, h.user)
+	handle("GET", "/users/:username/followers", service.ScopeUsersRead, h.followers)
+	handle("GET", "/users/:username/followees", service.ScopeUsersRead, h.followees)
+	handle("POST", "/users/:username/toggle_follow", service.ScopeFollowsWrite, h.toggleFollow)
+	handle("PUT", "/users/:username/follow", service.ScopeFollowsWrite, h.follow)
+	handle("DELETE", "/users/:username/follow", service.ScopeFollowsWrite, h.unfollow)
+
+	admin("GET", "/admin/users", service.PermListUsers, h.adminUsers)
+	admin("POST", "/admin/users/:username/suspend", service.PermSuspendUsers, h.suspendUser)
+	admin("POST", "/admin/users/:username/unsuspend", service.PermSuspendUsers, h.unsuspendUser)
+	admin("POST", "/admin/users/:username/password_reset", service.PermResetPasswords, h.forcePasswordReset)
+	admin("POST", "/admin/users/:username/unlock", service.PermUnlockLogins, h.adminUnlockLogin)
+	admin("PUT", "/admin/users/:username/roles", service.PermManageRoles, h.setUserRoles)
+	admin("DELETE", "/admin/users/:username", service.PermDeleteUsers, h.deleteUser)
+	admin("GET", "/admin/audit_log", service.PermReadAudit, h.auditLog)
 
 	r := way.NewRouter()
 	r.Handle("*", "/api...", http.StripPrefix("/api", h.withAuth(api)))
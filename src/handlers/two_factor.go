@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type loginTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token,omitempty"`
+	Code           string `json:"code,omitempty"`
+}
+
+func (h *handler) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var in loginTwoFactorInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := h.LoginTwoFactor(r.Context(), in.ChallengeToken, in.Code)
+	if err == service.ErrInvalidTwoFactorChallenge || err == service.ErrInvalidTwoFactorCode {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if e, ok := err.(*service.LoginThrottledError); ok {
+		respondThrottled(w, e)
+		return
+	}
+
+	if err == service.ErrAccountSuspended {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusOK)
+}
+
+func (h *handler) enrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	out, err := h.EnrollTwoFactor(r.Context())
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrTwoFactorAlreadyEnabled {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, out, http.StatusCreated)
+}
+
+type twoFactorCodeInput struct {
+	Code string `json:"code,omitempty"`
+}
+
+type confirmTwoFactorOutput struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (h *handler) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var in twoFactorCodeInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.ConfirmTwoFactor(r.Context(), in.Code)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrTwoFactorNotEnabled {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err == service.ErrTwoFactorAlreadyEnabled {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if err == service.ErrInvalidTwoFactorCode {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, confirmTwoFactorOutput{codes}, http.StatusOK)
+}
+
+func (h *handler) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var in twoFactorCodeInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.DisableTwoFactor(r.Context(), in.Code)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrTwoFactorNotEnabled {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if err == service.ErrInvalidTwoFactorCode {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if e, ok := err.(*service.LoginThrottledError); ok {
+		respondThrottled(w, e)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -42,6 +43,16 @@ func (h *handler) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err == service.ErrUsernameReserved {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if e, ok := err.(*service.PasswordPolicyError); ok {
+		respond(w, e, http.StatusUnprocessableEntity)
+		return
+	}
+
 	if err == service.ErrUserOk {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"text":"Usuario ingresado correctamente!"}`))
@@ -71,6 +82,11 @@ func (h *handler) user(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if e, ok := err.(*service.UsernameMovedError); ok {
+		redirectMovedUser(w, r, username, e)
+		return
+	}
+
 	if err != nil {
 		respondError(w, err)
 		return
@@ -80,10 +96,23 @@ func (h *handler) user(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handler) toggleFollow(w http.ResponseWriter, r *http.Request) {
+	h.setFollow(w, r, h.ToggleFollow)
+}
+
+func (h *handler) follow(w http.ResponseWriter, r *http.Request) {
+	h.setFollow(w, r, h.Follow)
+}
+
+func (h *handler) unfollow(w http.ResponseWriter, r *http.Request) {
+	h.setFollow(w, r, h.Unfollow)
+}
+
+func (h *handler) setFollow(w http.ResponseWriter, r *http.Request,
+	set func(context.Context, string) (service.ToggleFollowOutput, error)) {
 	ctx := r.Context()
 	username := way.Param(ctx, "username")
 
-	out, err := h.ToggleFollow(ctx, username)
+	out, err := set(ctx, username)
 	if err == service.ErrUnauthenticated {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
 		return
@@ -99,7 +128,12 @@ func (h *handler) toggleFollow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err == service.ErrForbiddenFollow {
+	if e, ok := err.(*service.UsernameMovedError); ok {
+		redirectMovedUser(w, r, username, e)
+		return
+	}
+
+	if err == service.ErrForbiddenFollow || err == service.ErrEmailNotVerified {
 		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
@@ -124,3 +158,41 @@ func (h *handler) users(w http.ResponseWriter, r *http.Request) {
 	}
 	respond(w, uu, http.StatusOK)
 }
+
+func (h *handler) followers(w http.ResponseWriter, r *http.Request) {
+	h.follows(w, r, h.Followers)
+}
+
+func (h *handler) followees(w http.ResponseWriter, r *http.Request) {
+	h.follows(w, r, h.Followees)
+}
+
+func (h *handler) follows(w http.ResponseWriter, r *http.Request,
+	list func(context.Context, string, int, string) ([]service.UserProfile, error)) {
+	ctx := r.Context()
+	username := way.Param(ctx, "username")
+	q := r.URL.Query()
+	first, _ := strconv.Atoi(q.Get("first"))
+	uu, err := list(ctx, username, first, q.Get("after"))
+	if err == service.ErrInvalideUsername {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrUserNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if e, ok := err.(*service.UsernameMovedError); ok {
+		redirectMovedUser(w, r, username, e)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, uu, http.StatusOK)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+type changeUsernameInput struct {
+	Username string `json:"username,omitempty"`
+}
+
+func (h *handler) changeUsername(w http.ResponseWriter, r *http.Request) {
+	var in changeUsernameInput
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.ChangeUsername(r.Context(), in.Username)
+	if err == service.ErrUnauthenticated {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err == service.ErrInvalideUsername {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err == service.ErrUsernameTaken || err == service.ErrUsernameReserved {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	if e, ok := err.(*service.UsernameCooldownError); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+		http.Error(w, e.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	respond(w, u, http.StatusOK)
+}
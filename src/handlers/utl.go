@@ -4,7 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Mynor2397/social-network/src/service"
 )
 
 func respond(w http.ResponseWriter, v interface{}, statuscode int) {
@@ -24,3 +31,38 @@ func respondError(w http.ResponseWriter, err error) {
 	log.Println(err)
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
+
+//respondThrottled responde 423 si la cuenta está bloqueada o 429 si lo está
+//la IP, con Retry-After en segundos.
+func respondThrottled(w http.ResponseWriter, e *service.LoginThrottledError) {
+	status := http.StatusTooManyRequests
+	if e.Err == service.ErrAccountLocked {
+		status = http.StatusLocked
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	http.Error(w, e.Error(), status)
+}
+
+//redirectMovedUser redirige una ruta /users/:username con un username que ya
+//cambió a la misma ruta con el username actual. Es temporal porque el
+//username viejo puede quedar libre, y conserva el método de la petición.
+func redirectMovedUser(w http.ResponseWriter, r *http.Request, username string, e *service.UsernameMovedError) {
+	rest := strings.TrimPrefix(r.URL.Path, "/users/"+username)
+	location := "/api/users/" + url.PathEscape(e.Username) + rest
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+
+	http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+}
+
+//clientInfo toma la IP y el user agent de la petición.
+func clientInfo(r *http.Request) service.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return service.ClientInfo{IP: ip, UserAgent: r.UserAgent()}
+}
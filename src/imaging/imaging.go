@@ -0,0 +1,170 @@
+//Package imaging decodifica las imágenes que suben los usuarios y genera sus
+//miniaturas. Solo usa la librería estándar: las miniaturas se calculan con el
+//promedio del área de cada pixel, que da buen resultado al reducir.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"net/http"
+
+	//registra los formatos que acepta Decode
+	_ "image/gif"
+	_ "image/png"
+)
+
+var (
+	//ErrUnsupportedFormat cuando el contenido no es una imagen JPEG, PNG o
+	//GIF.
+	ErrUnsupportedFormat = errors.New("formato de imagen no soportado")
+
+	//ErrTooManyPixels cuando la imagen tiene más pixeles de los permitidos.
+	ErrTooManyPixels = errors.New("la imagen tiene demasiados pixeles")
+)
+
+//formats son los formatos aceptados por el tipo que detecta
+//http.DetectContentType y el nombre con el que los registra image.
+var formats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+//Decode reconoce el formato por el contenido, sin fiarse de la extensión ni
+//del tipo que mande el cliente, revisa las dimensiones antes de decodificar
+//para no reservar memoria de más y devuelve la imagen sin metadatos. De un
+//GIF animado solo toma el primer cuadro.
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	format, ok := formats[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	cfg, name, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || name != format || cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, ErrUnsupportedFormat
+	}
+
+	if cfg.Width > maxPixels/cfg.Height {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+
+	return img, nil
+}
+
+//Flatten copia la imagen sobre un fondo blanco, para que las partes
+//transparentes no queden negras en JPEG.
+func Flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+//Fill escala src para que llene w x h y recorta al centro lo que sobra.
+func Fill(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+
+	//el recorte del centro con la proporción de w x h
+	cw, ch := sw, sh
+	if sw*h > sh*w {
+		cw = sh * w / h
+	} else {
+		ch = sw * h / w
+	}
+
+	if cw < 1 {
+		cw = 1
+	}
+
+	if ch < 1 {
+		ch = 1
+	}
+
+	ox, oy := (sw-cw)/2, (sh-ch)/2
+
+	//primero se escalan las filas y después las columnas
+	xs := areaWeights(cw, w)
+	ys := areaWeights(ch, h)
+
+	tmp := make([]float64, ch*w*4)
+	for y := 0; y < ch; y++ {
+		row := src.Pix[(oy+y)*src.Stride+ox*4:]
+		for x, ws := range xs {
+			p := tmp[(y*w+x)*4:]
+			for _, c := range ws {
+				for k := 0; k < 4; k++ {
+					p[k] += float64(row[c.index*4+k]) * c.weight
+				}
+			}
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, ws := range ys {
+		for x := 0; x < w; x++ {
+			var acc [4]float64
+			for _, c := range ws {
+				p := tmp[(c.index*w+x)*4:]
+				for k := 0; k < 4; k++ {
+					acc[k] += p[k] * c.weight
+				}
+			}
+
+			q := dst.Pix[y*dst.Stride+x*4:]
+			for k := 0; k < 4; k++ {
+				q[k] = uint8(acc[k] + 0.5)
+			}
+		}
+	}
+
+	return dst
+}
+
+//EncodeJPEG escribe la imagen como JPEG con la calidad dada, de 1 a 100.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+type contribution struct {
+	index  int
+	weight float64
+}
+
+//areaWeights devuelve, para cada uno de los dst pixeles, qué pixeles de los
+//src caen en su área y en qué proporción. Al agrandar el área es menor que
+//un pixel y queda el más cercano.
+func areaWeights(src, dst int) [][]contribution {
+	scale := float64(src) / float64(dst)
+	ww := make([][]contribution, dst)
+	for i := range ww {
+		start, end := float64(i)*scale, float64(i+1)*scale
+		for j := int(start); j < src && float64(j) < end; j++ {
+			lo, hi := float64(j), float64(j+1)
+			if lo < start {
+				lo = start
+			}
+
+			if hi > end {
+				hi = end
+			}
+
+			if hi > lo {
+				ww[i] = append(ww[i], contribution{index: j, weight: (hi - lo) / (end - start)})
+			}
+		}
+	}
+
+	return ww
+}
@@ -0,0 +1,261 @@
+//Package keyring firma y verifica tokens branca con varias llaves. Una llave
+//está activa y firma los tokens nuevos; las demás solo verifican los tokens
+//que ya se emitieron, para poder cambiar de llave sin cerrar las sesiones.
+//
+//Cada token lleva el id de su llave como prefijo: "<id>.<token branca>". Los
+//tokens sin prefijo, emitidos antes de que existiera el keyring, se prueban
+//con todas las llaves.
+package keyring
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hako/branca"
+)
+
+//KeySize es el largo que branca exige a las llaves.
+const KeySize = 32
+
+const keyAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+var rxKeyID = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+var (
+	//ErrUnknownKey cuando el token fue firmado con una llave que no está en el keyring.
+	ErrUnknownKey = errors.New("llave del token desconocida")
+
+	//ErrInvalidToken cuando ninguna llave puede verificar el token.
+	ErrInvalidToken = errors.New("token inválido")
+)
+
+//Key es una llave del keyring.
+type Key struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//File es el archivo del keyring: las llaves y el id de la activa.
+type File struct {
+	Active string `json:"active"`
+	Keys   []Key  `json:"keys"`
+}
+
+//Validate revisa que los ids sean válidos y únicos, que las llaves tengan
+//KeySize bytes y que la activa exista.
+func (f File) Validate() error {
+	seen := make(map[string]bool, len(f.Keys))
+	for _, k := range f.Keys {
+		if !rxKeyID.MatchString(k.ID) {
+			return fmt.Errorf("id de llave inválido: %q", k.ID)
+		}
+
+		if seen[k.ID] {
+			return fmt.Errorf("id de llave repetido: %s", k.ID)
+		}
+		seen[k.ID] = true
+
+		if len(k.Secret) != KeySize {
+			return fmt.Errorf("la llave %s debe tener %d bytes, tiene %d", k.ID, KeySize, len(k.Secret))
+		}
+	}
+
+	if !seen[f.Active] {
+		return fmt.Errorf("la llave activa %q no está en el keyring", f.Active)
+	}
+
+	return nil
+}
+
+//Key devuelve la llave con ese id.
+func (f File) Key(id string) (Key, bool) {
+	for _, k := range f.Keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+
+	return Key{}, false
+}
+
+//Load lee el archivo del keyring.
+func Load(path string) (File, error) {
+	var f File
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+
+	if err = json.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("no se pudo leer el keyring %s: %v", path, err)
+	}
+
+	return f, f.Validate()
+}
+
+//Save escribe el archivo del keyring con permisos solo para el dueño. Escribe
+//a un temporal y lo renombra, para que un servidor que lo recargue nunca lea
+//un archivo a medias.
+func (f File) Save(path string) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".keyring-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(append(b, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+//GenerateKey crea una llave aleatoria. El id lleva la fecha para ordenarlas
+//a simple vista.
+func GenerateKey(now time.Time) (Key, error) {
+	suffix, err := randomString(4)
+	if err != nil {
+		return Key{}, err
+	}
+
+	secret, err := randomString(KeySize)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		ID:        now.UTC().Format("20060102") + "-" + strings.ToLower(suffix),
+		Secret:    secret,
+		CreatedAt: now.UTC(),
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	max := big.NewInt(int64(len(keyAlphabet)))
+	b := make([]byte, n)
+	for i := range b {
+		c, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = keyAlphabet[c.Int64()]
+	}
+
+	return string(b), nil
+}
+
+//Keyring firma con la llave activa y verifica con cualquiera. Se puede
+//reemplazar su contenido con Replace mientras está en uso.
+type Keyring struct {
+	ttl uint32
+
+	mu     sync.RWMutex
+	active string
+	codecs map[string]*branca.Branca
+	order  []string
+}
+
+//New crea un keyring con las llaves de f. ttl es la vigencia de los tokens.
+func New(f File, ttl time.Duration) (*Keyring, error) {
+	k := &Keyring{ttl: uint32(ttl.Seconds())}
+	if err := k.Replace(f); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+//Replace cambia las llaves del keyring.
+func (k *Keyring) Replace(f File) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	codecs := make(map[string]*branca.Branca, len(f.Keys))
+	order := make([]string, 0, len(f.Keys))
+	for _, key := range f.Keys {
+		c := branca.NewBranca(key.Secret)
+		c.SetTTL(k.ttl)
+		codecs[key.ID] = c
+		order = append(order, key.ID)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.active = f.Active
+	k.codecs = codecs
+	k.order = order
+	return nil
+}
+
+//Encode firma payload con la llave activa. branca.Branca guarda la marca de
+//tiempo del primer token que firma y la reutiliza en los siguientes, así que
+//cada token se firma con una copia del codec.
+func (k *Keyring) Encode(payload string) (string, error) {
+	k.mu.RLock()
+	id := k.active
+	codec := *k.codecs[id]
+	k.mu.RUnlock()
+
+	token, err := codec.EncodeToString(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return id + "." + token, nil
+}
+
+//Decode verifica el token con la llave de su prefijo y devuelve el payload.
+func (k *Keyring) Decode(token string) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if i := strings.IndexByte(token, '.'); i >= 0 {
+		codec, ok := k.codecs[token[:i]]
+		if !ok {
+			return "", ErrUnknownKey
+		}
+
+		return codec.DecodeToString(token[i+1:])
+	}
+
+	for _, id := range k.order {
+		if payload, err := k.codecs[id].DecodeToString(token); err == nil {
+			return payload, nil
+		}
+	}
+
+	return "", ErrInvalidToken
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//LogMailer escribe los correos en el log en lugar de enviarlos.
+type LogMailer struct {
+	From string
+}
+
+var _ service.Mailer = (*LogMailer)(nil)
+
+//Send implementa service.Mailer.
+func (m *LogMailer) Send(ctx context.Context, msg service.Mail) error {
+	log.Printf("correo de %s para %s: %s\n%s", m.From, msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+//FileMailer guarda cada correo como un archivo .eml en Dir.
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+var _ service.Mailer = (*FileMailer)(nil)
+
+//Send implementa service.Mailer.
+func (m *FileMailer) Send(ctx context.Context, msg service.Mail) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return fmt.Errorf("no se pudo crear el directorio de correos: %v", err)
+	}
+
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	name := now.Format("20060102T150405") + "-" + hex.EncodeToString(b) + ".eml"
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", m.From)
+	fmt.Fprintf(&sb, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&sb, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&sb, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&sb, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	sb.WriteString(msg.Body)
+
+	if err := ioutil.WriteFile(filepath.Join(m.Dir, name), []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("no se pudo guardar el correo: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//ScheduleDeletion implementa service.DeletionStore.
+func (s *Store) ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	u.deleteAt = &at
+	return nil
+}
+
+//CancelDeletion implementa service.DeletionStore.
+func (s *Store) CancelDeletion(ctx context.Context, userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.deleteAt == nil {
+		return false, nil
+	}
+
+	u.deleteAt = nil
+	return true, nil
+}
+
+//DueDeletions implementa service.DeletionStore.
+func (s *Store) DueDeletions(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var due []*user
+	for _, u := range s.users {
+		if u.deleteAt != nil && !u.deleteAt.After(now) {
+			due = append(due, u)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].deleteAt.Before(*due[j].deleteAt)
+	})
+
+	var ids []int64
+	for i := 0; i < len(due) && i < limit; i++ {
+		ids = append(ids, due[i].id)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,269 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//UserRoles implementa service.RoleStore.
+func (s *Store) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return []string{}, nil
+	}
+
+	return append([]string{}, u.roles...), nil
+}
+
+//SetUserRoles implementa service.RoleStore.
+func (s *Store) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	u.roles = append([]string{}, roles...)
+	sort.Strings(u.roles)
+	return nil
+}
+
+//AdminUsers implementa service.AdminStore.
+func (s *Store) AdminUsers(ctx context.Context, search string, first int, after string) ([]service.AdminUser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search = fold(search)
+	after = fold(after)
+
+	matches := make([]*user, 0, len(s.users))
+	for _, u := range s.users {
+		name := fold(u.username)
+		if search != "" && !strings.Contains(name, search) && !strings.Contains(fold(u.email), search) {
+			continue
+		}
+
+		if after != "" && name <= after {
+			continue
+		}
+
+		matches = append(matches, u)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return fold(matches[i].username) < fold(matches[j].username)
+	})
+
+	if len(matches) > first {
+		matches = matches[:first]
+	}
+
+	uu := make([]service.AdminUser, len(matches))
+	for i, u := range matches {
+		uu[i] = service.AdminUser{
+			ID:             u.id,
+			Email:          u.email,
+			Username:       u.username,
+			Roles:          append([]string{}, u.roles...),
+			EmailVerified:  u.emailVerifiedAt != nil,
+			SuspendedAt:    u.suspendedAt,
+			FollowersCount: u.followersCount,
+			FolloweesCount: u.followeesCount,
+		}
+	}
+
+	return uu, nil
+}
+
+//SetUserSuspended implementa service.AdminStore.
+func (s *Store) SetUserSuspended(ctx context.Context, userID int64, suspendedAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	u.suspendedAt = suspendedAt
+	return nil
+}
+
+//UserSuspended implementa service.AdminStore.
+func (s *Store) UserSuspended(ctx context.Context, userID int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return false, service.ErrUserNotFound
+	}
+
+	return u.suspendedAt != nil, nil
+}
+
+//DeleteUser implementa service.AdminStore.
+func (s *Store) DeleteUser(ctx context.Context, userID int64, deletedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	for f := range s.follows {
+		switch userID {
+		case f.followerID:
+			if followee, ok := s.users[f.followeeID]; ok {
+				followee.followersCount--
+			}
+		case f.followeeID:
+			if follower, ok := s.users[f.followerID]; ok {
+				follower.followeesCount--
+			}
+		default:
+			continue
+		}
+
+		delete(s.follows, f)
+	}
+
+	for h, t := range s.refreshTokens {
+		if t.UserID == userID {
+			delete(s.refreshTokens, h)
+		}
+	}
+
+	for id, sess := range s.sessions {
+		if sess.UserID == userID {
+			delete(s.sessions, id)
+		}
+	}
+
+	for h, t := range s.passwordResetTokens {
+		if t.UserID == userID {
+			delete(s.passwordResetTokens, h)
+		}
+	}
+
+	for h, t := range s.emailVerificationTokens {
+		if t.UserID == userID {
+			delete(s.emailVerificationTokens, h)
+		}
+	}
+
+	for h, c := range s.twoFactorChallenges {
+		if c.UserID == userID {
+			delete(s.twoFactorChallenges, h)
+		}
+	}
+
+	for id, t := range s.personalAccessTokens {
+		if t.UserID == userID {
+			delete(s.personalAccessTokensByHash, t.Hash)
+			delete(s.personalAccessTokens, id)
+		}
+	}
+
+	for id, c := range s.oauthClients {
+		if c.OwnerID == userID {
+			s.deleteOAuthClient(id)
+		}
+	}
+
+	for k := range s.oauthConsents {
+		if k.userID == userID {
+			delete(s.oauthConsents, k)
+		}
+	}
+
+	for h, c := range s.oauthCodes {
+		if c.UserID == userID {
+			delete(s.oauthCodes, h)
+		}
+	}
+
+	for h, t := range s.oauthRefreshTokens {
+		if t.UserID == userID {
+			delete(s.oauthRefreshTokens, h)
+		}
+	}
+
+	for k, i := range s.identities {
+		if i.UserID == userID {
+			delete(s.identities, k)
+		}
+	}
+
+	for h, t := range s.emailChangeTokens {
+		if t.UserID == userID {
+			delete(s.emailChangeTokens, h)
+		}
+	}
+
+	for id, e := range s.dataExports {
+		if e.UserID == userID {
+			delete(s.dataExports, id)
+		}
+	}
+
+	for h, d := range s.dataExportDownloads {
+		if d.UserID == userID {
+			delete(s.dataExportDownloads, h)
+		}
+	}
+
+	//el username actual queda reservado como si lo hubiera cambiado
+	s.usernameHistory = append(s.usernameHistory, usernameChange{
+		userID:    userID,
+		username:  u.username,
+		changedAt: deletedAt,
+	})
+
+	s.deleteRecoveryCodes(userID)
+	delete(s.passwordResetRequests, fold(u.email))
+	delete(s.totps, userID)
+	delete(s.tokenRevocations, userID)
+	delete(s.byEmail, fold(u.email))
+	delete(s.byUsername, fold(u.username))
+	delete(s.users, userID)
+	return nil
+}
+
+//RecordAudit implementa service.AuditStore.
+func (s *Store) RecordAudit(ctx context.Context, e service.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastAuditID++
+	e.ID = s.lastAuditID
+	s.auditLog = append(s.auditLog, e)
+	return nil
+}
+
+//AuditLog implementa service.AuditStore.
+func (s *Store) AuditLog(ctx context.Context, first int, before int64) ([]service.AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ee := []service.AuditEntry{}
+	for i := len(s.auditLog) - 1; i >= 0 && len(ee) < first; i-- {
+		if before != 0 && s.auditLog[i].ID >= before {
+			continue
+		}
+
+		ee = append(ee, s.auditLog[i])
+	}
+
+	return ee, nil
+}
@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//Credentials implementa service.CredentialStore.
+func (s *Store) Credentials(ctx context.Context, email string) (service.User, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byEmail[fold(email)]
+	if !ok {
+		return service.User{}, "", service.ErrUserNotFound
+	}
+
+	u := s.users[id]
+	return service.User{ID: u.id, Username: u.username, Avatar: u.avatar}, u.password, nil
+}
@@ -0,0 +1,175 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateDataExport implementa service.DataExportStore.
+func (s *Store) CreateDataExport(ctx context.Context, e service.DataExport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.dataExports[e.ID] = &e
+	return nil
+}
+
+//DataExport implementa service.DataExportStore.
+func (s *Store) DataExport(ctx context.Context, id string) (service.DataExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, ok := s.dataExports[id]
+	if !ok {
+		return service.DataExport{}, service.ErrDataExportNotFound
+	}
+
+	return *e, nil
+}
+
+//DataExports implementa service.DataExportStore.
+func (s *Store) DataExports(ctx context.Context, userID int64) ([]service.DataExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ee []service.DataExport
+	for _, e := range s.dataExports {
+		if e.UserID == userID {
+			ee = append(ee, *e)
+		}
+	}
+
+	sort.Slice(ee, func(i, j int) bool {
+		return ee[i].CreatedAt.Before(ee[j].CreatedAt)
+	})
+
+	return ee, nil
+}
+
+//ActiveDataExport implementa service.DataExportStore.
+func (s *Store) ActiveDataExport(ctx context.Context, userID int64) (service.DataExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, e := range s.dataExports {
+		if e.UserID == userID && (e.Status == service.DataExportPending || e.Status == service.DataExportRunning) {
+			return *e, nil
+		}
+	}
+
+	return service.DataExport{}, service.ErrDataExportNotFound
+}
+
+//CountDataExports implementa service.DataExportStore.
+func (s *Store) CountDataExports(ctx context.Context, userID int64, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, e := range s.dataExports {
+		if e.UserID == userID && !e.CreatedAt.Before(since) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+//ClaimDataExport implementa service.DataExportStore.
+func (s *Store) ClaimDataExport(ctx context.Context, staleBefore, now time.Time) (service.DataExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *service.DataExport
+	for _, e := range s.dataExports {
+		claimable := e.Status == service.DataExportPending ||
+			e.Status == service.DataExportRunning && e.StartedAt.Before(staleBefore)
+		if claimable && (next == nil || e.CreatedAt.Before(next.CreatedAt)) {
+			next = e
+		}
+	}
+
+	if next == nil {
+		return service.DataExport{}, service.ErrDataExportNotFound
+	}
+
+	next.Status = service.DataExportRunning
+	next.StartedAt = &now
+	return *next, nil
+}
+
+//FinishDataExport implementa service.DataExportStore.
+func (s *Store) FinishDataExport(ctx context.Context, id, status string, finishedAt time.Time, expiresAt *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.dataExports[id]
+	if !ok {
+		return service.ErrDataExportNotFound
+	}
+
+	e.Status = status
+	e.FinishedAt = &finishedAt
+	e.ExpiresAt = expiresAt
+	for h, d := range s.dataExportDownloads {
+		if d.ExportID == id {
+			delete(s.dataExportDownloads, h)
+		}
+	}
+
+	return nil
+}
+
+//CreateDataExportDownload implementa service.DataExportStore.
+func (s *Store) CreateDataExportDownload(ctx context.Context, d service.DataExportDownload, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for h, old := range s.dataExportDownloads {
+		if old.ExportID == d.ExportID && old.ExpiresAt.Before(now) {
+			delete(s.dataExportDownloads, h)
+		}
+	}
+
+	s.dataExportDownloads[d.TokenHash] = d
+	return nil
+}
+
+//DataExportDownload implementa service.DataExportStore.
+func (s *Store) DataExportDownload(ctx context.Context, tokenHash string) (service.DataExportDownload, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	d, ok := s.dataExportDownloads[tokenHash]
+	if !ok {
+		return d, service.ErrInvalidDownloadToken
+	}
+
+	return d, nil
+}
+
+//ExpiredDataExports implementa service.DataExportStore.
+func (s *Store) ExpiredDataExports(ctx context.Context, now time.Time, limit int) ([]service.DataExport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ee []service.DataExport
+	for _, e := range s.dataExports {
+		if e.Status == service.DataExportReady && e.ExpiresAt != nil && !e.ExpiresAt.After(now) {
+			ee = append(ee, *e)
+		}
+	}
+
+	sort.Slice(ee, func(i, j int) bool {
+		return ee[i].ExpiresAt.Before(*ee[j].ExpiresAt)
+	})
+
+	if len(ee) > limit {
+		ee = ee[:limit]
+	}
+
+	return ee, nil
+}
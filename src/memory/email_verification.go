@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateEmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) CreateEmailVerificationToken(ctx context.Context, t service.EmailVerificationToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emailVerificationTokens[t.Hash] = &t
+	return nil
+}
+
+//EmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) EmailVerificationToken(ctx context.Context, hash string) (service.EmailVerificationToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.emailVerificationTokens[hash]
+	if !ok {
+		return service.EmailVerificationToken{}, service.ErrInvalidVerificationToken
+	}
+
+	return *t, nil
+}
+
+//UseEmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) UseEmailVerificationToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.emailVerificationTokens[hash]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+
+	t.UsedAt = &usedAt
+	return true, nil
+}
+
+//CountEmailVerificationTokens implementa service.EmailVerificationStore.
+func (s *Store) CountEmailVerificationTokens(ctx context.Context, userID int64, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, t := range s.emailVerificationTokens {
+		if t.UserID == userID && t.CreatedAt.After(since) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+//EmailStatus implementa service.EmailVerificationStore.
+func (s *Store) EmailStatus(ctx context.Context, userID int64) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", false, service.ErrUserNotFound
+	}
+
+	return u.email, u.emailVerifiedAt != nil, nil
+}
+
+//MarkEmailVerified implementa service.EmailVerificationStore.
+func (s *Store) MarkEmailVerified(ctx context.Context, userID int64, email string, at time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || fold(u.email) != fold(email) {
+		return false, nil
+	}
+
+	u.emailVerifiedAt = &at
+	return true, nil
+}
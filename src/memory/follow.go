@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SetFollow implementa service.FollowStore.
+func (s *Store) SetFollow(ctx context.Context, followerID, followeeID int64, op service.FollowOp) (service.ToggleFollowOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out service.ToggleFollowOutput
+	follower, ok := s.users[followerID]
+	if !ok {
+		return out, service.ErrUserNotFound
+	}
+
+	followee, ok := s.users[followeeID]
+	if !ok {
+		return out, service.ErrUserNotFound
+	}
+
+	f := follow{followerID: followerID, followeeID: followeeID}
+	following := s.follows[f]
+	switch {
+	case following && op != service.FollowAdd:
+		delete(s.follows, f)
+		follower.followeesCount--
+		followee.followersCount--
+	case !following && op != service.FollowRemove:
+		s.follows[f] = true
+		follower.followeesCount++
+		followee.followersCount++
+	}
+
+	out.Following = s.follows[f]
+	out.FollowersCount = followee.followersCount
+	return out, nil
+}
+
+//Followers implementa service.FollowStore.
+func (s *Store) Followers(ctx context.Context, viewerID, userID int64, first int, after string) ([]service.UserProfile, error) {
+	return s.followProfiles(viewerID, first, after, func(f follow) int64 {
+		if f.followeeID == userID {
+			return f.followerID
+		}
+
+		return 0
+	}), nil
+}
+
+//Followees implementa service.FollowStore.
+func (s *Store) Followees(ctx context.Context, viewerID, userID int64, first int, after string) ([]service.UserProfile, error) {
+	return s.followProfiles(viewerID, first, after, func(f follow) int64 {
+		if f.followerID == userID {
+			return f.followeeID
+		}
+
+		return 0
+	}), nil
+}
+
+//followProfiles pagina como Users los perfiles de los usuarios cuyo id
+//devuelve other para cada follow; other devuelve 0 para saltarse el follow.
+func (s *Store) followProfiles(viewerID int64, first int, after string, other func(follow) int64) []service.UserProfile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	after = fold(after)
+
+	var matches []*user
+	for f := range s.follows {
+		u, ok := s.users[other(f)]
+		if !ok || after != "" && fold(u.username) <= after {
+			continue
+		}
+
+		matches = append(matches, u)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return fold(matches[i].username) < fold(matches[j].username)
+	})
+
+	if len(matches) > first {
+		matches = matches[:first]
+	}
+
+	uu := make([]service.UserProfile, len(matches))
+	for i, u := range matches {
+		uu[i] = s.profile(viewerID, u)
+	}
+
+	return uu
+}
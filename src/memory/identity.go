@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateOIDCLogin implementa service.IdentityStore.
+func (s *Store) CreateOIDCLogin(ctx context.Context, l service.OIDCLogin) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.oidcLogins[l.Hash] = &l
+	return nil
+}
+
+//OIDCLogin implementa service.IdentityStore.
+func (s *Store) OIDCLogin(ctx context.Context, hash string) (service.OIDCLogin, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	l, ok := s.oidcLogins[hash]
+	if !ok {
+		return service.OIDCLogin{}, service.ErrInvalidOIDCState
+	}
+
+	return *l, nil
+}
+
+//UseOIDCLogin implementa service.IdentityStore.
+func (s *Store) UseOIDCLogin(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, ok := s.oidcLogins[hash]
+	if !ok || l.UsedAt != nil {
+		return false, nil
+	}
+
+	l.UsedAt = &usedAt
+	return true, nil
+}
+
+//IdentityUserID implementa service.IdentityStore.
+func (s *Store) IdentityUserID(ctx context.Context, provider, subject string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.identities[identityKey{provider: provider, subject: subject}]
+	if !ok {
+		return 0, service.ErrIdentityNotFound
+	}
+
+	return i.UserID, nil
+}
+
+//LinkIdentity implementa service.IdentityStore.
+func (s *Store) LinkIdentity(ctx context.Context, i service.Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.identities[identityKey{provider: i.Provider, subject: i.Subject}] = &i
+	return nil
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SetAvatar implementa service.ImageStore.
+func (s *Store) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", service.ErrUserNotFound
+	}
+
+	old := u.avatar
+	u.avatar = key
+	return old, nil
+}
+
+//SetCover implementa service.ImageStore.
+func (s *Store) SetCover(ctx context.Context, userID int64, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", service.ErrUserNotFound
+	}
+
+	old := u.cover
+	u.cover = key
+	return old, nil
+}
+
+//UserImages implementa service.ImageStore.
+func (s *Store) UserImages(ctx context.Context, userID int64) (string, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", "", service.ErrUserNotFound
+	}
+
+	return u.avatar, u.cover, nil
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//RecordLoginFailure implementa service.LoginAttemptStore.
+func (s *Store) RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (service.LoginFailures, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key = fold(key)
+	f, ok := s.loginFailures[key]
+	if !ok || f.LastFailureAt.Before(at.Add(-window)) {
+		f = &service.LoginFailures{Key: key}
+		s.loginFailures[key] = f
+	}
+
+	f.Count++
+	f.LastFailureAt = at
+	return *f, nil
+}
+
+//LoginFailures implementa service.LoginAttemptStore.
+func (s *Store) LoginFailures(ctx context.Context, key string) (service.LoginFailures, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.loginFailures[fold(key)]
+	if !ok {
+		return service.LoginFailures{Key: key}, nil
+	}
+
+	return *f, nil
+}
+
+//ClearLoginFailures implementa service.LoginAttemptStore.
+func (s *Store) ClearLoginFailures(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, fold(key))
+	return nil
+}
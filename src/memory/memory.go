@@ -0,0 +1,162 @@
+package memory
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//Store implementa service.Store en memoria. Sirve para correr la API sin un
+//servidor mysql; los datos se pierden al reiniciar.
+type Store struct {
+	mu sync.RWMutex
+
+	lastUserID int64
+	users      map[int64]*user
+	byEmail    map[string]int64
+	byUsername map[string]int64
+	follows    map[follow]bool
+
+	refreshTokens    map[string]*service.RefreshToken
+	revokedTokens    map[string]time.Time
+	tokenRevocations map[int64]time.Time
+	sessions         map[string]*service.Session
+
+	passwordResetTokens   map[string]*service.PasswordResetToken
+	passwordResetRequests map[string][]time.Time
+
+	emailVerificationTokens map[string]*service.EmailVerificationToken
+
+	totps               map[int64]*service.TOTP
+	recoveryCodes       map[string]*recoveryCode
+	twoFactorChallenges map[string]*service.TwoFactorChallenge
+
+	loginFailures map[string]*service.LoginFailures
+
+	personalAccessTokens       map[string]*service.PersonalAccessToken
+	personalAccessTokensByHash map[string]string
+
+	lastAuditID int64
+	auditLog    []service.AuditEntry
+
+	oauthClients       map[string]*service.OAuthClient
+	oauthConsents      map[oauthConsentKey]*service.OAuthConsent
+	oauthCodes         map[string]*service.OAuthCode
+	oauthRefreshTokens map[string]*service.OAuthRefreshToken
+
+	oidcLogins map[string]*service.OIDCLogin
+	identities map[identityKey]*service.Identity
+
+	emailChangeTokens map[string]*service.EmailChangeToken
+
+	usernameHistory []usernameChange
+
+	dataExports         map[string]*service.DataExport
+	dataExportDownloads map[string]service.DataExportDownload
+}
+
+var _ service.Store = (*Store)(nil)
+
+//user es la fila de la tabla user.
+type user struct {
+	id             int64
+	email          string
+	username       string
+	password       string
+	followersCount int
+	followeesCount int
+
+	displayName string
+	bio         string
+	location    string
+	website     string
+	birthday    string
+	avatar      string
+	cover       string
+
+	emailVerifiedAt *time.Time
+	suspendedAt     *time.Time
+	deleteAt        *time.Time
+	roles           []string
+}
+
+//recoveryCode es la fila de la tabla recovery_codes.
+type recoveryCode struct {
+	userID int64
+	usedAt *time.Time
+}
+
+//oauthConsentKey es la llave primaria de la tabla oauth_consents.
+type oauthConsentKey struct {
+	userID   int64
+	clientID string
+}
+
+//identityKey es la llave primaria de la tabla user_identities.
+type identityKey struct {
+	provider string
+	subject  string
+}
+
+//usernameChange es la fila de la tabla username_history.
+type usernameChange struct {
+	userID    int64
+	username  string
+	changedAt time.Time
+}
+
+//follow es la fila de la tabla follows.
+type follow struct {
+	followerID int64
+	followeeID int64
+}
+
+//New crea un almacenamiento vacío.
+func New() *Store {
+	return &Store{
+		users:      make(map[int64]*user),
+		byEmail:    make(map[string]int64),
+		byUsername: make(map[string]int64),
+		follows:    make(map[follow]bool),
+
+		refreshTokens:    make(map[string]*service.RefreshToken),
+		revokedTokens:    make(map[string]time.Time),
+		tokenRevocations: make(map[int64]time.Time),
+		sessions:         make(map[string]*service.Session),
+
+		passwordResetTokens:   make(map[string]*service.PasswordResetToken),
+		passwordResetRequests: make(map[string][]time.Time),
+
+		emailVerificationTokens: make(map[string]*service.EmailVerificationToken),
+
+		totps:               make(map[int64]*service.TOTP),
+		recoveryCodes:       make(map[string]*recoveryCode),
+		twoFactorChallenges: make(map[string]*service.TwoFactorChallenge),
+
+		loginFailures: make(map[string]*service.LoginFailures),
+
+		personalAccessTokens:       make(map[string]*service.PersonalAccessToken),
+		personalAccessTokensByHash: make(map[string]string),
+
+		oauthClients:       make(map[string]*service.OAuthClient),
+		oauthConsents:      make(map[oauthConsentKey]*service.OAuthConsent),
+		oauthCodes:         make(map[string]*service.OAuthCode),
+		oauthRefreshTokens: make(map[string]*service.OAuthRefreshToken),
+
+		oidcLogins: make(map[string]*service.OIDCLogin),
+		identities: make(map[identityKey]*service.Identity),
+
+		emailChangeTokens: make(map[string]*service.EmailChangeToken),
+
+		dataExports:         make(map[string]*service.DataExport),
+		dataExportDownloads: make(map[string]service.DataExportDownload),
+	}
+}
+
+//fold normaliza las llaves únicas como lo hace la colación de mysql, que no
+//distingue mayúsculas.
+func fold(s string) string {
+	return strings.ToLower(s)
+}
@@ -0,0 +1,251 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateOAuthClient implementa service.OAuthStore.
+func (s *Store) CreateOAuthClient(ctx context.Context, c service.OAuthClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c.RedirectURIs = append([]string{}, c.RedirectURIs...)
+	s.oauthClients[c.ID] = &c
+	return nil
+}
+
+//OAuthClient implementa service.OAuthStore.
+func (s *Store) OAuthClient(ctx context.Context, id string) (service.OAuthClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.oauthClients[id]
+	if !ok {
+		return service.OAuthClient{}, service.ErrOAuthClientNotFound
+	}
+
+	return *c, nil
+}
+
+//OAuthClients implementa service.OAuthStore.
+func (s *Store) OAuthClients(ctx context.Context, ownerID int64) ([]service.OAuthClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cc := []service.OAuthClient{}
+	for _, c := range s.oauthClients {
+		if c.OwnerID == ownerID {
+			cc = append(cc, *c)
+		}
+	}
+
+	sort.Slice(cc, func(i, j int) bool {
+		return cc[i].CreatedAt.After(cc[j].CreatedAt)
+	})
+
+	return cc, nil
+}
+
+//DeleteOAuthClient implementa service.OAuthStore.
+func (s *Store) DeleteOAuthClient(ctx context.Context, ownerID int64, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.oauthClients[id]
+	if !ok || c.OwnerID != ownerID {
+		return false, nil
+	}
+
+	s.deleteOAuthClient(id)
+	return true, nil
+}
+
+//deleteOAuthClient borra la aplicación y todo lo que se le emitió. Se llama
+//con s.mu tomado.
+func (s *Store) deleteOAuthClient(id string) {
+	for k := range s.oauthConsents {
+		if k.clientID == id {
+			delete(s.oauthConsents, k)
+		}
+	}
+
+	for h, c := range s.oauthCodes {
+		if c.ClientID == id {
+			delete(s.oauthCodes, h)
+		}
+	}
+
+	for h, t := range s.oauthRefreshTokens {
+		if t.ClientID == id {
+			delete(s.oauthRefreshTokens, h)
+		}
+	}
+
+	delete(s.oauthClients, id)
+}
+
+//SaveOAuthConsent implementa service.OAuthStore.
+func (s *Store) SaveOAuthConsent(ctx context.Context, c service.OAuthConsent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := oauthConsentKey{userID: c.UserID, clientID: c.ClientID}
+	if prev, ok := s.oauthConsents[k]; ok {
+		c.CreatedAt = prev.CreatedAt
+	}
+
+	c.Scopes = append([]string{}, c.Scopes...)
+	s.oauthConsents[k] = &c
+	return nil
+}
+
+//OAuthConsent implementa service.OAuthStore.
+func (s *Store) OAuthConsent(ctx context.Context, userID int64, clientID string) (service.OAuthConsent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.oauthConsents[oauthConsentKey{userID: userID, clientID: clientID}]
+	client, exists := s.oauthClients[clientID]
+	if !ok || !exists {
+		return service.OAuthConsent{}, service.ErrOAuthConsentNotFound
+	}
+
+	out := *c
+	out.ClientName = client.Name
+	return out, nil
+}
+
+//OAuthConsents implementa service.OAuthStore.
+func (s *Store) OAuthConsents(ctx context.Context, userID int64) ([]service.OAuthConsent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cc := []service.OAuthConsent{}
+	for k, c := range s.oauthConsents {
+		client, ok := s.oauthClients[k.clientID]
+		if k.userID != userID || !ok {
+			continue
+		}
+
+		out := *c
+		out.ClientName = client.Name
+		cc = append(cc, out)
+	}
+
+	sort.Slice(cc, func(i, j int) bool {
+		return cc[i].UpdatedAt.After(cc[j].UpdatedAt)
+	})
+
+	return cc, nil
+}
+
+//DeleteOAuthConsent implementa service.OAuthStore.
+func (s *Store) DeleteOAuthConsent(ctx context.Context, userID int64, clientID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := oauthConsentKey{userID: userID, clientID: clientID}
+	if _, ok := s.oauthConsents[k]; !ok {
+		return false, nil
+	}
+
+	for h, t := range s.oauthRefreshTokens {
+		if t.UserID == userID && t.ClientID == clientID {
+			delete(s.oauthRefreshTokens, h)
+		}
+	}
+
+	delete(s.oauthConsents, k)
+	return true, nil
+}
+
+//CreateOAuthCode implementa service.OAuthStore.
+func (s *Store) CreateOAuthCode(ctx context.Context, c service.OAuthCode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.oauthCodes[c.Hash] = &c
+	return nil
+}
+
+//OAuthCode implementa service.OAuthStore.
+func (s *Store) OAuthCode(ctx context.Context, hash string) (service.OAuthCode, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.oauthCodes[hash]
+	if !ok {
+		return service.OAuthCode{}, service.ErrOAuthCodeNotFound
+	}
+
+	return *c, nil
+}
+
+//UseOAuthCode implementa service.OAuthStore.
+func (s *Store) UseOAuthCode(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.oauthCodes[hash]
+	if !ok || c.UsedAt != nil {
+		return false, nil
+	}
+
+	c.UsedAt = &usedAt
+	return true, nil
+}
+
+//CreateOAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) CreateOAuthRefreshToken(ctx context.Context, t service.OAuthRefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.oauthRefreshTokens[t.Hash] = &t
+	return nil
+}
+
+//OAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) OAuthRefreshToken(ctx context.Context, hash string) (service.OAuthRefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.oauthRefreshTokens[hash]
+	if !ok {
+		return service.OAuthRefreshToken{}, service.ErrOAuthRefreshTokenNotFound
+	}
+
+	return *t, nil
+}
+
+//RevokeOAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) RevokeOAuthRefreshToken(ctx context.Context, hash string, revokedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.oauthRefreshTokens[hash]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+
+	t.RevokedAt = &revokedAt
+	return true, nil
+}
+
+//RevokeOAuthRefreshTokens implementa service.OAuthStore.
+func (s *Store) RevokeOAuthRefreshTokens(ctx context.Context, userID int64, clientID string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.oauthRefreshTokens {
+		if t.UserID == userID && t.ClientID == clientID && t.RevokedAt == nil {
+			at := revokedAt
+			t.RevokedAt = &at
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreatePasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) CreatePasswordResetToken(ctx context.Context, t service.PasswordResetToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.passwordResetTokens[t.Hash] = &t
+	return nil
+}
+
+//PasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) PasswordResetToken(ctx context.Context, hash string) (service.PasswordResetToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.passwordResetTokens[hash]
+	if !ok {
+		return service.PasswordResetToken{}, service.ErrInvalidResetToken
+	}
+
+	return *t, nil
+}
+
+//UsePasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) UsePasswordResetToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.passwordResetTokens[hash]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+
+	t.UsedAt = &usedAt
+	return true, nil
+}
+
+//RecordPasswordResetRequest implementa service.PasswordResetStore.
+func (s *Store) RecordPasswordResetRequest(ctx context.Context, email string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email = fold(email)
+	s.passwordResetRequests[email] = append(s.passwordResetRequests[email], at)
+	return nil
+}
+
+//CountPasswordResetRequests implementa service.PasswordResetStore.
+func (s *Store) CountPasswordResetRequests(ctx context.Context, email string, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, at := range s.passwordResetRequests[fold(email)] {
+		if at.After(since) {
+			n++
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreatePersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, t service.PersonalAccessToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.personalAccessTokens[t.ID] = &t
+	s.personalAccessTokensByHash[t.Hash] = t.ID
+	return nil
+}
+
+//PersonalAccessTokenByHash implementa service.PersonalAccessTokenStore.
+func (s *Store) PersonalAccessTokenByHash(ctx context.Context, hash string) (service.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.personalAccessTokensByHash[hash]
+	if !ok {
+		return service.PersonalAccessToken{}, service.ErrInvalidPersonalAccessToken
+	}
+
+	return *s.personalAccessTokens[id], nil
+}
+
+//PersonalAccessTokens implementa service.PersonalAccessTokenStore.
+func (s *Store) PersonalAccessTokens(ctx context.Context, userID int64) ([]service.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tt := []service.PersonalAccessToken{}
+	for _, t := range s.personalAccessTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			tt = append(tt, *t)
+		}
+	}
+
+	sort.Slice(tt, func(i, j int) bool { return tt[i].CreatedAt.After(tt[j].CreatedAt) })
+	return tt, nil
+}
+
+//TouchPersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) TouchPersonalAccessToken(ctx context.Context, id string, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if t, ok := s.personalAccessTokens[id]; ok {
+		t.LastUsedAt = &usedAt
+	}
+
+	return nil
+}
+
+//RevokePersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userID int64, id string, revokedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.personalAccessTokens[id]
+	if !ok || t.UserID != userID || t.RevokedAt != nil {
+		return false, nil
+	}
+
+	t.RevokedAt = &revokedAt
+	return true, nil
+}
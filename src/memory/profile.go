@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//UpdateProfile implementa service.ProfileStore.
+func (s *Store) UpdateProfile(ctx context.Context, userID int64, c service.ProfileChanges) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	for _, f := range []struct {
+		field *string
+		value *string
+	}{
+		{&u.displayName, c.DisplayName},
+		{&u.bio, c.Bio},
+		{&u.location, c.Location},
+		{&u.website, c.Website},
+		{&u.birthday, c.Birthday},
+		{&u.password, c.PasswordHash},
+	} {
+		if f.value != nil {
+			*f.field = *f.value
+		}
+	}
+
+	if c.PasswordHash != nil {
+		s.useAccountTokens(userID, c.UpdatedAt)
+	}
+
+	return nil
+}
+
+//CreateEmailChangeToken implementa service.ProfileStore.
+func (s *Store) CreateEmailChangeToken(ctx context.Context, t service.EmailChangeToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.emailChangeTokens[t.Hash] = &t
+	return nil
+}
+
+//EmailChangeToken implementa service.ProfileStore.
+func (s *Store) EmailChangeToken(ctx context.Context, hash string) (service.EmailChangeToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.emailChangeTokens[hash]
+	if !ok {
+		return service.EmailChangeToken{}, service.ErrInvalidEmailChangeToken
+	}
+
+	return *t, nil
+}
+
+//CountEmailChangeTokens implementa service.ProfileStore.
+func (s *Store) CountEmailChangeTokens(ctx context.Context, userID int64, since time.Time) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	n := 0
+	for _, t := range s.emailChangeTokens {
+		if t.UserID == userID && t.CreatedAt.After(since) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+//ConfirmEmailChange implementa service.ProfileStore.
+func (s *Store) ConfirmEmailChange(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.emailChangeTokens[hash]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+
+	u, ok := s.users[t.UserID]
+	if !ok {
+		return false, service.ErrUserNotFound
+	}
+
+	if id, taken := s.byEmail[fold(t.Email)]; taken && id != t.UserID {
+		return false, service.ErrEmailTaken
+	}
+
+	t.UsedAt = &usedAt
+	delete(s.byEmail, fold(u.email))
+	s.byEmail[fold(t.Email)] = t.UserID
+	u.email = t.Email
+	u.emailVerifiedAt = &usedAt
+	s.useAccountTokens(t.UserID, usedAt)
+	return true, nil
+}
+
+//useAccountTokens marca como usados en usedAt los tokens de recuperación y
+//de cambio de email pendientes del usuario. Se llama con s.mu tomado.
+func (s *Store) useAccountTokens(userID int64, usedAt time.Time) {
+	for _, t := range s.passwordResetTokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &usedAt
+		}
+	}
+
+	for _, t := range s.emailChangeTokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &usedAt
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+//RevokeToken implementa service.RevocationStore.
+func (s *Store) RevokeToken(ctx context.Context, userID int64, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revokedTokens[tokenID] = expiresAt
+	return nil
+}
+
+//RevokeUserTokens implementa service.RevocationStore.
+func (s *Store) RevokeUserTokens(ctx context.Context, userID int64, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenRevocations[userID] = before
+	return nil
+}
+
+//TokenRevoked implementa service.RevocationStore.
+func (s *Store) TokenRevoked(ctx context.Context, userID int64, tokenID string, issuedAt time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.revokedTokens[tokenID]; ok {
+		return true, nil
+	}
+
+	before, ok := s.tokenRevocations[userID]
+	return ok && !issuedAt.After(before), nil
+}
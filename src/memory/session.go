@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateSession implementa service.SessionStore.
+func (s *Store) CreateSession(ctx context.Context, sess service.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sess.ID] = &sess
+	return nil
+}
+
+//Session implementa service.SessionStore.
+func (s *Store) Session(ctx context.Context, id string) (service.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return service.Session{}, service.ErrSessionNotFound
+	}
+
+	return *sess, nil
+}
+
+//Sessions implementa service.SessionStore.
+func (s *Store) Sessions(ctx context.Context, userID int64, now time.Time) ([]service.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ss []service.Session
+	for _, sess := range s.sessions {
+		if sess.UserID == userID && sess.RevokedAt == nil && sess.ExpiresAt.After(now) {
+			ss = append(ss, *sess)
+		}
+	}
+
+	sort.Slice(ss, func(i, j int) bool { return ss[i].LastSeenAt.After(ss[j].LastSeenAt) })
+	return ss, nil
+}
+
+//TouchSession implementa service.SessionStore.
+func (s *Store) TouchSession(ctx context.Context, id string, seenAt time.Time, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[id]; ok {
+		sess.LastSeenAt = seenAt
+		sess.IP = ip
+	}
+
+	return nil
+}
+
+//ExtendSession implementa service.SessionStore.
+func (s *Store) ExtendSession(ctx context.Context, id string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[id]; ok {
+		sess.ExpiresAt = expiresAt
+	}
+
+	return nil
+}
+
+//RevokeSession implementa service.SessionStore.
+func (s *Store) RevokeSession(ctx context.Context, id string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sess, ok := s.sessions[id]; ok && sess.RevokedAt == nil {
+		sess.RevokedAt = &revokedAt
+	}
+
+	return nil
+}
+
+//RevokeUserSessions implementa service.SessionStore.
+func (s *Store) RevokeUserSessions(ctx context.Context, userID int64, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sess := range s.sessions {
+		if sess.UserID == userID && sess.RevokedAt == nil {
+			sess.RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateRefreshToken implementa service.RefreshTokenStore.
+func (s *Store) CreateRefreshToken(ctx context.Context, t service.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refreshTokens[t.Hash] = &t
+	return nil
+}
+
+//RefreshToken implementa service.RefreshTokenStore.
+func (s *Store) RefreshToken(ctx context.Context, hash string) (service.RefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.refreshTokens[hash]
+	if !ok {
+		return service.RefreshToken{}, service.ErrInvalidRefreshToken
+	}
+
+	return *t, nil
+}
+
+//UseRefreshToken implementa service.RefreshTokenStore.
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refreshTokens[hash]
+	if !ok || t.UsedAt != nil {
+		return false, nil
+	}
+
+	t.UsedAt = &usedAt
+	return true, nil
+}
+
+//RevokeRefreshTokenFamily implementa service.RefreshTokenStore.
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refreshTokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
+
+//RevokeUserRefreshTokens implementa service.RefreshTokenStore.
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int64, revokedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &revokedAt
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SaveTOTP implementa service.TwoFactorStore.
+func (s *Store) SaveTOTP(ctx context.Context, t service.TOTP) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totps[t.UserID] = &t
+	return nil
+}
+
+//TOTP implementa service.TwoFactorStore.
+func (s *Store) TOTP(ctx context.Context, userID int64) (service.TOTP, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.totps[userID]
+	if !ok {
+		return service.TOTP{}, service.ErrTwoFactorNotEnabled
+	}
+
+	return *t, nil
+}
+
+//UseTOTPStep implementa service.TwoFactorStore.
+func (s *Store) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.totps[userID]
+	if !ok || t.LastStep >= step {
+		return false, nil
+	}
+
+	t.LastStep = step
+	return true, nil
+}
+
+//DeleteTOTP implementa service.TwoFactorStore.
+func (s *Store) DeleteTOTP(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.totps, userID)
+	s.deleteRecoveryCodes(userID)
+	return nil
+}
+
+//ReplaceRecoveryCodes implementa service.TwoFactorStore.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteRecoveryCodes(userID)
+	for _, h := range hashes {
+		s.recoveryCodes[h] = &recoveryCode{userID: userID}
+	}
+
+	return nil
+}
+
+//UseRecoveryCode implementa service.TwoFactorStore.
+func (s *Store) UseRecoveryCode(ctx context.Context, userID int64, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.recoveryCodes[hash]
+	if !ok || c.userID != userID || c.usedAt != nil {
+		return false, nil
+	}
+
+	c.usedAt = &usedAt
+	return true, nil
+}
+
+//CreateTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) CreateTwoFactorChallenge(ctx context.Context, c service.TwoFactorChallenge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.twoFactorChallenges[c.Hash] = &c
+	return nil
+}
+
+//TwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) TwoFactorChallenge(ctx context.Context, hash string) (service.TwoFactorChallenge, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.twoFactorChallenges[hash]
+	if !ok {
+		return service.TwoFactorChallenge{}, service.ErrInvalidTwoFactorChallenge
+	}
+
+	return *c, nil
+}
+
+//AttemptTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) AttemptTwoFactorChallenge(ctx context.Context, hash string, max int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.twoFactorChallenges[hash]
+	if !ok || c.Attempts >= max {
+		return false, nil
+	}
+
+	c.Attempts++
+	return true, nil
+}
+
+//UseTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) UseTwoFactorChallenge(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.twoFactorChallenges[hash]
+	if !ok || c.UsedAt != nil {
+		return false, nil
+	}
+
+	c.UsedAt = &usedAt
+	return true, nil
+}
+
+//deleteRecoveryCodes borra los códigos de respaldo del usuario. Se llama con
+//el candado tomado.
+func (s *Store) deleteRecoveryCodes(userID int64) {
+	for h, c := range s.recoveryCodes {
+		if c.userID == userID {
+			delete(s.recoveryCodes, h)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateUser implementa service.UserStore.
+func (s *Store) CreateUser(ctx context.Context, email, username, passwordHash string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byEmail[fold(email)]; ok {
+		return 0, service.ErrInvalidUser
+	}
+
+	if _, ok := s.byUsername[fold(username)]; ok {
+		return 0, service.ErrInvalidUser
+	}
+
+	s.lastUserID++
+	u := &user{
+		id:       s.lastUserID,
+		email:    email,
+		username: username,
+		password: passwordHash,
+	}
+	s.users[u.id] = u
+	s.byEmail[fold(email)] = u.id
+	s.byUsername[fold(username)] = u.id
+
+	return u.id, nil
+}
+
+//UserByID implementa service.UserStore.
+func (s *Store) UserByID(ctx context.Context, id int64) (service.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return service.User{}, service.ErrUserNotFound
+	}
+
+	return service.User{ID: u.id, Username: u.username, Avatar: u.avatar}, nil
+}
+
+//UserIDByUsername implementa service.UserStore.
+func (s *Store) UserIDByUsername(ctx context.Context, username string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byUsername[fold(username)]
+	if !ok {
+		return 0, service.ErrUserNotFound
+	}
+
+	return id, nil
+}
+
+//UserProfile implementa service.UserStore.
+func (s *Store) UserProfile(ctx context.Context, viewerID int64, username string) (service.UserProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, ok := s.byUsername[fold(username)]
+	if !ok {
+		return service.UserProfile{}, service.ErrUserNotFound
+	}
+
+	return s.profile(viewerID, s.users[id]), nil
+}
+
+//Users implementa service.UserStore.
+func (s *Store) Users(ctx context.Context, viewerID int64, search string, first int, after string) ([]service.UserProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	search = fold(search)
+	after = fold(after)
+
+	matches := make([]*user, 0, len(s.users))
+	for _, u := range s.users {
+		name := fold(u.username)
+		if search != "" && !strings.Contains(name, search) {
+			continue
+		}
+
+		if after != "" && name <= after {
+			continue
+		}
+
+		matches = append(matches, u)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return fold(matches[i].username) < fold(matches[j].username)
+	})
+
+	if len(matches) > first {
+		matches = matches[:first]
+	}
+
+	uu := make([]service.UserProfile, len(matches))
+	for i, u := range matches {
+		uu[i] = s.profile(viewerID, u)
+	}
+
+	return uu, nil
+}
+
+//profile arma el perfil de u visto por viewerID. Se llama con s.mu tomado.
+func (s *Store) profile(viewerID int64, u *user) service.UserProfile {
+	p := service.UserProfile{
+		User:           service.User{ID: u.id, Username: u.username, Avatar: u.avatar},
+		Email:          u.email,
+		FollowersCount: u.followersCount,
+		FolloweesCount: u.followeesCount,
+		DisplayName:    u.displayName,
+		Bio:            u.bio,
+		Location:       u.location,
+		Website:        u.website,
+		Birthday:       u.birthday,
+		Cover:          u.cover,
+	}
+
+	if viewerID != 0 {
+		p.Following = s.follows[follow{followerID: viewerID, followeeID: u.id}]
+		p.Followeed = s.follows[follow{followerID: u.id, followeeID: viewerID}]
+	}
+
+	return p
+}
+
+//UpdatePassword implementa service.UserStore.
+func (s *Store) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	u.password = passwordHash
+	return nil
+}
+
+//ReplacePasswordHash implementa service.UserStore.
+func (s *Store) ReplacePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || u.password != oldHash {
+		return false, nil
+	}
+
+	u.password = newHash
+	return true, nil
+}
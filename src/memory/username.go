@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//ChangeUsername implementa service.UsernameStore.
+func (s *Store) ChangeUsername(ctx context.Context, userID int64, username string, changedAt, cooldownSince, reservedSince time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return service.ErrUserNotFound
+	}
+
+	var last time.Time
+	for _, c := range s.usernameHistory {
+		if c.userID == userID && c.changedAt.After(last) {
+			last = c.changedAt
+		}
+	}
+
+	if last.After(cooldownSince) {
+		return &service.UsernameCooldownError{RetryAfter: last.Sub(cooldownSince)}
+	}
+
+	if id, taken := s.byUsername[fold(username)]; taken && id != userID {
+		return service.ErrUsernameTaken
+	}
+
+	if s.usernameReserved(username, userID, reservedSince) {
+		return service.ErrUsernameReserved
+	}
+
+	s.usernameHistory = append(s.usernameHistory, usernameChange{
+		userID:    userID,
+		username:  u.username,
+		changedAt: changedAt,
+	})
+
+	delete(s.byUsername, fold(u.username))
+	s.byUsername[fold(username)] = userID
+	u.username = username
+	return nil
+}
+
+//UsernameOwner implementa service.UsernameStore.
+func (s *Store) UsernameOwner(ctx context.Context, username string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	//el historial está en orden, el último cambio va al final
+	for i := len(s.usernameHistory) - 1; i >= 0; i-- {
+		if c := s.usernameHistory[i]; fold(c.username) == fold(username) {
+			return c.userID, nil
+		}
+	}
+
+	return 0, service.ErrUserNotFound
+}
+
+//UsernameReserved implementa service.UsernameStore.
+func (s *Store) UsernameReserved(ctx context.Context, username string, exceptID int64, since time.Time) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.usernameReserved(username, exceptID, since), nil
+}
+
+//PurgeUsernameHistory implementa service.UsernameStore.
+func (s *Store) PurgeUsernameHistory(ctx context.Context, before time.Time, limit int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	history := s.usernameHistory[:0]
+	for _, c := range s.usernameHistory {
+		if _, ok := s.users[c.userID]; !ok && n < limit && c.changedAt.Before(before) {
+			n++
+			continue
+		}
+
+		history = append(history, c)
+	}
+	s.usernameHistory = history
+
+	return n, nil
+}
+
+func (s *Store) usernameReserved(username string, exceptID int64, since time.Time) bool {
+	for _, c := range s.usernameHistory {
+		if c.userID != exceptID && fold(c.username) == fold(username) && c.changedAt.After(since) {
+			return true
+		}
+	}
+
+	return false
+}
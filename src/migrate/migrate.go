@@ -0,0 +1,327 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var embedded embed.FS
+
+//lockName es el nombre del candado de mysql que evita que dos instancias
+//migren al mismo tiempo.
+const lockName = "network.schema_migrations"
+
+var (
+	//ErrLocked cuando otra instancia tiene el candado de migraciones.
+	ErrLocked = errors.New("otra instancia está ejecutando migraciones")
+
+	//ErrChecksum cuando una migración aplicada cambió después de aplicarse.
+	ErrChecksum = errors.New("el checksum de una migración aplicada no coincide")
+
+	rxFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+)
+
+//Migration es un cambio versionado del esquema.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+//Checksum identifica el contenido de la migración aplicada.
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+//Status es el estado de una migración en la base de datos.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+//Migrations devuelve las migraciones embebidas en el binario ordenadas por
+//versión.
+func Migrations() ([]Migration, error) {
+	return load(embedded, "migrations")
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron leer las migraciones: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		m := rxFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("nombre de migración inválido: %s", e.Name())
+		}
+
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo leer la migración %s: %v", e.Name(), err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("la versión %d tiene dos nombres: %s y %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mig.Up = string(b)
+		} else {
+			mig.Down = string(b)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("la migración %d_%s no tiene archivo up", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+//Migrator aplica y revierte migraciones sobre una base de datos mysql. La
+//conexión debe aceptar varias sentencias por consulta.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	out        io.Writer
+
+	//DryRun muestra el SQL que se ejecutaría sin tocar la base de datos.
+	DryRun bool
+
+	//LockTimeout es cuánto se espera por el candado de migraciones.
+	LockTimeout time.Duration
+}
+
+//New crea un Migrator con las migraciones embebidas que reporta su avance
+//en out.
+func New(db *sql.DB, out io.Writer) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:          db,
+		migrations:  migrations,
+		out:         out,
+		LockTimeout: 10 * time.Second,
+	}, nil
+}
+
+//Up aplica hasta n migraciones pendientes en orden, todas si n <= 0.
+func (m *Migrator) Up(ctx context.Context, n int) error {
+	return m.run(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		count := 0
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+
+			if n > 0 && count == n {
+				break
+			}
+
+			fmt.Fprintf(m.out, "up %04d_%s\n", mig.Version, mig.Name)
+			if m.DryRun {
+				fmt.Fprintln(m.out, mig.Up)
+				count++
+				continue
+			}
+
+			if _, err := conn.ExecContext(ctx, mig.Up); err != nil {
+				return fmt.Errorf("no se pudo aplicar %04d_%s: %v", mig.Version, mig.Name, err)
+			}
+
+			query := "INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"
+			if _, err := conn.ExecContext(ctx, query, mig.Version, mig.Name, mig.Checksum(), time.Now().UTC()); err != nil {
+				return fmt.Errorf("no se pudo registrar %04d_%s: %v", mig.Version, mig.Name, err)
+			}
+			count++
+		}
+
+		if count == 0 {
+			fmt.Fprintln(m.out, "no hay migraciones pendientes")
+		}
+		return nil
+	})
+}
+
+//Down revierte las últimas n migraciones aplicadas, en orden inverso.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	if n <= 0 {
+		n = 1
+	}
+
+	return m.run(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		count := 0
+		for i := len(m.migrations) - 1; i >= 0 && count < n; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+
+			if mig.Down == "" {
+				return fmt.Errorf("la migración %04d_%s no se puede revertir", mig.Version, mig.Name)
+			}
+
+			fmt.Fprintf(m.out, "down %04d_%s\n", mig.Version, mig.Name)
+			count++
+			if m.DryRun {
+				fmt.Fprintln(m.out, mig.Down)
+				continue
+			}
+
+			if _, err := conn.ExecContext(ctx, mig.Down); err != nil {
+				return fmt.Errorf("no se pudo revertir %04d_%s: %v", mig.Version, mig.Name, err)
+			}
+
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version=?", mig.Version); err != nil {
+				return fmt.Errorf("no se pudo borrar el registro de %04d_%s: %v", mig.Version, mig.Name, err)
+			}
+		}
+
+		if count == 0 {
+			fmt.Fprintln(m.out, "no hay migraciones para revertir")
+		}
+		return nil
+	})
+}
+
+//Status devuelve todas las migraciones conocidas con su fecha de aplicación.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var out []Status
+	err := m.run(ctx, func(conn *sql.Conn, applied map[int64]Status) error {
+		for _, mig := range m.migrations {
+			st := Status{Migration: mig}
+			if a, ok := applied[mig.Version]; ok {
+				st.AppliedAt = a.AppliedAt
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+
+	return out, err
+}
+
+//run toma el candado, lee las migraciones aplicadas, verifica sus checksums
+//y ejecuta fn sobre la misma conexión.
+func (m *Migrator) run(ctx context.Context, fn func(*sql.Conn, map[int64]Status) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("no se pudo obtener una conexión: %v", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	query := "SELECT GET_LOCK(?, ?)"
+	if err = conn.QueryRowContext(ctx, query, lockName, int(m.LockTimeout.Seconds())).Scan(&locked); err != nil {
+		return fmt.Errorf("no se pudo pedir el candado de migraciones: %v", err)
+	}
+
+	if locked.Int64 != 1 {
+		return ErrLocked
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	if !m.DryRun {
+		query = `CREATE TABLE IF NOT EXISTS schema_migrations(
+			version bigint primary key,
+			name varchar(100) not null,
+			checksum char(64) not null,
+			applied_at datetime not null
+		)`
+		if _, err = conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("no se pudo crear la tabla schema_migrations: %v", err)
+		}
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]Status, error) {
+	known := make(map[int64]Migration, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = mig
+	}
+
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'schema_migrations')"
+	if err := conn.QueryRowContext(ctx, query).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("no se pudo revisar la tabla schema_migrations: %v", err)
+	}
+
+	applied := make(map[int64]Status)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron leer las migraciones aplicadas: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var st Status
+		var checksum string
+		var appliedAt time.Time
+		if err = rows.Scan(&st.Version, &st.Name, &checksum, &appliedAt); err != nil {
+			return nil, fmt.Errorf("no se pudo escanear schema_migrations: %v", err)
+		}
+
+		mig, ok := known[st.Version]
+		if !ok {
+			return nil, fmt.Errorf("la migración aplicada %04d_%s no existe en este binario", st.Version, st.Name)
+		}
+
+		if mig.Checksum() != checksum {
+			return nil, fmt.Errorf("%w: %04d_%s", ErrChecksum, st.Version, st.Name)
+		}
+
+		st.Migration = mig
+		st.AppliedAt = &appliedAt
+		applied[st.Version] = st
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("no se pueden iterar las migraciones aplicadas: %v", err)
+	}
+
+	return applied, nil
+}
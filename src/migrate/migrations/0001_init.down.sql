@@ -0,0 +1,4 @@
+DROP PROCEDURE IF EXISTS `subfollowers`;
+DROP PROCEDURE IF EXISTS `addfollowers`;
+DROP TABLE IF EXISTS follows;
+DROP TABLE IF EXISTS user;
@@ -1,14 +1,12 @@
-DROP database IF EXISTS network;
-CREATE DATABASE IF NOT EXISTS network;
-USE network;
-
+-- Esquema inicial, tomado de database.sql. Usa IF NOT EXISTS para poder
+-- adoptar bases de datos creadas antes de tener migraciones.
 CREATE TABLE IF NOT EXISTS user(
 	id int auto_increment primary key,
-    email varchar(50) not null unique, 
+    email varchar(50) not null unique,
     username varchar(50) not null unique,
     password varchar(75) not null,
     followers_count int not null default 0 check(followers_count>=0),
-    followees_count int not null default 0 check(followers_count>=0)
+    followees_count int not null default 0 check(followees_count>=0)
 );
 
 CREATE TABLE IF NOT exists follows(
@@ -17,12 +15,7 @@ CREATE TABLE IF NOT exists follows(
     primary key(follower_id, followee_id)
 );
 
-
-alter table user
-add check (followees_count >= 0)
-add check (followers_count >= 0);
-
-delimiter $
+DROP PROCEDURE IF EXISTS `addfollowers`;
 CREATE PROCEDURE `addfollowers` (
 in _id int
 )
@@ -41,10 +34,9 @@ START TRANSACTION;
 UPDATE user SET followers_count = followers_count + 1 WHERE id = _id;
 SELECT  followers_count FROM user where id = _id;
 COMMIT;
-END $
-
+END;
 
-delimiter $
+DROP PROCEDURE IF EXISTS `subfollowers`;
 CREATE PROCEDURE `subfollowers` (
 in _id int
 )
@@ -63,4 +55,4 @@ START TRANSACTION;
 UPDATE user SET followers_count = followers_count - 1 WHERE id = _id;
 SELECT  followers_count FROM user where id = _id;
 COMMIT;
-END $
\ No newline at end of file
+END;
@@ -0,0 +1,11 @@
+CREATE TABLE refresh_tokens(
+	token_hash char(64) primary key,
+    user_id int not null,
+    family_id varchar(32) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null,
+    revoked_at datetime null,
+    index(family_id),
+    index(user_id)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE revoked_tokens(
+	token_id varchar(32) primary key,
+    user_id int not null,
+    expires_at datetime(3) not null,
+    index(expires_at)
+);
+
+CREATE TABLE token_revocations(
+	user_id int primary key,
+    revoked_before datetime(3) not null
+);
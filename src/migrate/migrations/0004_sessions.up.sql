@@ -0,0 +1,11 @@
+CREATE TABLE sessions(
+	id varchar(32) primary key,
+    user_id int not null,
+    user_agent varchar(255) not null default '',
+    ip varchar(45) not null default '',
+    created_at datetime not null,
+    last_seen_at datetime not null,
+    expires_at datetime not null,
+    revoked_at datetime null,
+    index(user_id)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE password_reset_tokens(
+	token_hash char(64) primary key,
+    user_id int not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null,
+    index(user_id)
+);
+
+CREATE TABLE password_reset_requests(
+	id int auto_increment primary key,
+    email varchar(50) not null,
+    requested_at datetime not null,
+    index(email, requested_at)
+);
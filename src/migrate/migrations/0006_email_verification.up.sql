@@ -0,0 +1,14 @@
+ALTER TABLE user ADD COLUMN email_verified_at datetime null;
+
+-- Las cuentas creadas antes de la verificación se dan por verificadas.
+UPDATE user SET email_verified_at = UTC_TIMESTAMP() WHERE email_verified_at IS NULL;
+
+CREATE TABLE email_verification_tokens(
+	token_hash char(64) primary key,
+    user_id int not null,
+    email varchar(50) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null,
+    index(user_id, created_at)
+);
@@ -0,0 +1,24 @@
+CREATE TABLE user_totp(
+	user_id int primary key,
+    secret varchar(64) not null,
+    created_at datetime not null,
+    confirmed_at datetime null,
+    last_step bigint not null default 0
+);
+
+CREATE TABLE recovery_codes(
+	code_hash char(64) primary key,
+    user_id int not null,
+    used_at datetime null,
+    index(user_id)
+);
+
+CREATE TABLE two_factor_challenges(
+	token_hash char(64) primary key,
+    user_id int not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    attempts int not null default 0,
+    used_at datetime null,
+    index(user_id)
+);
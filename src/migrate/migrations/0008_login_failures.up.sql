@@ -0,0 +1,5 @@
+CREATE TABLE login_failures(
+	throttle_key varchar(191) primary key,
+    failures int not null,
+    last_failure_at datetime not null
+);
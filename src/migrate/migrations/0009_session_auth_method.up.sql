@@ -0,0 +1 @@
+ALTER TABLE sessions ADD COLUMN auth_method varchar(16) not null default 'pwd' AFTER ip;
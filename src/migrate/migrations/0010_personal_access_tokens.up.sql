@@ -0,0 +1,12 @@
+CREATE TABLE personal_access_tokens(
+	id varchar(32) primary key,
+    user_id int not null,
+    name varchar(50) not null,
+    token_hash char(64) not null unique,
+    scopes varchar(255) not null,
+    created_at datetime not null,
+    last_used_at datetime null,
+    expires_at datetime null,
+    revoked_at datetime null,
+    index(user_id, created_at)
+);
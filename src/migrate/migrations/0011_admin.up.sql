@@ -0,0 +1,18 @@
+ALTER TABLE user ADD COLUMN suspended_at datetime null;
+
+CREATE TABLE user_roles(
+	user_id int not null,
+    role varchar(16) not null,
+    primary key(user_id, role)
+);
+
+CREATE TABLE admin_audit_log(
+	id bigint auto_increment primary key,
+    actor_id int not null,
+    action varchar(50) not null,
+    target_id int not null,
+    details varchar(255) not null default '',
+    ip varchar(45) not null default '',
+    created_at datetime not null,
+    index(target_id)
+);
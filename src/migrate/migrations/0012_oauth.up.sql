@@ -0,0 +1,46 @@
+CREATE TABLE oauth_clients(
+	id varchar(32) primary key,
+    owner_id int not null,
+    name varchar(50) not null,
+    secret_hash char(64) null,
+    redirect_uris text not null,
+    public boolean not null default false,
+    created_at datetime not null,
+    index(owner_id, created_at)
+);
+
+CREATE TABLE oauth_consents(
+	user_id int not null,
+    client_id varchar(32) not null,
+    scopes varchar(255) not null,
+    created_at datetime not null,
+    updated_at datetime not null,
+    primary key(user_id, client_id),
+    index(client_id)
+);
+
+CREATE TABLE oauth_codes(
+	code_hash char(64) primary key,
+    client_id varchar(32) not null,
+    user_id int not null,
+    redirect_uri varchar(255) not null,
+    scopes varchar(255) not null,
+    code_challenge varchar(128) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null,
+    index(client_id),
+    index(user_id)
+);
+
+CREATE TABLE oauth_refresh_tokens(
+	token_hash char(64) primary key,
+    client_id varchar(32) not null,
+    user_id int not null,
+    scopes varchar(255) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    revoked_at datetime null,
+    index(user_id, client_id),
+    index(client_id)
+);
@@ -0,0 +1,19 @@
+CREATE TABLE oidc_logins(
+	state_hash char(64) primary key,
+    provider varchar(32) not null,
+    nonce varchar(64) not null,
+    code_verifier varchar(128) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null
+);
+
+CREATE TABLE user_identities(
+	provider varchar(32) not null,
+    subject varchar(255) not null,
+    user_id int not null,
+    email varchar(255) not null,
+    created_at datetime not null,
+    primary key(provider, subject),
+    index(user_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE user MODIFY password varchar(75) not null;
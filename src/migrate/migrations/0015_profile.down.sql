@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS email_change_tokens;
+ALTER TABLE user
+	DROP COLUMN display_name,
+    DROP COLUMN bio,
+    DROP COLUMN location,
+    DROP COLUMN website,
+    DROP COLUMN birthday;
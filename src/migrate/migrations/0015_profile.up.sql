@@ -0,0 +1,16 @@
+ALTER TABLE user
+	ADD display_name varchar(50) not null default '',
+    ADD bio varchar(160) not null default '',
+    ADD location varchar(50) not null default '',
+    ADD website varchar(255) not null default '',
+    ADD birthday date null;
+
+CREATE TABLE email_change_tokens(
+	token_hash char(64) primary key,
+    user_id int not null,
+    email varchar(255) not null,
+    created_at datetime not null,
+    expires_at datetime not null,
+    used_at datetime null,
+    index(user_id, created_at)
+);
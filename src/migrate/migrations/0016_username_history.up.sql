@@ -0,0 +1,8 @@
+CREATE TABLE username_history(
+	id int auto_increment primary key,
+    user_id int not null,
+    username varchar(50) not null,
+    changed_at datetime not null,
+    index(username, changed_at),
+    index(user_id, changed_at)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE user
+	DROP COLUMN avatar,
+    DROP COLUMN cover;
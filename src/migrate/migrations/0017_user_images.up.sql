@@ -0,0 +1,3 @@
+ALTER TABLE user
+	ADD avatar varchar(255) not null default '',
+    ADD cover varchar(255) not null default '';
@@ -0,0 +1,3 @@
+ALTER TABLE user
+	ADD delete_at datetime null,
+    ADD index(delete_at);
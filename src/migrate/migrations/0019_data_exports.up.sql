@@ -0,0 +1,14 @@
+CREATE TABLE data_exports(
+	id char(22) primary key,
+    user_id int not null,
+    status varchar(16) not null,
+    created_at datetime not null,
+    started_at datetime null,
+    finished_at datetime null,
+    expires_at datetime null,
+    download_token_hash char(64) null,
+    download_expires_at datetime null,
+    index(user_id, created_at),
+    index(status, created_at),
+    index(status, expires_at)
+);
@@ -0,0 +1 @@
+ALTER TABLE follows DROP index followee_id;
@@ -0,0 +1 @@
+ALTER TABLE follows ADD index(followee_id);
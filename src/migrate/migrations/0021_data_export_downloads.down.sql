@@ -0,0 +1,4 @@
+ALTER TABLE data_exports
+	ADD download_token_hash char(64) null,
+    ADD download_expires_at datetime null;
+DROP TABLE IF EXISTS data_export_downloads;
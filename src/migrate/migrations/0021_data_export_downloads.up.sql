@@ -0,0 +1,11 @@
+CREATE TABLE data_export_downloads(
+	token_hash char(64) primary key,
+    export_id char(22) not null,
+    user_id int not null,
+    expires_at datetime not null,
+    index(export_id, expires_at),
+    index(user_id)
+);
+ALTER TABLE data_exports
+	DROP COLUMN download_token_hash,
+    DROP COLUMN download_expires_at;
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//ScheduleDeletion implementa service.DeletionStore.
+func (s *Store) ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error {
+	query := "UPDATE user SET delete_at=? WHERE id=?"
+	_, err := s.db.ExecContext(ctx, query, at.UTC(), userID)
+	return err
+}
+
+//CancelDeletion implementa service.DeletionStore.
+func (s *Store) CancelDeletion(ctx context.Context, userID int64) (bool, error) {
+	query := "UPDATE user SET delete_at=NULL WHERE id=? AND delete_at IS NOT NULL"
+	res, err := s.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//DueDeletions implementa service.DeletionStore.
+func (s *Store) DueDeletions(ctx context.Context, now time.Time, limit int) ([]int64, error) {
+	query := "SELECT id FROM user WHERE delete_at <= ? ORDER BY delete_at LIMIT ?"
+	rows, err := s.db.QueryContext(ctx, query, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("no se pudo escanear la cuenta por borrar: %v", err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
@@ -0,0 +1,272 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//userTables son las tablas con filas de un usuario, por user_id, que se
+//borran junto con él.
+var userTables = []string{
+	"refresh_tokens",
+	"revoked_tokens",
+	"token_revocations",
+	"sessions",
+	"password_reset_tokens",
+	"email_verification_tokens",
+	"user_totp",
+	"recovery_codes",
+	"two_factor_challenges",
+	"personal_access_tokens",
+	"user_roles",
+	"oauth_consents",
+	"oauth_codes",
+	"oauth_refresh_tokens",
+	"user_identities",
+	"email_change_tokens",
+	"data_exports",
+	"data_export_downloads",
+}
+
+//UserRoles implementa service.RoleStore.
+func (s *Store) UserRoles(ctx context.Context, userID int64) ([]string, error) {
+	query := "SELECT role FROM user_roles WHERE user_id=? ORDER BY role"
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var r string
+		if err = rows.Scan(&r); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, r)
+	}
+
+	return roles, rows.Err()
+}
+
+//SetUserRoles implementa service.RoleStore.
+func (s *Store) SetUserRoles(ctx context.Context, userID int64, roles []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id=?", userID); err != nil {
+		return fmt.Errorf("no se pudieron borrar los roles: %v", err)
+	}
+
+	for _, r := range roles {
+		query := "INSERT INTO user_roles (user_id, role) VALUES (?, ?)"
+		if _, err = tx.ExecContext(ctx, query, userID, r); err != nil {
+			return fmt.Errorf("no se pudo insertar el rol %s: %v", r, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+//AdminUsers implementa service.AdminStore.
+func (s *Store) AdminUsers(ctx context.Context, search string, first int, after string) ([]service.AdminUser, error) {
+	query, args, err := buildQuery(`
+		SELECT id, email, username, followers_count, followees_count,
+		email_verified_at IS NOT NULL, suspended_at,
+		(SELECT COALESCE(GROUP_CONCAT(role ORDER BY role), '') FROM user_roles WHERE user_id = user.id)
+		FROM user
+		{{if or .search .after}}WHERE{{end}}
+		{{if .search}} (username LIKE CONCAT('%', @search, '%') OR email LIKE CONCAT('%', @search, '%')){{end}}
+		{{if and .search .after}}AND{{end}}
+		{{if .after}}username > @after {{end}}
+		ORDER BY username ASC
+		LIMIT @first`, map[string]interface{}{
+		"search": search,
+		"first":  first,
+		"after":  after,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("No se puede construir el query: %v", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	uu := make([]service.AdminUser, 0, first)
+	for rows.Next() {
+		var u service.AdminUser
+		var suspendedAt sql.NullTime
+		var roles string
+		err = rows.Scan(&u.ID, &u.Email, &u.Username, &u.FollowersCount, &u.FolloweesCount,
+			&u.EmailVerified, &suspendedAt, &roles)
+		if err != nil {
+			return nil, fmt.Errorf("No se pudo escanear el query usuarios: %v", err)
+		}
+
+		u.SuspendedAt = nullTime(suspendedAt)
+		u.Roles = []string{}
+		if roles != "" {
+			u.Roles = strings.Split(roles, ",")
+		}
+
+		uu = append(uu, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("No se pueden iterar las filas: %v", err)
+	}
+	return uu, nil
+}
+
+//SetUserSuspended implementa service.AdminStore.
+func (s *Store) SetUserSuspended(ctx context.Context, userID int64, suspendedAt *time.Time) error {
+	var at interface{}
+	if suspendedAt != nil {
+		at = suspendedAt.UTC()
+	}
+
+	var exists bool
+	query := "SELECT EXISTS(SELECT 1 FROM user WHERE id=?)"
+	if err := s.db.QueryRowContext(ctx, query, userID).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return service.ErrUserNotFound
+	}
+
+	_, err := s.db.ExecContext(ctx, "UPDATE user SET suspended_at=? WHERE id=?", at, userID)
+	return err
+}
+
+//UserSuspended implementa service.AdminStore.
+func (s *Store) UserSuspended(ctx context.Context, userID int64) (bool, error) {
+	var suspended bool
+	query := "SELECT suspended_at IS NOT NULL FROM user WHERE id=?"
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&suspended)
+	if err == sql.ErrNoRows {
+		return false, service.ErrUserNotFound
+	}
+
+	return suspended, err
+}
+
+//DeleteUser implementa service.AdminStore.
+func (s *Store) DeleteUser(ctx context.Context, userID int64, deletedAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	query := "UPDATE user JOIN follows ON follows.followee_id = user.id " +
+		"SET user.followers_count = user.followers_count - 1 WHERE follows.follower_id=?"
+	if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("no se pudo actualizar el contador de seguidores: %v", err)
+	}
+
+	query = "UPDATE user JOIN follows ON follows.follower_id = user.id " +
+		"SET user.followees_count = user.followees_count - 1 WHERE follows.followee_id=?"
+	if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("no se pudo actualizar el contador de seguidos: %v", err)
+	}
+
+	query = "DELETE FROM follows WHERE follower_id=? OR followee_id=?"
+	if _, err = tx.ExecContext(ctx, query, userID, userID); err != nil {
+		return fmt.Errorf("no se pudieron borrar los follows: %v", err)
+	}
+
+	query = "DELETE password_reset_requests FROM password_reset_requests " +
+		"JOIN user ON user.email = password_reset_requests.email WHERE user.id=?"
+	if _, err = tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("no se pudieron borrar las recuperaciones pedidas: %v", err)
+	}
+
+	//el username actual queda reservado como si lo hubiera cambiado
+	query = "INSERT INTO username_history (user_id, username, changed_at) SELECT id, username, ? FROM user WHERE id=?"
+	if _, err = tx.ExecContext(ctx, query, deletedAt.UTC(), userID); err != nil {
+		return fmt.Errorf("no se pudo guardar el username en el historial: %v", err)
+	}
+
+	for _, table := range userTables {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id=?", userID); err != nil {
+			return fmt.Errorf("no se pudo borrar de %s: %v", table, err)
+		}
+	}
+
+	//las autorizaciones que otros usuarios dieron a sus aplicaciones dejan de
+	//servir porque OAuthConsent las junta con oauth_clients
+	if _, err = tx.ExecContext(ctx, "DELETE FROM oauth_clients WHERE owner_id=?", userID); err != nil {
+		return fmt.Errorf("no se pudieron borrar las aplicaciones: %v", err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM user WHERE id=?", userID)
+	if err != nil {
+		return fmt.Errorf("no se pudo borrar el usuario: %v", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return service.ErrUserNotFound
+	}
+
+	return tx.Commit()
+}
+
+//RecordAudit implementa service.AuditStore.
+func (s *Store) RecordAudit(ctx context.Context, e service.AuditEntry) error {
+	query := "INSERT INTO admin_audit_log (actor_id, action, target_id, details, ip, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, e.ActorID, e.Action, e.TargetID, e.Details, e.IP, e.CreatedAt.UTC())
+	return err
+}
+
+//AuditLog implementa service.AuditStore.
+func (s *Store) AuditLog(ctx context.Context, first int, before int64) ([]service.AuditEntry, error) {
+	query, args, err := buildQuery(`
+		SELECT id, actor_id, action, target_id, details, ip, created_at
+		FROM admin_audit_log
+		{{if .before}}WHERE id < @before{{end}}
+		ORDER BY id DESC
+		LIMIT @first`, map[string]interface{}{
+		"before": before,
+		"first":  first,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("No se puede construir el query: %v", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	ee := make([]service.AuditEntry, 0, first)
+	for rows.Next() {
+		var e service.AuditEntry
+		if err = rows.Scan(&e.ID, &e.ActorID, &e.Action, &e.TargetID, &e.Details, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		ee = append(ee, e)
+	}
+
+	return ee, rows.Err()
+}
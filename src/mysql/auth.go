@@ -0,0 +1,22 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//Credentials implementa service.CredentialStore.
+func (s *Store) Credentials(ctx context.Context, email string) (service.User, string, error) {
+	var u service.User
+	var hash string
+
+	query := "SELECT id, username, avatar, password from user where email=?"
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&u.ID, &u.Username, &u.Avatar, &hash)
+	if err == sql.ErrNoRows {
+		return u, "", service.ErrUserNotFound
+	}
+
+	return u, hash, err
+}
@@ -0,0 +1,175 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//dataExportColumns son las columnas de data_exports en el orden de
+//scanDataExport.
+const dataExportColumns = "id, user_id, status, created_at, started_at, finished_at, expires_at"
+
+//scanner es un *sql.Row o un *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDataExport(row scanner) (service.DataExport, error) {
+	var e service.DataExport
+	var startedAt, finishedAt, expiresAt sql.NullTime
+	err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.CreatedAt, &startedAt, &finishedAt, &expiresAt)
+	e.StartedAt = nullTime(startedAt)
+	e.FinishedAt = nullTime(finishedAt)
+	e.ExpiresAt = nullTime(expiresAt)
+	return e, err
+}
+
+//CreateDataExport implementa service.DataExportStore.
+func (s *Store) CreateDataExport(ctx context.Context, e service.DataExport) error {
+	query := "INSERT INTO data_exports (id, user_id, status, created_at) VALUES (?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, e.ID, e.UserID, e.Status, e.CreatedAt.UTC())
+	return err
+}
+
+//DataExport implementa service.DataExportStore.
+func (s *Store) DataExport(ctx context.Context, id string) (service.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE id=?"
+	e, err := scanDataExport(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return e, service.ErrDataExportNotFound
+	}
+
+	return e, err
+}
+
+//DataExports implementa service.DataExportStore.
+func (s *Store) DataExports(ctx context.Context, userID int64) ([]service.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE user_id=? ORDER BY created_at"
+	return s.dataExports(ctx, query, userID)
+}
+
+//ActiveDataExport implementa service.DataExportStore.
+func (s *Store) ActiveDataExport(ctx context.Context, userID int64) (service.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE user_id=? AND status IN (?, ?) LIMIT 1"
+	row := s.db.QueryRowContext(ctx, query, userID, service.DataExportPending, service.DataExportRunning)
+	e, err := scanDataExport(row)
+	if err == sql.ErrNoRows {
+		return e, service.ErrDataExportNotFound
+	}
+
+	return e, err
+}
+
+//CountDataExports implementa service.DataExportStore.
+func (s *Store) CountDataExports(ctx context.Context, userID int64, since time.Time) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM data_exports WHERE user_id=? AND created_at >= ?"
+	err := s.db.QueryRowContext(ctx, query, userID, since.UTC()).Scan(&n)
+	return n, err
+}
+
+//ClaimDataExport implementa service.DataExportStore. Bloquea la fila para
+//que dos procesos no tomen la misma exportación.
+func (s *Store) ClaimDataExport(ctx context.Context, staleBefore, now time.Time) (service.DataExport, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.DataExport{}, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	query := "SELECT " + dataExportColumns + " FROM data_exports" +
+		" WHERE status=? OR (status=? AND started_at < ?) ORDER BY created_at LIMIT 1 FOR UPDATE"
+	row := tx.QueryRowContext(ctx, query, service.DataExportPending, service.DataExportRunning, staleBefore.UTC())
+	e, err := scanDataExport(row)
+	if err == sql.ErrNoRows {
+		return e, service.ErrDataExportNotFound
+	}
+
+	if err != nil {
+		return e, err
+	}
+
+	query = "UPDATE data_exports SET status=?, started_at=? WHERE id=?"
+	if _, err = tx.ExecContext(ctx, query, service.DataExportRunning, now.UTC(), e.ID); err != nil {
+		return e, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return e, fmt.Errorf("no se pudo tomar la exportación: %v", err)
+	}
+
+	e.Status = service.DataExportRunning
+	e.StartedAt = &now
+	return e, nil
+}
+
+//FinishDataExport implementa service.DataExportStore.
+func (s *Store) FinishDataExport(ctx context.Context, id, status string, finishedAt time.Time, expiresAt *time.Time) error {
+	var exp interface{}
+	if expiresAt != nil {
+		exp = expiresAt.UTC()
+	}
+
+	query := "UPDATE data_exports SET status=?, finished_at=?, expires_at=? WHERE id=?"
+	if _, err := s.db.ExecContext(ctx, query, status, finishedAt.UTC(), exp, id); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM data_export_downloads WHERE export_id=?", id)
+	return err
+}
+
+//CreateDataExportDownload implementa service.DataExportStore.
+func (s *Store) CreateDataExportDownload(ctx context.Context, d service.DataExportDownload, now time.Time) error {
+	query := "DELETE FROM data_export_downloads WHERE export_id=? AND expires_at < ?"
+	if _, err := s.db.ExecContext(ctx, query, d.ExportID, now.UTC()); err != nil {
+		return err
+	}
+
+	query = "INSERT INTO data_export_downloads (token_hash, export_id, user_id, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, d.TokenHash, d.ExportID, d.UserID, d.ExpiresAt.UTC())
+	return err
+}
+
+//DataExportDownload implementa service.DataExportStore.
+func (s *Store) DataExportDownload(ctx context.Context, tokenHash string) (service.DataExportDownload, error) {
+	d := service.DataExportDownload{TokenHash: tokenHash}
+	query := "SELECT export_id, user_id, expires_at FROM data_export_downloads WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, tokenHash).Scan(&d.ExportID, &d.UserID, &d.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return d, service.ErrInvalidDownloadToken
+	}
+
+	return d, err
+}
+
+//ExpiredDataExports implementa service.DataExportStore.
+func (s *Store) ExpiredDataExports(ctx context.Context, now time.Time, limit int) ([]service.DataExport, error) {
+	query := "SELECT " + dataExportColumns + " FROM data_exports WHERE status=? AND expires_at <= ? ORDER BY expires_at LIMIT ?"
+	return s.dataExports(ctx, query, service.DataExportReady, now.UTC(), limit)
+}
+
+func (s *Store) dataExports(ctx context.Context, query string, args ...interface{}) ([]service.DataExport, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	var ee []service.DataExport
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escanear la exportación: %v", err)
+		}
+
+		ee = append(ee, e)
+	}
+
+	return ee, rows.Err()
+}
@@ -0,0 +1,77 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateEmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) CreateEmailVerificationToken(ctx context.Context, t service.EmailVerificationToken) error {
+	query := "INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.Hash, t.UserID, t.Email, t.CreatedAt.UTC(), t.ExpiresAt.UTC())
+	return err
+}
+
+//EmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) EmailVerificationToken(ctx context.Context, hash string) (service.EmailVerificationToken, error) {
+	t := service.EmailVerificationToken{Hash: hash}
+	var usedAt sql.NullTime
+
+	query := "SELECT user_id, email, created_at, expires_at, used_at FROM email_verification_tokens WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.UserID, &t.Email, &t.CreatedAt, &t.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return t, service.ErrInvalidVerificationToken
+	}
+
+	t.UsedAt = nullTime(usedAt)
+	return t, err
+}
+
+//UseEmailVerificationToken implementa service.EmailVerificationStore.
+func (s *Store) UseEmailVerificationToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE email_verification_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//CountEmailVerificationTokens implementa service.EmailVerificationStore.
+func (s *Store) CountEmailVerificationTokens(ctx context.Context, userID int64, since time.Time) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM email_verification_tokens WHERE user_id=? AND created_at > ?"
+	err := s.db.QueryRowContext(ctx, query, userID, since.UTC()).Scan(&n)
+	return n, err
+}
+
+//EmailStatus implementa service.EmailVerificationStore.
+func (s *Store) EmailStatus(ctx context.Context, userID int64) (string, bool, error) {
+	var email string
+	var verified bool
+
+	query := "SELECT email, email_verified_at IS NOT NULL FROM user WHERE id=?"
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&email, &verified)
+	if err == sql.ErrNoRows {
+		return "", false, service.ErrUserNotFound
+	}
+
+	return email, verified, err
+}
+
+//MarkEmailVerified implementa service.EmailVerificationStore.
+func (s *Store) MarkEmailVerified(ctx context.Context, userID int64, email string, at time.Time) (bool, error) {
+	query := "UPDATE user SET email_verified_at=? WHERE id=? AND email=?"
+	res, err := s.db.ExecContext(ctx, query, at.UTC(), userID, email)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SetFollow implementa service.FollowStore.
+func (s *Store) SetFollow(ctx context.Context, followerID, followeeID int64, op service.FollowOp) (service.ToggleFollowOutput, error) {
+	var out service.ToggleFollowOutput
+
+	//inicio de una transacción
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+	//fin de la transacción
+
+	query := "SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id=? AND followee_id=?)"
+	if err = tx.QueryRowContext(ctx, query, followerID, followeeID).Scan(&out.Following); err != nil {
+		return out, fmt.Errorf("No se pudo realizar la consulta de seguidor: %v", err)
+	}
+
+	//el DELETE y el INSERT IGNORE dicen si cambiaron la fila, así dos
+	//peticiones a la vez no mueven los contadores dos veces
+	unfollow := op == service.FollowRemove || op == service.FollowToggle && out.Following
+	var n int64
+
+	//Para cuando un usario esté siguiendo y quiera dejar de seguir
+
+	if unfollow {
+		query = "DELETE FROM follows WHERE follower_id=? AND followee_id=?"
+		res, err := tx.ExecContext(ctx, query, followerID, followeeID)
+		if err != nil {
+			return out, fmt.Errorf("No se pudo borrar los seguidores: %v", err)
+		}
+
+		if n, err = res.RowsAffected(); err != nil {
+			return out, err
+		}
+
+		if n == 1 {
+			query = "UPDATE user SET followees_count = followees_count - 1 WHERE id=?"
+			if _, err = tx.ExecContext(ctx, query, followerID); err != nil {
+				return out, fmt.Errorf("no se pudo actualizar el contador de seguidos: %v", err)
+			}
+
+			query = "call subfollowers(?)"
+			if err = tx.QueryRowContext(ctx, query, followeeID).Scan(&out.FollowersCount); err != nil {
+				return out, fmt.Errorf("No se pudo actualizar el contador de seguidores: %v", err)
+			}
+		}
+	} else { //cuando un usario quiera seguir a otro usuario
+		//inserta el usuario seguido
+		query = "INSERT IGNORE INTO follows(follower_id, followee_id) VALUES (?, ?)"
+		res, err := tx.ExecContext(ctx, query, followerID, followeeID)
+		if err != nil {
+			return out, fmt.Errorf("No se pudo insertar usuarios seguidos: %v", err)
+		}
+
+		if n, err = res.RowsAffected(); err != nil {
+			return out, err
+		}
+
+		if n == 1 {
+			//actualiza el contador de seguidores
+			query = "UPDATE user SET followees_count = followees_count + 1 WHERE id=?"
+			if _, err = tx.ExecContext(ctx, query, followerID); err != nil {
+				return out, fmt.Errorf("No se pudo actualizar el contador de seguidos: %v", err)
+			}
+
+			query = "call addfollowers(?)"
+			if err = tx.QueryRowContext(ctx, query, followeeID).Scan(&out.FollowersCount); err != nil {
+				return out, fmt.Errorf("No se pudo actualizar el contador de seguidoores: %v", err)
+			}
+		}
+	}
+
+	//cuando ya estaba como se pidió
+	if n == 0 {
+		query = "SELECT followers_count FROM user WHERE id=?"
+		if err = tx.QueryRowContext(ctx, query, followeeID).Scan(&out.FollowersCount); err != nil {
+			return out, fmt.Errorf("no se pudo consultar el contador de seguidores: %v", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return out, fmt.Errorf("No se realizo un commit al toogle de seguir: %v", err)
+	}
+
+	out.Following = !unfollow
+
+	return out, nil
+}
+
+//Followers implementa service.FollowStore.
+func (s *Store) Followers(ctx context.Context, viewerID, userID int64, first int, after string) ([]service.UserProfile, error) {
+	return s.follows(ctx, viewerID, userID, first, after, "follower_id", "followee_id")
+}
+
+//Followees implementa service.FollowStore.
+func (s *Store) Followees(ctx context.Context, viewerID, userID int64, first int, after string) ([]service.UserProfile, error) {
+	return s.follows(ctx, viewerID, userID, first, after, "followee_id", "follower_id")
+}
+
+//follows devuelve los perfiles de los usuarios en la columna join de los
+//follows con userID en la columna where, paginados como Users.
+func (s *Store) follows(ctx context.Context, viewerID, userID int64, first int, after, join, where string) ([]service.UserProfile, error) {
+	auth := viewerID != 0
+
+	query, args, err := buildQuery(`
+		SELECT id, email, username, display_name, bio, location, website, birthday, followers_count, followees_count,
+			avatar, cover
+		{{if .auth}}
+		,followers.follower_id IS NOT NULL AS following
+		,followees.followee_id IS NOT NULL AS followeed
+		{{end}}
+		FROM follows
+		INNER JOIN user ON user.id = follows.{{.join}}
+		{{if .auth}}
+		LEFT JOIN follows AS followers ON followers.follower_id = @uid AND followers.followee_id = user.id
+		LEFT JOIN follows AS followees ON followees.follower_id = user.id AND followees.followee_id = @uid
+		{{end}}
+		WHERE follows.{{.where}} = @user
+		{{if .after}}AND username > @after{{end}}
+		ORDER BY username ASC
+		LIMIT @first`, map[string]interface{}{
+		"auth":  auth,
+		"uid":   viewerID,
+		"user":  userID,
+		"join":  join,
+		"where": where,
+		"first": first,
+		"after": after,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("No se puede construir el query: %v", err)
+	}
+
+	return s.userProfiles(ctx, auth, first, query, args...)
+}
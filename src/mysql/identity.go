@@ -0,0 +1,62 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateOIDCLogin implementa service.IdentityStore.
+func (s *Store) CreateOIDCLogin(ctx context.Context, l service.OIDCLogin) error {
+	query := "INSERT INTO oidc_logins (state_hash, provider, nonce, code_verifier, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, l.Hash, l.Provider, l.Nonce, l.CodeVerifier, l.CreatedAt.UTC(), l.ExpiresAt.UTC())
+	return err
+}
+
+//OIDCLogin implementa service.IdentityStore.
+func (s *Store) OIDCLogin(ctx context.Context, hash string) (service.OIDCLogin, error) {
+	var l service.OIDCLogin
+	var usedAt sql.NullTime
+	query := "SELECT state_hash, provider, nonce, code_verifier, created_at, expires_at, used_at FROM oidc_logins WHERE state_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&l.Hash, &l.Provider, &l.Nonce, &l.CodeVerifier,
+		&l.CreatedAt, &l.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return l, service.ErrInvalidOIDCState
+	}
+
+	l.UsedAt = nullTime(usedAt)
+	return l, err
+}
+
+//UseOIDCLogin implementa service.IdentityStore.
+func (s *Store) UseOIDCLogin(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE oidc_logins SET used_at=? WHERE state_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//IdentityUserID implementa service.IdentityStore.
+func (s *Store) IdentityUserID(ctx context.Context, provider, subject string) (int64, error) {
+	var uid int64
+	query := "SELECT user_id FROM user_identities WHERE provider=? AND subject=?"
+	err := s.db.QueryRowContext(ctx, query, provider, subject).Scan(&uid)
+	if err == sql.ErrNoRows {
+		return 0, service.ErrIdentityNotFound
+	}
+
+	return uid, err
+}
+
+//LinkIdentity implementa service.IdentityStore.
+func (s *Store) LinkIdentity(ctx context.Context, i service.Identity) error {
+	query := "INSERT INTO user_identities (provider, subject, user_id, email, created_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, i.Provider, i.Subject, i.UserID, i.Email, i.CreatedAt.UTC())
+	return err
+}
@@ -0,0 +1,59 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SetAvatar implementa service.ImageStore.
+func (s *Store) SetAvatar(ctx context.Context, userID int64, key string) (string, error) {
+	return s.setImage(ctx, "avatar", userID, key)
+}
+
+//SetCover implementa service.ImageStore.
+func (s *Store) SetCover(ctx context.Context, userID int64, key string) (string, error) {
+	return s.setImage(ctx, "cover", userID, key)
+}
+
+//setImage cambia la columna avatar o cover y devuelve el valor anterior.
+func (s *Store) setImage(ctx context.Context, column string, userID int64, key string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	var old string
+	query := "SELECT " + column + " FROM user WHERE id=? FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&old)
+	if err == sql.ErrNoRows {
+		return "", service.ErrUserNotFound
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	query = "UPDATE user SET " + column + "=? WHERE id=?"
+	if _, err = tx.ExecContext(ctx, query, key, userID); err != nil {
+		return "", err
+	}
+
+	return old, tx.Commit()
+}
+
+//UserImages implementa service.ImageStore.
+func (s *Store) UserImages(ctx context.Context, userID int64) (string, string, error) {
+	var avatar, cover string
+	query := "SELECT avatar, cover FROM user WHERE id=?"
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&avatar, &cover)
+	if err == sql.ErrNoRows {
+		return "", "", service.ErrUserNotFound
+	}
+
+	return avatar, cover, err
+}
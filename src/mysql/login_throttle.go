@@ -0,0 +1,41 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//RecordLoginFailure implementa service.LoginAttemptStore.
+func (s *Store) RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (service.LoginFailures, error) {
+	//failures se asigna antes que last_failure_at, así que compara con el
+	//fallo anterior.
+	query := "INSERT INTO login_failures (throttle_key, failures, last_failure_at) VALUES (?, 1, ?) " +
+		"ON DUPLICATE KEY UPDATE failures = IF(last_failure_at < ?, 1, failures + 1), last_failure_at = VALUES(last_failure_at)"
+	_, err := s.db.ExecContext(ctx, query, key, at.UTC(), at.Add(-window).UTC())
+	if err != nil {
+		return service.LoginFailures{Key: key}, err
+	}
+
+	return s.LoginFailures(ctx, key)
+}
+
+//LoginFailures implementa service.LoginAttemptStore.
+func (s *Store) LoginFailures(ctx context.Context, key string) (service.LoginFailures, error) {
+	f := service.LoginFailures{Key: key}
+	query := "SELECT failures, last_failure_at FROM login_failures WHERE throttle_key=?"
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&f.Count, &f.LastFailureAt)
+	if err == sql.ErrNoRows {
+		return f, nil
+	}
+
+	return f, err
+}
+
+//ClearLoginFailures implementa service.LoginAttemptStore.
+func (s *Store) ClearLoginFailures(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM login_failures WHERE throttle_key=?", key)
+	return err
+}
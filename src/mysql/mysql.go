@@ -2,10 +2,13 @@ package mysql
 
 import (
 	"database/sql"
-	"log"
+	"fmt"
+	"strconv"
 	"sync"
 
-	_ "github.com/go-sql-driver/mysql" //Es el conector para mysql
+	driver "github.com/go-sql-driver/mysql" //Es el conector para mysql
+
+	"github.com/Mynor2397/social-network/src/config"
 )
 
 var (
@@ -15,18 +18,56 @@ var (
 )
 
 //Connect is a function that permited the connection to mysql
-func Connect() *sql.DB {
-	user := "root"
-	password := "system"
-	server := "localhost"
-	database := "network"
-
+func Connect(cfg config.Database) (*sql.DB, error) {
 	once.Do(func() {
-		db, err = sql.Open("mysql", user+":"+password+"@tcp("+server+")/"+database)
+		db, err = sql.Open("mysql", DSN(cfg))
 		if err != nil {
-			log.Println(err.Error())
+			err = fmt.Errorf("no se pudo abrir la conexión a mysql: %v", err)
+			return
 		}
+
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	})
 
-	return db
+	return db, err
+}
+
+//OpenForMigrations abre una conexión aparte del pool de Connect que acepta
+//varias sentencias por consulta, como las de una migración.
+func OpenForMigrations(cfg config.Database) (*sql.DB, error) {
+	c := driverConfig(cfg)
+	c.MultiStatements = true
+	c.ParseTime = true
+
+	db, err := sql.Open("mysql", c.FormatDSN())
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la conexión a mysql: %v", err)
+	}
+
+	return db, nil
+}
+
+//DSN arma la cadena de conexión del driver a partir de la configuración.
+func DSN(cfg config.Database) string {
+	return driverConfig(cfg).FormatDSN()
+}
+
+func driverConfig(cfg config.Database) *driver.Config {
+	c := driver.NewConfig()
+	c.User = cfg.User
+	c.Passwd = cfg.Password
+	c.Net = "tcp"
+	c.Addr = cfg.Host + ":" + strconv.Itoa(cfg.Port)
+	c.DBName = cfg.Name
+	c.ParseTime = cfg.ParseTime
+	c.Timeout = cfg.Timeout
+	c.ReadTimeout = cfg.ReadTimeout
+	c.WriteTimeout = cfg.WriteTimeout
+	if cfg.Charset != "" {
+		c.Params = map[string]string{"charset": cfg.Charset}
+	}
+
+	return c
 }
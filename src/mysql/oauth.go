@@ -0,0 +1,261 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateOAuthClient implementa service.OAuthStore.
+func (s *Store) CreateOAuthClient(ctx context.Context, c service.OAuthClient) error {
+	var secretHash interface{}
+	if c.SecretHash != "" {
+		secretHash = c.SecretHash
+	}
+
+	query := "INSERT INTO oauth_clients (id, owner_id, name, secret_hash, redirect_uris, public, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, c.ID, c.OwnerID, c.Name, secretHash,
+		strings.Join(c.RedirectURIs, "\n"), c.Public, c.CreatedAt.UTC())
+	return err
+}
+
+const oauthClientColumns = "id, owner_id, name, secret_hash, redirect_uris, public, created_at"
+
+func scanOAuthClient(row interface{ Scan(...interface{}) error }) (service.OAuthClient, error) {
+	var c service.OAuthClient
+	var secretHash sql.NullString
+	var uris string
+	err := row.Scan(&c.ID, &c.OwnerID, &c.Name, &secretHash, &uris, &c.Public, &c.CreatedAt)
+	c.SecretHash = secretHash.String
+	c.RedirectURIs = strings.Split(uris, "\n")
+	return c, err
+}
+
+//OAuthClient implementa service.OAuthStore.
+func (s *Store) OAuthClient(ctx context.Context, id string) (service.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE id=?"
+	c, err := scanOAuthClient(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return c, service.ErrOAuthClientNotFound
+	}
+
+	return c, err
+}
+
+//OAuthClients implementa service.OAuthStore.
+func (s *Store) OAuthClients(ctx context.Context, ownerID int64) ([]service.OAuthClient, error) {
+	query := "SELECT " + oauthClientColumns + " FROM oauth_clients WHERE owner_id=? ORDER BY created_at DESC"
+	rows, err := s.db.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cc := []service.OAuthClient{}
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escanear la aplicación: %v", err)
+		}
+		cc = append(cc, c)
+	}
+
+	return cc, rows.Err()
+}
+
+//DeleteOAuthClient implementa service.OAuthStore.
+func (s *Store) DeleteOAuthClient(ctx context.Context, ownerID int64, id string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM oauth_clients WHERE id=? AND owner_id=?", id, ownerID)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	for _, table := range []string{"oauth_consents", "oauth_codes", "oauth_refresh_tokens"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE client_id=?", id); err != nil {
+			return false, fmt.Errorf("no se pudo borrar de %s: %v", table, err)
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+//SaveOAuthConsent implementa service.OAuthStore.
+func (s *Store) SaveOAuthConsent(ctx context.Context, c service.OAuthConsent) error {
+	query := "INSERT INTO oauth_consents (user_id, client_id, scopes, created_at, updated_at) VALUES (?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE scopes=VALUES(scopes), updated_at=VALUES(updated_at)"
+	_, err := s.db.ExecContext(ctx, query, c.UserID, c.ClientID, strings.Join(c.Scopes, ","),
+		c.CreatedAt.UTC(), c.UpdatedAt.UTC())
+	return err
+}
+
+const oauthConsentColumns = "oauth_consents.user_id, oauth_consents.client_id, oauth_clients.name, " +
+	"oauth_consents.scopes, oauth_consents.created_at, oauth_consents.updated_at"
+
+func scanOAuthConsent(row interface{ Scan(...interface{}) error }) (service.OAuthConsent, error) {
+	var c service.OAuthConsent
+	var scopes string
+	err := row.Scan(&c.UserID, &c.ClientID, &c.ClientName, &scopes, &c.CreatedAt, &c.UpdatedAt)
+	c.Scopes = strings.Split(scopes, ",")
+	return c, err
+}
+
+//OAuthConsent implementa service.OAuthStore.
+func (s *Store) OAuthConsent(ctx context.Context, userID int64, clientID string) (service.OAuthConsent, error) {
+	query := "SELECT " + oauthConsentColumns + " FROM oauth_consents " +
+		"JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id " +
+		"WHERE oauth_consents.user_id=? AND oauth_consents.client_id=?"
+	c, err := scanOAuthConsent(s.db.QueryRowContext(ctx, query, userID, clientID))
+	if err == sql.ErrNoRows {
+		return c, service.ErrOAuthConsentNotFound
+	}
+
+	return c, err
+}
+
+//OAuthConsents implementa service.OAuthStore.
+func (s *Store) OAuthConsents(ctx context.Context, userID int64) ([]service.OAuthConsent, error) {
+	query := "SELECT " + oauthConsentColumns + " FROM oauth_consents " +
+		"JOIN oauth_clients ON oauth_clients.id = oauth_consents.client_id " +
+		"WHERE oauth_consents.user_id=? ORDER BY oauth_consents.updated_at DESC"
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cc := []service.OAuthConsent{}
+	for rows.Next() {
+		c, err := scanOAuthConsent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escanear la autorización: %v", err)
+		}
+		cc = append(cc, c)
+	}
+
+	return cc, rows.Err()
+}
+
+//DeleteOAuthConsent implementa service.OAuthStore.
+func (s *Store) DeleteOAuthConsent(ctx context.Context, userID int64, clientID string) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM oauth_consents WHERE user_id=? AND client_id=?", userID, clientID)
+	if err != nil {
+		return false, err
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	query := "DELETE FROM oauth_refresh_tokens WHERE user_id=? AND client_id=?"
+	if _, err = tx.ExecContext(ctx, query, userID, clientID); err != nil {
+		return false, fmt.Errorf("no se pudieron borrar los refresh tokens: %v", err)
+	}
+
+	return true, tx.Commit()
+}
+
+//CreateOAuthCode implementa service.OAuthStore.
+func (s *Store) CreateOAuthCode(ctx context.Context, c service.OAuthCode) error {
+	query := "INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at) " +
+		"VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, c.Hash, c.ClientID, c.UserID, c.RedirectURI,
+		strings.Join(c.Scopes, ","), c.CodeChallenge, c.CreatedAt.UTC(), c.ExpiresAt.UTC())
+	return err
+}
+
+//OAuthCode implementa service.OAuthStore.
+func (s *Store) OAuthCode(ctx context.Context, hash string) (service.OAuthCode, error) {
+	var c service.OAuthCode
+	var scopes string
+	var usedAt sql.NullTime
+	query := "SELECT code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at, used_at " +
+		"FROM oauth_codes WHERE code_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&c.Hash, &c.ClientID, &c.UserID, &c.RedirectURI,
+		&scopes, &c.CodeChallenge, &c.CreatedAt, &c.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return c, service.ErrOAuthCodeNotFound
+	}
+
+	c.Scopes = strings.Split(scopes, ",")
+	c.UsedAt = nullTime(usedAt)
+	return c, err
+}
+
+//UseOAuthCode implementa service.OAuthStore.
+func (s *Store) UseOAuthCode(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE oauth_codes SET used_at=? WHERE code_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//CreateOAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) CreateOAuthRefreshToken(ctx context.Context, t service.OAuthRefreshToken) error {
+	query := "INSERT INTO oauth_refresh_tokens (token_hash, client_id, user_id, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.Hash, t.ClientID, t.UserID,
+		strings.Join(t.Scopes, ","), t.CreatedAt.UTC(), t.ExpiresAt.UTC())
+	return err
+}
+
+//OAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) OAuthRefreshToken(ctx context.Context, hash string) (service.OAuthRefreshToken, error) {
+	var t service.OAuthRefreshToken
+	var scopes string
+	var revokedAt sql.NullTime
+	query := "SELECT token_hash, client_id, user_id, scopes, created_at, expires_at, revoked_at " +
+		"FROM oauth_refresh_tokens WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.Hash, &t.ClientID, &t.UserID,
+		&scopes, &t.CreatedAt, &t.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return t, service.ErrOAuthRefreshTokenNotFound
+	}
+
+	t.Scopes = strings.Split(scopes, ",")
+	t.RevokedAt = nullTime(revokedAt)
+	return t, err
+}
+
+//RevokeOAuthRefreshToken implementa service.OAuthStore.
+func (s *Store) RevokeOAuthRefreshToken(ctx context.Context, hash string, revokedAt time.Time) (bool, error) {
+	query := "UPDATE oauth_refresh_tokens SET revoked_at=? WHERE token_hash=? AND revoked_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//RevokeOAuthRefreshTokens implementa service.OAuthStore.
+func (s *Store) RevokeOAuthRefreshTokens(ctx context.Context, userID int64, clientID string, revokedAt time.Time) error {
+	query := "UPDATE oauth_refresh_tokens SET revoked_at=? WHERE user_id=? AND client_id=? AND revoked_at IS NULL"
+	_, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), userID, clientID)
+	return err
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreatePasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) CreatePasswordResetToken(ctx context.Context, t service.PasswordResetToken) error {
+	query := "INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.Hash, t.UserID, t.CreatedAt.UTC(), t.ExpiresAt.UTC())
+	return err
+}
+
+//PasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) PasswordResetToken(ctx context.Context, hash string) (service.PasswordResetToken, error) {
+	t := service.PasswordResetToken{Hash: hash}
+	var usedAt sql.NullTime
+
+	query := "SELECT user_id, created_at, expires_at, used_at FROM password_reset_tokens WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.UserID, &t.CreatedAt, &t.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return t, service.ErrInvalidResetToken
+	}
+
+	t.UsedAt = nullTime(usedAt)
+	return t, err
+}
+
+//UsePasswordResetToken implementa service.PasswordResetStore.
+func (s *Store) UsePasswordResetToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE password_reset_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//RecordPasswordResetRequest implementa service.PasswordResetStore.
+func (s *Store) RecordPasswordResetRequest(ctx context.Context, email string, at time.Time) error {
+	query := "INSERT INTO password_reset_requests (email, requested_at) VALUES (?, ?)"
+	_, err := s.db.ExecContext(ctx, query, email, at.UTC())
+	return err
+}
+
+//CountPasswordResetRequests implementa service.PasswordResetStore.
+func (s *Store) CountPasswordResetRequests(ctx context.Context, email string, since time.Time) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM password_reset_requests WHERE email=? AND requested_at > ?"
+	err := s.db.QueryRowContext(ctx, query, email, since.UTC()).Scan(&n)
+	return n, err
+}
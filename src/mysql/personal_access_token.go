@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreatePersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) CreatePersonalAccessToken(ctx context.Context, t service.PersonalAccessToken) error {
+	var expiresAt interface{}
+	if t.ExpiresAt != nil {
+		expiresAt = t.ExpiresAt.UTC()
+	}
+
+	query := "INSERT INTO personal_access_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.ID, t.UserID, t.Name, t.Hash,
+		strings.Join(t.Scopes, ","), t.CreatedAt.UTC(), expiresAt)
+	return err
+}
+
+const personalAccessTokenColumns = "id, user_id, name, token_hash, scopes, created_at, last_used_at, expires_at, revoked_at"
+
+func scanPersonalAccessToken(row interface{ Scan(...interface{}) error }) (service.PersonalAccessToken, error) {
+	var t service.PersonalAccessToken
+	var scopes string
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Hash, &scopes,
+		&t.CreatedAt, &lastUsedAt, &expiresAt, &revokedAt)
+	t.Scopes = strings.Split(scopes, ",")
+	t.LastUsedAt = nullTime(lastUsedAt)
+	t.ExpiresAt = nullTime(expiresAt)
+	t.RevokedAt = nullTime(revokedAt)
+	return t, err
+}
+
+//PersonalAccessTokenByHash implementa service.PersonalAccessTokenStore.
+func (s *Store) PersonalAccessTokenByHash(ctx context.Context, hash string) (service.PersonalAccessToken, error) {
+	query := "SELECT " + personalAccessTokenColumns + " FROM personal_access_tokens WHERE token_hash=?"
+	t, err := scanPersonalAccessToken(s.db.QueryRowContext(ctx, query, hash))
+	if err == sql.ErrNoRows {
+		return t, service.ErrInvalidPersonalAccessToken
+	}
+
+	return t, err
+}
+
+//PersonalAccessTokens implementa service.PersonalAccessTokenStore.
+func (s *Store) PersonalAccessTokens(ctx context.Context, userID int64) ([]service.PersonalAccessToken, error) {
+	query := "SELECT " + personalAccessTokenColumns + " FROM personal_access_tokens " +
+		"WHERE user_id=? AND revoked_at IS NULL ORDER BY created_at DESC"
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tt := []service.PersonalAccessToken{}
+	for rows.Next() {
+		t, err := scanPersonalAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escanear el token personal: %v", err)
+		}
+		tt = append(tt, t)
+	}
+
+	return tt, rows.Err()
+}
+
+//TouchPersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) TouchPersonalAccessToken(ctx context.Context, id string, usedAt time.Time) error {
+	query := "UPDATE personal_access_tokens SET last_used_at=? WHERE id=?"
+	_, err := s.db.ExecContext(ctx, query, usedAt.UTC(), id)
+	return err
+}
+
+//RevokePersonalAccessToken implementa service.PersonalAccessTokenStore.
+func (s *Store) RevokePersonalAccessToken(ctx context.Context, userID int64, id string, revokedAt time.Time) (bool, error) {
+	query := "UPDATE personal_access_tokens SET revoked_at=? WHERE id=? AND user_id=? AND revoked_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
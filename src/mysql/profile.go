@@ -0,0 +1,165 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//UpdateProfile implementa service.ProfileStore.
+func (s *Store) UpdateProfile(ctx context.Context, userID int64, c service.ProfileChanges) error {
+	var sets []string
+	var args []interface{}
+	set := func(column string, v *string) {
+		if v != nil {
+			sets = append(sets, column+"=?")
+			args = append(args, *v)
+		}
+	}
+
+	set("display_name", c.DisplayName)
+	set("bio", c.Bio)
+	set("location", c.Location)
+	set("website", c.Website)
+	set("password", c.PasswordHash)
+
+	if c.Birthday != nil {
+		var birthday interface{}
+		if *c.Birthday != "" {
+			birthday = *c.Birthday
+		}
+
+		sets = append(sets, "birthday=?")
+		args = append(args, birthday)
+	}
+
+	if len(sets) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	//un solo UPDATE, así que cambian todas las columnas o ninguna
+	query := "UPDATE user SET " + strings.Join(sets, ", ") + " WHERE id=?"
+	if _, err = tx.ExecContext(ctx, query, append(args, userID)...); err != nil {
+		return err
+	}
+
+	if c.PasswordHash != nil {
+		if err = useAccountTokens(ctx, tx, userID, c.UpdatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//CreateEmailChangeToken implementa service.ProfileStore.
+func (s *Store) CreateEmailChangeToken(ctx context.Context, t service.EmailChangeToken) error {
+	query := "INSERT INTO email_change_tokens (token_hash, user_id, email, created_at, expires_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.Hash, t.UserID, t.Email, t.CreatedAt.UTC(), t.ExpiresAt.UTC())
+	return err
+}
+
+//EmailChangeToken implementa service.ProfileStore.
+func (s *Store) EmailChangeToken(ctx context.Context, hash string) (service.EmailChangeToken, error) {
+	t := service.EmailChangeToken{Hash: hash}
+	var usedAt sql.NullTime
+	query := "SELECT user_id, email, created_at, expires_at, used_at FROM email_change_tokens WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.UserID, &t.Email, &t.CreatedAt, &t.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return t, service.ErrInvalidEmailChangeToken
+	}
+
+	t.UsedAt = nullTime(usedAt)
+	return t, err
+}
+
+//CountEmailChangeTokens implementa service.ProfileStore.
+func (s *Store) CountEmailChangeTokens(ctx context.Context, userID int64, since time.Time) (int, error) {
+	var n int
+	query := "SELECT COUNT(*) FROM email_change_tokens WHERE user_id=? AND created_at > ?"
+	err := s.db.QueryRowContext(ctx, query, userID, since.UTC()).Scan(&n)
+	return n, err
+}
+
+//ConfirmEmailChange implementa service.ProfileStore.
+func (s *Store) ConfirmEmailChange(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	query := "UPDATE email_change_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL"
+	res, err := tx.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	//otra petición ya usó el token
+	if n != 1 {
+		return false, nil
+	}
+
+	var userID int64
+	var email string
+	query = "SELECT user_id, email FROM email_change_tokens WHERE token_hash=?"
+	if err = tx.QueryRowContext(ctx, query, hash).Scan(&userID, &email); err != nil {
+		return false, err
+	}
+
+	query = "UPDATE user SET email=?, email_verified_at=? WHERE id=?"
+	_, err = tx.ExecContext(ctx, query, email, usedAt.UTC(), userID)
+	if isDuplicateEntry(err) {
+		return false, service.ErrEmailTaken
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if err = useAccountTokens(ctx, tx, userID, usedAt); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+//useAccountTokens marca como usados en usedAt los tokens de recuperación y
+//de cambio de email pendientes del usuario.
+func useAccountTokens(ctx context.Context, tx *sql.Tx, userID int64, usedAt time.Time) error {
+	for _, table := range []string{"password_reset_tokens", "email_change_tokens"} {
+		query := "UPDATE " + table + " SET used_at=? WHERE user_id=? AND used_at IS NULL"
+		if _, err := tx.ExecContext(ctx, query, usedAt.UTC(), userID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//formatDate devuelve la fecha de una columna date como AAAA-MM-DD, o vacío
+//si es NULL.
+func formatDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+
+	return t.Time.Format("2006-01-02")
+}
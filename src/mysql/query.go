@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sync"
+	"text/template"
+)
+
+var (
+	queriesMu    sync.Mutex
+	queriesCache = make(map[string]*template.Template)
+
+	rxQueryParam = regexp.MustCompile(`@(\w+)`)
+)
+
+//buildQuery aplica data a la plantilla text y cambia cada @nombre por un ?
+//agregando su valor a los argumentos en el orden en que aparecen.
+func buildQuery(text string, data map[string]interface{}) (string, []interface{}, error) {
+	queriesMu.Lock()
+	t, ok := queriesCache[text]
+	if !ok {
+		var err error
+		t, err = template.New("query").Parse(text)
+		if err != nil {
+			queriesMu.Unlock()
+			return "", nil, fmt.Errorf("could not parse sql query template %v", err)
+		}
+
+		queriesCache[text] = t
+	}
+	queriesMu.Unlock()
+
+	var wr bytes.Buffer
+	if err := t.Execute(&wr, data); err != nil {
+		return "", nil, fmt.Errorf("could not apply sql query data: %v", err)
+	}
+
+	var err error
+	args := []interface{}{}
+	query := rxQueryParam.ReplaceAllStringFunc(wr.String(), func(m string) string {
+		val, ok := data[m[1:]]
+		if !ok {
+			err = fmt.Errorf("sql query param %s not found", m)
+			return m
+		}
+
+		args = append(args, val)
+		return "?"
+	})
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return query, args, nil
+}
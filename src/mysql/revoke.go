@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"context"
+	"time"
+)
+
+//RevokeToken implementa service.RevocationStore.
+func (s *Store) RevokeToken(ctx context.Context, userID int64, tokenID string, expiresAt time.Time) error {
+	query := "INSERT IGNORE INTO revoked_tokens (token_id, user_id, expires_at) VALUES (?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, tokenID, userID, expiresAt.UTC())
+	return err
+}
+
+//RevokeUserTokens implementa service.RevocationStore.
+func (s *Store) RevokeUserTokens(ctx context.Context, userID int64, before time.Time) error {
+	query := "INSERT INTO token_revocations (user_id, revoked_before) VALUES (?, ?) " +
+		"ON DUPLICATE KEY UPDATE revoked_before = VALUES(revoked_before)"
+	_, err := s.db.ExecContext(ctx, query, userID, before.UTC())
+	return err
+}
+
+//TokenRevoked implementa service.RevocationStore.
+func (s *Store) TokenRevoked(ctx context.Context, userID int64, tokenID string, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	query := "SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE token_id=?) " +
+		"OR EXISTS(SELECT 1 FROM token_revocations WHERE user_id=? AND revoked_before >= ?)"
+	err := s.db.QueryRowContext(ctx, query, tokenID, userID, issuedAt.UTC()).Scan(&revoked)
+	return revoked, err
+}
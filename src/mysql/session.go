@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//maxUserAgent es el largo de la columna sessions.user_agent.
+const maxUserAgent = 255
+
+//CreateSession implementa service.SessionStore.
+func (s *Store) CreateSession(ctx context.Context, sess service.Session) error {
+	ua := sess.UserAgent
+	if len(ua) > maxUserAgent {
+		ua = ua[:maxUserAgent]
+	}
+
+	query := "INSERT INTO sessions (id, user_id, user_agent, ip, auth_method, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, sess.ID, sess.UserID, ua, sess.IP, sess.AuthMethod,
+		sess.CreatedAt.UTC(), sess.LastSeenAt.UTC(), sess.ExpiresAt.UTC())
+	return err
+}
+
+const sessionColumns = "id, user_id, user_agent, ip, auth_method, created_at, last_seen_at, expires_at, revoked_at"
+
+func scanSession(row interface{ Scan(...interface{}) error }) (service.Session, error) {
+	var sess service.Session
+	var revokedAt sql.NullTime
+	err := row.Scan(&sess.ID, &sess.UserID, &sess.UserAgent, &sess.IP, &sess.AuthMethod,
+		&sess.CreatedAt, &sess.LastSeenAt, &sess.ExpiresAt, &revokedAt)
+	sess.RevokedAt = nullTime(revokedAt)
+	return sess, err
+}
+
+//Session implementa service.SessionStore.
+func (s *Store) Session(ctx context.Context, id string) (service.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id=?"
+	sess, err := scanSession(s.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return sess, service.ErrSessionNotFound
+	}
+
+	return sess, err
+}
+
+//Sessions implementa service.SessionStore.
+func (s *Store) Sessions(ctx context.Context, userID int64, now time.Time) ([]service.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions " +
+		"WHERE user_id=? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC"
+	rows, err := s.db.QueryContext(ctx, query, userID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ss []service.Session
+	for rows.Next() {
+		sess, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escanear la sesión: %v", err)
+		}
+		ss = append(ss, sess)
+	}
+
+	return ss, rows.Err()
+}
+
+//TouchSession implementa service.SessionStore.
+func (s *Store) TouchSession(ctx context.Context, id string, seenAt time.Time, ip string) error {
+	query := "UPDATE sessions SET last_seen_at=?, ip=? WHERE id=?"
+	_, err := s.db.ExecContext(ctx, query, seenAt.UTC(), ip, id)
+	return err
+}
+
+//ExtendSession implementa service.SessionStore.
+func (s *Store) ExtendSession(ctx context.Context, id string, expiresAt time.Time) error {
+	query := "UPDATE sessions SET expires_at=? WHERE id=?"
+	_, err := s.db.ExecContext(ctx, query, expiresAt.UTC(), id)
+	return err
+}
+
+//RevokeSession implementa service.SessionStore.
+func (s *Store) RevokeSession(ctx context.Context, id string, revokedAt time.Time) error {
+	query := "UPDATE sessions SET revoked_at=? WHERE id=? AND revoked_at IS NULL"
+	_, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), id)
+	return err
+}
+
+//RevokeUserSessions implementa service.SessionStore.
+func (s *Store) RevokeUserSessions(ctx context.Context, userID int64, revokedAt time.Time) error {
+	query := "UPDATE sessions SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL"
+	_, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), userID)
+	return err
+}
@@ -0,0 +1,29 @@
+package mysql
+
+import (
+	"database/sql"
+
+	driver "github.com/go-sql-driver/mysql"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//errDuplicateEntry es el código de mysql para una llave única repetida.
+const errDuplicateEntry = 1062
+
+//Store implementa service.Store sobre mysql.
+type Store struct {
+	db *sql.DB
+}
+
+var _ service.Store = (*Store)(nil)
+
+//NewStore crea el almacenamiento sobre una conexión abierta con Connect.
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func isDuplicateEntry(err error) bool {
+	e, ok := err.(*driver.MySQLError)
+	return ok && e.Number == errDuplicateEntry
+}
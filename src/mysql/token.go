@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateRefreshToken implementa service.RefreshTokenStore.
+func (s *Store) CreateRefreshToken(ctx context.Context, t service.RefreshToken) error {
+	query := "INSERT INTO refresh_tokens (token_hash, user_id, family_id, created_at, expires_at) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.Hash, t.UserID, t.FamilyID, t.CreatedAt.UTC(), t.ExpiresAt.UTC())
+	return err
+}
+
+//RefreshToken implementa service.RefreshTokenStore.
+func (s *Store) RefreshToken(ctx context.Context, hash string) (service.RefreshToken, error) {
+	t := service.RefreshToken{Hash: hash}
+	var usedAt, revokedAt sql.NullTime
+
+	query := "SELECT user_id, family_id, created_at, expires_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&t.UserID, &t.FamilyID, &t.CreatedAt, &t.ExpiresAt, &usedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return t, service.ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return t, err
+	}
+
+	t.UsedAt = nullTime(usedAt)
+	t.RevokedAt = nullTime(revokedAt)
+	return t, nil
+}
+
+//UseRefreshToken implementa service.RefreshTokenStore.
+func (s *Store) UseRefreshToken(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE refresh_tokens SET used_at=? WHERE token_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//RevokeRefreshTokenFamily implementa service.RefreshTokenStore.
+func (s *Store) RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	query := "UPDATE refresh_tokens SET revoked_at=? WHERE family_id=? AND revoked_at IS NULL"
+	_, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), familyID)
+	return err
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
+
+//RevokeUserRefreshTokens implementa service.RefreshTokenStore.
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID int64, revokedAt time.Time) error {
+	query := "UPDATE refresh_tokens SET revoked_at=? WHERE user_id=? AND revoked_at IS NULL"
+	_, err := s.db.ExecContext(ctx, query, revokedAt.UTC(), userID)
+	return err
+}
@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//SaveTOTP implementa service.TwoFactorStore.
+func (s *Store) SaveTOTP(ctx context.Context, t service.TOTP) error {
+	var confirmedAt interface{}
+	if t.ConfirmedAt != nil {
+		confirmedAt = t.ConfirmedAt.UTC()
+	}
+
+	query := "REPLACE INTO user_totp (user_id, secret, created_at, confirmed_at, last_step) VALUES (?, ?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, t.UserID, t.Secret, t.CreatedAt.UTC(), confirmedAt, t.LastStep)
+	return err
+}
+
+//TOTP implementa service.TwoFactorStore.
+func (s *Store) TOTP(ctx context.Context, userID int64) (service.TOTP, error) {
+	t := service.TOTP{UserID: userID}
+	var confirmedAt sql.NullTime
+
+	query := "SELECT secret, created_at, confirmed_at, last_step FROM user_totp WHERE user_id=?"
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&t.Secret, &t.CreatedAt, &confirmedAt, &t.LastStep)
+	if err == sql.ErrNoRows {
+		return t, service.ErrTwoFactorNotEnabled
+	}
+
+	t.ConfirmedAt = nullTime(confirmedAt)
+	return t, err
+}
+
+//UseTOTPStep implementa service.TwoFactorStore.
+func (s *Store) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	query := "UPDATE user_totp SET last_step=? WHERE user_id=? AND last_step < ?"
+	res, err := s.db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//DeleteTOTP implementa service.TwoFactorStore.
+func (s *Store) DeleteTOTP(ctx context.Context, userID int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transacción: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM user_totp WHERE user_id=?", userID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=?", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//ReplaceRecoveryCodes implementa service.TwoFactorStore.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transacción: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id=?", userID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO recovery_codes (code_hash, user_id) VALUES (?, ?)"
+	for _, h := range hashes {
+		if _, err = tx.ExecContext(ctx, query, h, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+//UseRecoveryCode implementa service.TwoFactorStore.
+func (s *Store) UseRecoveryCode(ctx context.Context, userID int64, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE recovery_codes SET used_at=? WHERE code_hash=? AND user_id=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash, userID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//CreateTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) CreateTwoFactorChallenge(ctx context.Context, c service.TwoFactorChallenge) error {
+	query := "INSERT INTO two_factor_challenges (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)"
+	_, err := s.db.ExecContext(ctx, query, c.Hash, c.UserID, c.CreatedAt.UTC(), c.ExpiresAt.UTC())
+	return err
+}
+
+//TwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) TwoFactorChallenge(ctx context.Context, hash string) (service.TwoFactorChallenge, error) {
+	c := service.TwoFactorChallenge{Hash: hash}
+	var usedAt sql.NullTime
+
+	query := "SELECT user_id, created_at, expires_at, attempts, used_at FROM two_factor_challenges WHERE token_hash=?"
+	err := s.db.QueryRowContext(ctx, query, hash).Scan(&c.UserID, &c.CreatedAt, &c.ExpiresAt, &c.Attempts, &usedAt)
+	if err == sql.ErrNoRows {
+		return c, service.ErrInvalidTwoFactorChallenge
+	}
+
+	c.UsedAt = nullTime(usedAt)
+	return c, err
+}
+
+//AttemptTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) AttemptTwoFactorChallenge(ctx context.Context, hash string, max int) (bool, error) {
+	query := "UPDATE two_factor_challenges SET attempts=attempts+1 WHERE token_hash=? AND attempts < ?"
+	res, err := s.db.ExecContext(ctx, query, hash, max)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+//UseTwoFactorChallenge implementa service.TwoFactorStore.
+func (s *Store) UseTwoFactorChallenge(ctx context.Context, hash string, usedAt time.Time) (bool, error) {
+	query := "UPDATE two_factor_challenges SET used_at=? WHERE token_hash=? AND used_at IS NULL"
+	res, err := s.db.ExecContext(ctx, query, usedAt.UTC(), hash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,174 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//CreateUser implementa service.UserStore.
+func (s *Store) CreateUser(ctx context.Context, email, username, passwordHash string) (int64, error) {
+	query := "INSERT INTO user (email, username, password) VALUES (?, ?, ?)"
+	res, err := s.db.ExecContext(ctx, query, email, username, passwordHash)
+	if isDuplicateEntry(err) {
+		return 0, service.ErrInvalidUser
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo insertar el usuario: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+//UserByID implementa service.UserStore.
+func (s *Store) UserByID(ctx context.Context, id int64) (service.User, error) {
+	u := service.User{ID: id}
+	query := "SELECT username, avatar FROM user WHERE id=?"
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&u.Username, &u.Avatar)
+	if err == sql.ErrNoRows {
+		return u, service.ErrUserNotFound
+	}
+
+	return u, err
+}
+
+//UserIDByUsername implementa service.UserStore.
+func (s *Store) UserIDByUsername(ctx context.Context, username string) (int64, error) {
+	var id int64
+	query := "SELECT id FROM user WHERE username=?"
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, service.ErrUserNotFound
+	}
+
+	return id, err
+}
+
+//UserProfile implementa service.UserStore.
+func (s *Store) UserProfile(ctx context.Context, viewerID int64, username string) (service.UserProfile, error) {
+	var u service.UserProfile
+	var birthday sql.NullTime
+	auth := viewerID != 0
+
+	args := []interface{}{}
+	dest := []interface{}{&u.ID, &u.Email, &u.Username, &u.DisplayName, &u.Bio, &u.Location, &u.Website,
+		&birthday, &u.FollowersCount, &u.FolloweesCount, &u.Avatar, &u.Cover}
+	query := "SELECT id, email, username, display_name, bio, location, website, birthday, followers_count, followees_count, " +
+		"avatar, cover "
+	if auth {
+		query += ", " +
+			"followers.follower_id IS NOT NULL AS following, " +
+			"followees.followee_id IS NOT NULL AS followeed "
+		dest = append(dest, &u.Following, &u.Followeed)
+	}
+
+	query += "FROM user "
+	if auth {
+		query += "LEFT JOIN follows AS followers ON followers.follower_id = ? AND followers.followee_id = user.id " +
+			"LEFT JOIN follows AS followees ON followees.follower_id = user.id AND followees.followee_id = ? "
+
+		args = append(args, viewerID, viewerID)
+	}
+
+	query += "WHERE username = ?"
+	args = append(args, username)
+
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return u, service.ErrUserNotFound
+	}
+
+	u.Birthday = formatDate(birthday)
+	return u, err
+}
+
+//Users implementa service.UserStore.
+func (s *Store) Users(ctx context.Context, viewerID int64, search string, first int, after string) ([]service.UserProfile, error) {
+	auth := viewerID != 0
+
+	query, args, err := buildQuery(`
+		SELECT id, email, username, display_name, bio, location, website, birthday, followers_count, followees_count,
+			avatar, cover
+		{{if .auth}}
+		,followers.follower_id IS NOT NULL AS following
+		,followees.followee_id IS NOT NULL AS followeed
+		{{end}}
+		FROM user
+		{{if .auth}}
+		LEFT JOIN follows AS followers ON followers.follower_id = @uid AND followers.followee_id = user.id
+		LEFT JOIN follows AS followees ON followees.follower_id = user.id AND followees.followee_id = @uid
+		{{end}}
+		{{if or .search .after}}WHERE{{end}}
+		{{if .search}} username LIKE CONCAT('%', @search, '%'){{end}}
+		{{if and .search .after}}AND{{end}}
+		{{if .after}}username > @after {{end}}
+		ORDER BY username ASC
+		LIMIT @first`, map[string]interface{}{
+		"auth":   auth,
+		"uid":    viewerID,
+		"search": search,
+		"first":  first,
+		"after":  after,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("No se puede construir el query: %v", err)
+	}
+
+	return s.userProfiles(ctx, auth, first, query, args...)
+}
+
+//userProfiles escanea los perfiles que devuelve un query con las columnas de
+//Users.
+func (s *Store) userProfiles(ctx context.Context, auth bool, first int, query string, args ...interface{}) ([]service.UserProfile, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	uu := make([]service.UserProfile, 0, first)
+	for rows.Next() {
+		var u service.UserProfile
+		var birthday sql.NullTime
+		dest := []interface{}{&u.ID, &u.Email, &u.Username, &u.DisplayName, &u.Bio, &u.Location, &u.Website,
+			&birthday, &u.FollowersCount, &u.FolloweesCount, &u.Avatar, &u.Cover}
+		if auth {
+			dest = append(dest, &u.Following, &u.Followeed)
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("No se pudo escanear el query usuarios: %v", err)
+		}
+
+		u.Birthday = formatDate(birthday)
+		uu = append(uu, u)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("No se pueden iterar las filas: %v", err)
+	}
+	return uu, nil
+}
+
+//UpdatePassword implementa service.UserStore.
+func (s *Store) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	query := "UPDATE user SET password=? WHERE id=?"
+	_, err := s.db.ExecContext(ctx, query, passwordHash, userID)
+	return err
+}
+
+//ReplacePasswordHash implementa service.UserStore.
+func (s *Store) ReplacePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) (bool, error) {
+	query := "UPDATE user SET password=? WHERE id=? AND password=?"
+	res, err := s.db.ExecContext(ctx, query, newHash, userID, oldHash)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,101 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//ChangeUsername implementa service.UsernameStore.
+func (s *Store) ChangeUsername(ctx context.Context, userID int64, username string, changedAt, cooldownSince, reservedSince time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
+	}
+
+	defer tx.Rollback()
+
+	var old string
+	query := "SELECT username FROM user WHERE id=? FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&old)
+	if err == sql.ErrNoRows {
+		return service.ErrUserNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var last sql.NullTime
+	query = "SELECT MAX(changed_at) FROM username_history WHERE user_id=?"
+	if err = tx.QueryRowContext(ctx, query, userID).Scan(&last); err != nil {
+		return err
+	}
+
+	if last.Valid && last.Time.After(cooldownSince) {
+		return &service.UsernameCooldownError{RetryAfter: last.Time.Sub(cooldownSince)}
+	}
+
+	var reserved bool
+	query = "SELECT EXISTS(SELECT 1 FROM username_history WHERE username=? AND user_id<>? AND changed_at > ?)"
+	if err = tx.QueryRowContext(ctx, query, username, userID, reservedSince.UTC()).Scan(&reserved); err != nil {
+		return err
+	}
+
+	if reserved {
+		return service.ErrUsernameReserved
+	}
+
+	query = "INSERT INTO username_history (user_id, username, changed_at) VALUES (?, ?, ?)"
+	if _, err = tx.ExecContext(ctx, query, userID, old, changedAt.UTC()); err != nil {
+		return err
+	}
+
+	query = "UPDATE user SET username=? WHERE id=?"
+	_, err = tx.ExecContext(ctx, query, username, userID)
+	if isDuplicateEntry(err) {
+		return service.ErrUsernameTaken
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//UsernameOwner implementa service.UsernameStore.
+func (s *Store) UsernameOwner(ctx context.Context, username string) (int64, error) {
+	var id int64
+	query := "SELECT user_id FROM username_history WHERE username=? ORDER BY changed_at DESC, id DESC LIMIT 1"
+	err := s.db.QueryRowContext(ctx, query, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, service.ErrUserNotFound
+	}
+
+	return id, err
+}
+
+//UsernameReserved implementa service.UsernameStore.
+func (s *Store) UsernameReserved(ctx context.Context, username string, exceptID int64, since time.Time) (bool, error) {
+	var reserved bool
+	query := "SELECT EXISTS(SELECT 1 FROM username_history WHERE username=? AND user_id<>? AND changed_at > ?)"
+	err := s.db.QueryRowContext(ctx, query, username, exceptID, since.UTC()).Scan(&reserved)
+	return reserved, err
+}
+
+//PurgeUsernameHistory implementa service.UsernameStore.
+func (s *Store) PurgeUsernameHistory(ctx context.Context, before time.Time, limit int) (int, error) {
+	query := "DELETE FROM username_history WHERE changed_at < ? " +
+		"AND NOT EXISTS (SELECT 1 FROM user WHERE user.id = username_history.user_id) LIMIT ?"
+	res, err := s.db.ExecContext(ctx, query, before.UTC(), limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
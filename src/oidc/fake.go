@@ -0,0 +1,219 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Fake es un proveedor OpenID Connect falso para probar el login en local.
+//Aprueba todos los logins sin preguntar, con el email que llegue en
+//login_hint o con Email, y firma los ID tokens con una llave RSA que genera
+//al crearse.
+type Fake struct {
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	Email         string
+	EmailVerified bool
+
+	key   *rsa.PrivateKey
+	kid   string
+	mu    sync.Mutex
+	codes map[string]fakeCode
+}
+
+type fakeCode struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	email         string
+	expiresAt     time.Time
+}
+
+//NewFake crea el proveedor falso.
+func NewFake(issuer, clientID, clientSecret, email string) (*Fake, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	//cada llave tiene su kid, como al rotar, para que los clientes que
+	//guardaron la anterior pidan la nueva
+	sum := sha256.Sum256(key.N.Bytes())
+
+	return &Fake{
+		Issuer:        strings.TrimSuffix(issuer, "/"),
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Email:         email,
+		EmailVerified: true,
+		key:           key,
+		kid:           base64.RawURLEncoding.EncodeToString(sum[:8]),
+		codes:         make(map[string]fakeCode),
+	}, nil
+}
+
+func (f *Fake) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		writeJSON(w, discovery{
+			Issuer:                f.Issuer,
+			AuthorizationEndpoint: f.Issuer + "/authorize",
+			TokenEndpoint:         f.Issuer + "/token",
+			JWKSURI:               f.Issuer + "/jwks",
+		}, http.StatusOK)
+	case "/jwks":
+		writeJSON(w, map[string][]jwk{"keys": {{
+			Kty: "RSA",
+			Kid: f.kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}}}, http.StatusOK)
+	case "/authorize":
+		f.authorize(w, r)
+	case "/token":
+		f.token(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+//authorize aprueba el login y vuelve a redirect_uri con el código.
+func (f *Fake) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != f.ClientID || q.Get("response_type") != "code" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("redirect_uri") == "" {
+		http.Error(w, "solicitud inválida", http.StatusBadRequest)
+		return
+	}
+
+	email := q.Get("login_hint")
+	if email == "" {
+		email = f.Email
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	f.mu.Lock()
+	f.codes[code] = fakeCode{
+		redirectURI:   q.Get("redirect_uri"),
+		nonce:         q.Get("nonce"),
+		codeChallenge: q.Get("code_challenge"),
+		email:         email,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	f.mu.Unlock()
+
+	u, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	v := u.Query()
+	v.Set("code", code)
+	v.Set("state", q.Get("state"))
+	u.RawQuery = v.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+//token cambia el código por un ID token, comprobando el secreto y PKCE.
+func (f *Fake) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, map[string]string{"error": "invalid_request"}, http.StatusBadRequest)
+		return
+	}
+
+	id, secret, _ := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if id != f.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(f.ClientSecret)) != 1 {
+		writeJSON(w, map[string]string{"error": "invalid_client"}, http.StatusUnauthorized)
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	f.mu.Lock()
+	c, ok := f.codes[code]
+	delete(f.codes, code)
+	f.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || time.Now().After(c.expiresAt) || c.redirectURI != r.PostForm.Get("redirect_uri") ||
+		c.codeChallenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		writeJSON(w, map[string]string{"error": "invalid_grant"}, http.StatusBadRequest)
+		return
+	}
+
+	idToken, err := f.sign(c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"access_token": "fake",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	}, http.StatusOK)
+}
+
+//sign firma el ID token del código con RS256. El sub sale del email para
+//que el mismo email sea siempre la misma identidad.
+func (f *Fake) sign(c fakeCode) (string, error) {
+	now := time.Now()
+	sub := sha256.Sum256([]byte(strings.ToLower(c.email)))
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": f.kid, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss":                f.Issuer,
+		"sub":                base64.RawURLEncoding.EncodeToString(sub[:12]),
+		"aud":                f.ClientID,
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              c.nonce,
+		"email":              c.email,
+		"email_verified":     f.EmailVerified,
+		"preferred_username": strings.SplitN(c.email, "@", 2)[0],
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, status int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,498 @@
+//Package oidc inicia sesión con proveedores OpenID Connect externos. Cada
+//proveedor se descubre en <issuer>/.well-known/openid-configuration, el
+//código se cambia con PKCE y el ID token se verifica con las llaves públicas
+//(JWKS) del proveedor. Provider implementa service.IdentityProvider.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+//clockSkew es la diferencia de reloj que se tolera con el proveedor.
+const clockSkew = time.Minute
+
+//jwksRefreshInterval es cada cuánto se pueden volver a pedir las llaves del
+//proveedor cuando llega un token firmado con una llave desconocida.
+const jwksRefreshInterval = time.Minute
+
+var rxProviderName = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+//ErrInvalidIDToken cuando el ID token no se puede verificar.
+var ErrInvalidIDToken = errors.New("ID token inválido")
+
+//Config es un proveedor en el archivo de proveedores.
+type Config struct {
+	Name         string   `yaml:"name"`
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+//File es el archivo de proveedores.
+type File struct {
+	Providers []Config `yaml:"providers"`
+}
+
+//Load lee el archivo YAML de proveedores.
+func Load(path string) ([]Config, error) {
+	var f File
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = yaml.UnmarshalStrict(b, &f); err != nil {
+		return nil, fmt.Errorf("no se pudo leer los proveedores %s: %v", path, err)
+	}
+
+	seen := make(map[string]bool, len(f.Providers))
+	for _, c := range f.Providers {
+		if err = c.Validate(); err != nil {
+			return nil, err
+		}
+
+		if seen[c.Name] {
+			return nil, fmt.Errorf("proveedor repetido: %s", c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	return f.Providers, nil
+}
+
+//Validate revisa que el proveedor tenga todo lo necesario. El issuer y el
+//callback deben ser https, salvo en localhost para probar con un proveedor
+//falso.
+func (c Config) Validate() error {
+	if !rxProviderName.MatchString(c.Name) {
+		return fmt.Errorf("nombre de proveedor inválido: %q", c.Name)
+	}
+
+	if !secureURL(c.Issuer) {
+		return fmt.Errorf("proveedor %s: issuer debe ser una URL https", c.Name)
+	}
+
+	if !secureURL(c.RedirectURL) {
+		return fmt.Errorf("proveedor %s: redirect_url debe ser una URL https", c.Name)
+	}
+
+	if c.ClientID == "" {
+		return fmt.Errorf("proveedor %s: client_id es obligatorio", c.Name)
+	}
+
+	return nil
+}
+
+func secureURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+
+	host := u.Hostname()
+	return u.Scheme == "https" || (u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1"))
+}
+
+//Provider es un proveedor OpenID Connect. Descubre sus endpoints la primera
+//vez que se usa y guarda sus llaves públicas.
+type Provider struct {
+	cfg    Config
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *discovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+var _ service.IdentityProvider = (*Provider)(nil)
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+//New crea el proveedor.
+func New(cfg Config) *Provider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+//Name implementa service.IdentityProvider.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+//AuthCodeURL implementa service.IdentityProvider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(codeVerifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(sum[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+//Exchange implementa service.IdentityProvider.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (service.ExternalIdentity, error) {
+	var id service.ExternalIdentity
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return id, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return id, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var out struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	status, err := p.do(req, &out)
+	if err != nil {
+		return id, fmt.Errorf("no se pudo cambiar el código con %s: %v", p.cfg.Name, err)
+	}
+
+	if status != http.StatusOK || out.IDToken == "" {
+		return id, fmt.Errorf("%s rechazó el código: %d %s", p.cfg.Name, status, out.Error)
+	}
+
+	return p.verify(ctx, d, out.IDToken, nonce)
+}
+
+//idTokenClaims son los claims del ID token que se usan.
+type idTokenClaims struct {
+	Issuer            string          `json:"iss"`
+	Subject           string          `json:"sub"`
+	Audience          json.RawMessage `json:"aud"`
+	AuthorizedParty   string          `json:"azp"`
+	ExpiresAt         int64           `json:"exp"`
+	IssuedAt          int64           `json:"iat"`
+	Nonce             string          `json:"nonce"`
+	Email             string          `json:"email"`
+	EmailVerified     interface{}     `json:"email_verified"`
+	Name              string          `json:"name"`
+	PreferredUsername string          `json:"preferred_username"`
+}
+
+//verify revisa la firma y los claims del ID token y devuelve la identidad.
+func (p *Provider) verify(ctx context.Context, d *discovery, token, nonce string) (service.ExternalIdentity, error) {
+	var id service.ExternalIdentity
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return id, ErrInvalidIDToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return id, ErrInvalidIDToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return id, ErrInvalidIDToken
+	}
+
+	key, err := p.key(ctx, d, header.Kid)
+	if err != nil {
+		return id, err
+	}
+
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, sum[:], sig) {
+		return id, ErrInvalidIDToken
+	}
+
+	var c idTokenClaims
+	if err = decodeSegment(parts[1], &c); err != nil {
+		return id, ErrInvalidIDToken
+	}
+
+	now := time.Now()
+	switch {
+	case c.Issuer != d.Issuer:
+		return id, fmt.Errorf("%v: issuer %q", ErrInvalidIDToken, c.Issuer)
+	case !audienceContains(c.Audience, p.cfg.ClientID, c.AuthorizedParty):
+		return id, fmt.Errorf("%v: audiencia", ErrInvalidIDToken)
+	case now.After(time.Unix(c.ExpiresAt, 0).Add(clockSkew)):
+		return id, fmt.Errorf("%v: expirado", ErrInvalidIDToken)
+	case time.Unix(c.IssuedAt, 0).After(now.Add(clockSkew)):
+		return id, fmt.Errorf("%v: emitido en el futuro", ErrInvalidIDToken)
+	case c.Nonce != nonce:
+		return id, fmt.Errorf("%v: nonce", ErrInvalidIDToken)
+	case c.Subject == "":
+		return id, fmt.Errorf("%v: sin sub", ErrInvalidIDToken)
+	}
+
+	return service.ExternalIdentity{
+		Subject:           c.Subject,
+		Email:             c.Email,
+		EmailVerified:     c.EmailVerified == true || c.EmailVerified == "true",
+		Name:              c.Name,
+		PreferredUsername: c.PreferredUsername,
+	}, nil
+}
+
+//audienceContains acepta aud como texto o como lista; con varias audiencias
+//exige que azp sea la aplicación.
+func audienceContains(raw json.RawMessage, clientID, azp string) bool {
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		return one == clientID
+	}
+
+	var many []string
+	if json.Unmarshal(raw, &many) != nil {
+		return false
+	}
+
+	for _, aud := range many {
+		if aud == clientID {
+			return len(many) == 1 || azp == clientID
+		}
+	}
+
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+
+	return false
+}
+
+//discover pide la configuración del proveedor la primera vez, y de nuevo
+//si la vez anterior falló.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d discovery
+	status, err := p.do(req, &d)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("no se pudo descubrir el proveedor %s: %d %v", p.cfg.Name, status, err)
+	}
+
+	if d.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("el proveedor %s dice ser %q y no %q", p.cfg.Name, d.Issuer, p.cfg.Issuer)
+	}
+
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("al proveedor %s le faltan endpoints", p.cfg.Name)
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+//key devuelve la llave kid del proveedor. Si no la conoce vuelve a pedir las
+//llaves, como mucho una vez por jwksRefreshInterval, porque el proveedor
+//pudo haberlas rotado.
+func (p *Provider) key(ctx context.Context, d *discovery, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(p.keysFetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("%v: llave %q desconocida", ErrInvalidIDToken, kid)
+	}
+
+	p.keysFetched = time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", d.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("no se pudieron pedir las llaves de %s: %d %v", p.cfg.Name, status, err)
+	}
+
+	p.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if pub, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = pub
+		}
+	}
+
+	k, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%v: llave %q desconocida", ErrInvalidIDToken, kid)
+	}
+
+	return k, nil
+}
+
+func (p *Provider) do(req *http.Request, v interface{}) (int, error) {
+	res, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode, json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+//jwk es una llave pública en formato JWK. Solo se aceptan RSA y EC P-256
+//de firma.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, errors.New("la llave no es de firma")
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("exponente inválido")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("curva no soportada %s", k.Crv)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+
+	return nil, fmt.Errorf("tipo de llave no soportado %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("entero inválido")
+	}
+
+	return new(big.Int).SetBytes(b), nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
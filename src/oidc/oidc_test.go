@@ -0,0 +1,280 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/keyring"
+	"github.com/Mynor2397/social-network/src/memory"
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+const (
+	testClientID = "app"
+	testNonce    = "nonce-de-prueba"
+)
+
+//newTestProvider levanta el proveedor falso en un servidor de prueba y
+//devuelve el proveedor falso y el cliente configurado contra él.
+func newTestProvider(t *testing.T) (*Fake, *Provider) {
+	t.Helper()
+
+	var f *Fake
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	f, err := NewFake(srv.URL, testClientID, "secreto", "ana@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{
+		Name:         "fake",
+		Issuer:       srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secreto",
+		RedirectURL:  "http://localhost/callback",
+	})
+
+	return f, p
+}
+
+//signTestToken firma claims con la llave del proveedor falso y el kid dado.
+func signTestToken(t *testing.T, f *Fake, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+//validClaims son los claims de un ID token que verify acepta.
+func validClaims(f *Fake) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            f.Issuer,
+		"sub":            "sujeto",
+		"aud":            testClientID,
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          testNonce,
+		"email":          "ana@example.com",
+		"email_verified": true,
+	}
+}
+
+//authorizeCode sigue authURL en el proveedor falso y devuelve el código con
+//el que redirige.
+func authorizeCode(t *testing.T, authURL string) string {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("el proveedor respondió %d", res.StatusCode)
+	}
+
+	u, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u.Query().Get("code")
+}
+
+func TestExchangeVerifiesIDToken(t *testing.T) {
+	ctx := context.Background()
+	_, p := newTestProvider(t)
+
+	authURL, err := p.AuthCodeURL(ctx, "state", testNonce, strings.Repeat("v", 43))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := p.Exchange(ctx, authorizeCode(t, authURL), strings.Repeat("v", 43), testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id.Subject == "" || id.Email != "ana@example.com" || !id.EmailVerified {
+		t.Fatalf("identidad inesperada: %+v", id)
+	}
+
+	authURL, err = p.AuthCodeURL(ctx, "state", "otro-nonce", strings.Repeat("v", 43))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.Exchange(ctx, authorizeCode(t, authURL), strings.Repeat("v", 43), testNonce)
+	if err == nil || !strings.HasPrefix(err.Error(), ErrInvalidIDToken.Error()) {
+		t.Fatalf("se esperaba ErrInvalidIDToken con otro nonce, se obtuvo %v", err)
+	}
+}
+
+func TestVerifyRejectsInvalidIDTokens(t *testing.T) {
+	ctx := context.Background()
+	f, p := newTestProvider(t)
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.verify(ctx, d, signTestToken(t, f, f.kid, validClaims(f)), testNonce); err != nil {
+		t.Fatalf("el token válido debería pasar: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		kid    string
+		change func(c map[string]interface{})
+	}{
+		{"aud de otra aplicación", f.kid, func(c map[string]interface{}) { c["aud"] = "otra" }},
+		{"varias aud sin azp", f.kid, func(c map[string]interface{}) { c["aud"] = []string{testClientID, "otra"} }},
+		{"azp de otra aplicación", f.kid, func(c map[string]interface{}) {
+			c["aud"] = []string{testClientID, "otra"}
+			c["azp"] = "otra"
+		}},
+		{"nonce distinto", f.kid, func(c map[string]interface{}) { c["nonce"] = "otro" }},
+		{"expirado", f.kid, func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * clockSkew).Unix() }},
+		{"otro issuer", f.kid, func(c map[string]interface{}) { c["iss"] = "https://otro.example.com" }},
+		{"kid desconocido", "desconocido", func(c map[string]interface{}) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validClaims(f)
+			tt.change(c)
+
+			_, err := p.verify(ctx, d, signTestToken(t, f, tt.kid, c), testNonce)
+			if err == nil || !strings.HasPrefix(err.Error(), ErrInvalidIDToken.Error()) {
+				t.Fatalf("se esperaba ErrInvalidIDToken, se obtuvo %v", err)
+			}
+		})
+	}
+
+	t.Run("firma de otra llave", func(t *testing.T) {
+		other, err := NewFake(f.Issuer, testClientID, "secreto", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = p.verify(ctx, d, signTestToken(t, other, f.kid, validClaims(f)), testNonce); err != ErrInvalidIDToken {
+			t.Fatalf("se esperaba ErrInvalidIDToken, se obtuvo %v", err)
+		}
+	})
+}
+
+//discardMailer no envía los correos.
+type discardMailer struct{}
+
+func (discardMailer) Send(ctx context.Context, msg service.Mail) error {
+	return nil
+}
+
+func TestFinishOIDCLoginLinksVerifiedEmail(t *testing.T) {
+	ctx := context.Background()
+	f, p := newTestProvider(t)
+
+	codec, err := keyring.New(keyring.File{
+		Active: "test",
+		Keys:   []keyring.Key{{ID: "test", Secret: "llave-de-prueba-de-32-caracteres", CreatedAt: time.Now()}},
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	s := service.New(store, codec, discardMailer{}, service.Config{IdentityProviders: []service.IdentityProvider{p}})
+
+	//CreateUser devuelve ErrUserOk cuando crea al usuario
+	if err = s.CreateUser(ctx, "ana@example.com", "ana", "Tortuga-verde-42"); err != service.ErrUserOk {
+		t.Fatal(err)
+	}
+
+	uid, err := store.UserIDByUsername(ctx, "ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() (service.LoginOutput, error) {
+		authURL, state, err := s.StartOIDCLogin(ctx, "fake")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return s.FinishOIDCLogin(ctx, "fake", state, authorizeCode(t, authURL))
+	}
+
+	//la cuenta aún no verificó el email
+	if _, err = login(); err != service.ErrOIDCAccountConflict {
+		t.Fatalf("se esperaba ErrOIDCAccountConflict, se obtuvo %v", err)
+	}
+
+	if _, err = store.MarkEmailVerified(ctx, uid, "ana@example.com", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	//el proveedor no verificó el email
+	f.EmailVerified = false
+	if _, err = login(); err != service.ErrOIDCEmailNotVerified {
+		t.Fatalf("se esperaba ErrOIDCEmailNotVerified, se obtuvo %v", err)
+	}
+
+	f.EmailVerified = true
+	out, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out.AuthUser.ID != uid {
+		t.Fatalf("se enlazó con el usuario %d y no con %d", out.AuthUser.ID, uid)
+	}
+
+	linked, err := store.IdentityUserID(ctx, "fake", fakeSubject("ana@example.com"))
+	if err != nil || linked != uid {
+		t.Fatalf("la identidad no quedó enlazada: %d %v", linked, err)
+	}
+}
+
+//fakeSubject es el sub que el proveedor falso da al email.
+func fakeSubject(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
@@ -0,0 +1,86 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+//prefixSize es el largo del prefijo de los rangos, el mismo de la API de
+//Have I Been Pwned.
+const prefixSize = 5
+
+//BreachList es una lista local de contraseñas filtradas por su hash SHA-1,
+//como las que publica Have I Been Pwned. Se consulta por rangos de prefijo,
+//igual que la API con k-anonimato, así que se puede cambiar por un servicio
+//remoto sin mandarle nunca el hash completo.
+type BreachList struct {
+	ranges map[string][]string
+}
+
+//LoadBreachList lee el archivo de hashes, uno por línea como HASH o
+//HASH:CUENTA, sin importar mayúsculas. Las líneas vacías y las que empiezan
+//con # se ignoran.
+func LoadBreachList(path string) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l := &BreachList{ranges: make(map[string][]string)}
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash := strings.ToUpper(strings.SplitN(line, ":", 2)[0])
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != 2*sha1.Size {
+			return nil, fmt.Errorf("%s:%d: hash SHA-1 inválido", path, n)
+		}
+
+		l.ranges[hash[:prefixSize]] = append(l.ranges[hash[:prefixSize]], hash[prefixSize:])
+	}
+
+	if err = sc.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range l.ranges {
+		sort.Strings(r)
+	}
+
+	return l, nil
+}
+
+//Len devuelve cuántos hashes tiene la lista.
+func (l *BreachList) Len() int {
+	n := 0
+	for _, r := range l.ranges {
+		n += len(r)
+	}
+
+	return n
+}
+
+//Range devuelve, ordenados, los sufijos de los hashes que empiezan con el
+//prefijo de 5 caracteres.
+func (l *BreachList) Range(prefix string) []string {
+	return l.ranges[strings.ToUpper(prefix)]
+}
+
+//Breached devuelve si la contraseña está en la lista.
+func (l *BreachList) Breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	r := l.Range(hash[:prefixSize])
+	i := sort.SearchStrings(r, hash[prefixSize:])
+	return i < len(r) && r[i] == hash[prefixSize:], nil
+}
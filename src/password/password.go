@@ -0,0 +1,209 @@
+//Package password hashea las contraseñas con bcrypt o Argon2id. Los hashes
+//de Argon2id usan el formato PHC ($argon2id$v=19$m=...,t=...,p=...$sal$hash)
+//y los que se hicieron con pepper llevan el prefijo $pepper, así que un
+//mismo almacenamiento puede tener hashes de los dos algoritmos, con y sin
+//pepper, y Verify avisa cuáles hay que volver a generar. BreachList revisa
+//las contraseñas contra una lista local de contraseñas filtradas.
+package password
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	//Bcrypt es el algoritmo bcrypt.
+	Bcrypt = "bcrypt"
+
+	//Argon2id es el algoritmo Argon2id, el que se usa por defecto.
+	Argon2id = "argon2id"
+
+	pepperPrefix = "$pepper"
+	argon2Prefix = "$argon2id$"
+	saltSize     = 16
+	keySize      = 32
+)
+
+//ErrUnknownAlgorithm cuando el algoritmo no es Bcrypt ni Argon2id.
+var ErrUnknownAlgorithm = errors.New("algoritmo de contraseñas desconocido")
+
+//Hasher genera y verifica hashes de contraseñas. Los valores en cero se
+//cambian por los valores por defecto.
+type Hasher struct {
+	//Algorithm es el algoritmo de los hashes nuevos.
+	Algorithm string
+
+	//BcryptCost es el costo de bcrypt.
+	BcryptCost int
+
+	//Argon2Time, Argon2Memory (en KiB) y Argon2Threads son los parámetros
+	//de Argon2id.
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+
+	//Pepper es un secreto del servidor que se mezcla con la contraseña antes
+	//de hashearla, para que los hashes no sirvan sin él.
+	Pepper string
+}
+
+func (h Hasher) algorithm() string {
+	if h.Algorithm == "" {
+		return Argon2id
+	}
+
+	return h.Algorithm
+}
+
+func (h Hasher) bcryptCost() int {
+	if h.BcryptCost == 0 {
+		return bcrypt.DefaultCost
+	}
+
+	return h.BcryptCost
+}
+
+//argon2Params devuelve los parámetros de Argon2id; por defecto son los que
+//recomienda OWASP.
+func (h Hasher) argon2Params() (uint32, uint32, uint8) {
+	t, m, p := h.Argon2Time, h.Argon2Memory, h.Argon2Threads
+	if t == 0 {
+		t = 2
+	}
+
+	if m == 0 {
+		m = 19 * 1024
+	}
+
+	if p == 0 {
+		p = 1
+	}
+
+	return t, m, p
+}
+
+//Hash devuelve el hash de la contraseña con el algoritmo y el pepper
+//configurados.
+func (h Hasher) Hash(password string) (string, error) {
+	input := h.input(password, h.Pepper != "")
+
+	var hash string
+	switch h.algorithm() {
+	case Bcrypt:
+		b, err := bcrypt.GenerateFromPassword(input, h.bcryptCost())
+		if err != nil {
+			return "", err
+		}
+
+		hash = string(b)
+	case Argon2id:
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		t, m, p := h.argon2Params()
+		key := argon2.IDKey(input, salt, t, m, p, keySize)
+		hash = fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, m, t, p,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	default:
+		return "", ErrUnknownAlgorithm
+	}
+
+	if h.Pepper != "" {
+		hash = pepperPrefix + hash
+	}
+
+	return hash, nil
+}
+
+//Verify devuelve si la contraseña corresponde al hash y, si corresponde, si
+//hay que volver a generarlo porque usa otro algoritmo, otros parámetros o
+//no coincide en el uso del pepper. Un hash vacío o mal formado no
+//corresponde con ninguna contraseña.
+func (h Hasher) Verify(password, hash string) (bool, bool) {
+	peppered := strings.HasPrefix(hash, pepperPrefix+"$")
+	if peppered {
+		if h.Pepper == "" {
+			return false, false
+		}
+
+		hash = strings.TrimPrefix(hash, pepperPrefix)
+	}
+
+	input := h.input(password, peppered)
+	rehash := peppered != (h.Pepper != "")
+
+	if strings.HasPrefix(hash, argon2Prefix) {
+		ok, current := h.verifyArgon2(input, hash)
+		return ok, ok && (rehash || !current)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), input) != nil {
+		return false, false
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, rehash || err != nil || h.algorithm() != Bcrypt || cost != h.bcryptCost()
+}
+
+//verifyArgon2 compara la contraseña con un hash de Argon2id y devuelve si
+//sus parámetros son los configurados.
+func (h Hasher) verifyArgon2(input []byte, hash string) (bool, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+
+	var version int
+	var t, m uint32
+	var p uint8
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &m, &t, &p); err != nil || t == 0 || p == 0 {
+		return false, false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+
+	other := argon2.IDKey(input, salt, t, m, p, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false
+	}
+
+	ct, cm, cp := h.argon2Params()
+	current := h.algorithm() == Argon2id && t == ct && m == cm && p == cp &&
+		len(salt) == saltSize && len(key) == keySize
+	return true, current
+}
+
+//input es lo que se hashea: la contraseña, o su HMAC con el pepper. El HMAC
+//además deja las contraseñas largas dentro de los 72 bytes de bcrypt.
+func (h Hasher) input(password string, peppered bool) []byte {
+	if !peppered {
+		return []byte(password)
+	}
+
+	mac := hmac.New(sha256.New, []byte(h.Pepper))
+	mac.Write([]byte(password))
+	return []byte(base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+}
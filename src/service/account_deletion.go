@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+//purgeBatchSize es cuántas cuentas borra como máximo cada pasada de
+//PurgeDeletedAccounts.
+const purgeBatchSize = 100
+
+//AccountDeletion es el borrado pendiente de la cuenta del usuario.
+type AccountDeletion struct {
+	DeleteAt time.Time `json:"delete_at"`
+}
+
+//DeletionStore guarda los borrados de cuentas pendientes.
+type DeletionStore interface {
+	//ScheduleDeletion programa el borrado de la cuenta para at.
+	ScheduleDeletion(ctx context.Context, userID int64, at time.Time) error
+
+	//CancelDeletion cancela el borrado pendiente de la cuenta y devuelve si
+	//había uno.
+	CancelDeletion(ctx context.Context, userID int64) (bool, error)
+
+	//DueDeletions devuelve hasta limit cuentas con el borrado programado
+	//antes de now.
+	DueDeletions(ctx context.Context, now time.Time, limit int) ([]int64, error)
+}
+
+//DeleteAccount programa el borrado de la cuenta del usuario autenticado para
+//dentro de DeletionGracePeriod y cierra todas sus sesiones. Iniciar
+//sesión antes de esa fecha cancela el borrado. Si la cuenta tiene contraseña
+//hay que darla para confirmar.
+func (s *Service) DeleteAccount(ctx context.Context, password string) (AccountDeletion, error) {
+	var out AccountDeletion
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	_, hash, err := s.store.Credentials(ctx, email)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar la contraseña: %v", err)
+	}
+
+	//las cuentas creadas con un proveedor externo no tienen contraseña
+	if hash != "" {
+		if err = s.checkCurrentPassword(ctx, email, password, hash); err != nil {
+			return out, err
+		}
+	}
+
+	out.DeleteAt = time.Now().Add(s.cfg.DeletionGracePeriod)
+	if err = s.store.ScheduleDeletion(ctx, uid, out.DeleteAt); err != nil {
+		return out, fmt.Errorf("no se pudo programar el borrado de la cuenta: %v", err)
+	}
+
+	if err = s.revokeUserTokens(ctx, uid); err != nil {
+		return out, err
+	}
+
+	err = s.mailer.Send(ctx, Mail{
+		To:      email,
+		Subject: "Su cuenta será borrada",
+		Body: fmt.Sprintf("Hola,\n\n"+
+			"Su cuenta y todos sus datos se borrarán el %s.\n\n"+
+			"Si cambia de opinión, inicie sesión antes de esa fecha y el borrado se cancelará.\n",
+			out.DeleteAt.UTC().Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Printf("no se pudo avisar el borrado de la cuenta al usuario %d: %v", uid, err)
+	}
+
+	return out, nil
+}
+
+//cancelDeletion cancela el borrado pendiente del usuario al iniciar sesión y
+//devuelve si había uno.
+func (s *Service) cancelDeletion(ctx context.Context, uid int64) (bool, error) {
+	cancelled, err := s.store.CancelDeletion(ctx, uid)
+	if err != nil {
+		return false, fmt.Errorf("no se pudo cancelar el borrado de la cuenta: %v", err)
+	}
+
+	return cancelled, nil
+}
+
+//PurgeDeletedAccounts borra las cuentas cuyo periodo de gracia terminó, con
+//sus follows, los contadores de los usuarios que seguían o las seguían, sus
+//imágenes y el resto de sus datos. Devuelve cuántas borró; se llama
+//periódicamente hasta que no quede ninguna.
+func (s *Service) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	ids, err := s.store.DueDeletions(ctx, time.Now(), purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudieron consultar las cuentas por borrar: %v", err)
+	}
+
+	n := 0
+	for _, uid := range ids {
+		err = s.deleteUser(ctx, uid)
+		if err == ErrUserNotFound {
+			continue
+		}
+
+		if err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+//deleteUser borra al usuario con sus imágenes, sus exportaciones y los
+//intentos de login fallidos de su email, que no dependen de su id. Sus
+//usernames quedan reservados como si los hubiera cambiado.
+func (s *Service) deleteUser(ctx context.Context, uid int64) error {
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err == ErrUserNotFound {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if err = s.deleteUserImages(ctx, uid); err != nil {
+		return err
+	}
+
+	if err = s.deleteUserDataExports(ctx, uid); err != nil {
+		return err
+	}
+
+	if err = s.store.ClearLoginFailures(ctx, accountThrottleKey(email)); err != nil {
+		return fmt.Errorf("no se pudieron borrar los intentos de login: %v", err)
+	}
+
+	err = s.store.DeleteUser(ctx, uid, time.Now())
+	if err == ErrUserNotFound {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo borrar al usuario %d: %v", uid, err)
+	}
+
+	return nil
+}
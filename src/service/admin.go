@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	//RoleAdmin puede hacer todo en la administración.
+	RoleAdmin = "admin"
+
+	//RoleModerator puede consultar, suspender y desbloquear usuarios.
+	RoleModerator = "moderator"
+)
+
+const (
+	//PermListUsers permite listar los usuarios con sus datos privados.
+	PermListUsers = "users:list"
+
+	//PermSuspendUsers permite suspender y reactivar usuarios.
+	PermSuspendUsers = "users:suspend"
+
+	//PermUnlockLogins permite desbloquear el login de una cuenta.
+	PermUnlockLogins = "users:unlock"
+
+	//PermResetPasswords permite obligar a un usuario a cambiar su contraseña.
+	PermResetPasswords = "users:reset_password"
+
+	//PermDeleteUsers permite borrar cuentas.
+	PermDeleteUsers = "users:delete"
+
+	//PermManageRoles permite cambiar los roles de los usuarios.
+	PermManageRoles = "roles:write"
+
+	//PermReadAudit permite consultar el registro de acciones administrativas.
+	PermReadAudit = "audit:read"
+)
+
+//roleRanks ordenan los roles: solo se puede moderar a un usuario cuyo rol
+//más alto esté por debajo del propio. Un usuario sin roles tiene rango cero.
+var roleRanks = map[string]int{
+	RoleModerator: 1,
+	RoleAdmin:     2,
+}
+
+//rolePermissions son los permisos de cada rol. Un usuario sin roles no tiene
+//ningún permiso administrativo.
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermListUsers, PermSuspendUsers, PermUnlockLogins, PermResetPasswords,
+		PermDeleteUsers, PermManageRoles, PermReadAudit,
+	},
+	RoleModerator: {
+		PermListUsers, PermSuspendUsers, PermUnlockLogins, PermReadAudit,
+	},
+}
+
+var (
+	//ErrForbidden cuando el usuario no tiene el permiso que pide la acción.
+	ErrForbidden = errors.New("no tiene permiso para esta acción")
+
+	//ErrAccountSuspended cuando la cuenta está suspendida.
+	ErrAccountSuspended = errors.New("la cuenta está suspendida")
+
+	//ErrCannotModerateSelf cuando un administrador intenta suspenderse,
+	//borrarse o quitarse roles a sí mismo.
+	ErrCannotModerateSelf = errors.New("no puede hacer esta acción sobre su propia cuenta")
+
+	//ErrInvalidRole cuando se asigna un rol que no existe.
+	ErrInvalidRole = errors.New("rol inválido")
+
+	//ErrInvalidReason cuando el motivo es demasiado largo.
+	ErrInvalidReason = errors.New("el motivo no puede pasar de 255 caracteres")
+)
+
+//AdminUser es un usuario como lo ve la administración.
+type AdminUser struct {
+	ID             int64      `json:"id"`
+	Email          string     `json:"email"`
+	Username       string     `json:"username"`
+	Roles          []string   `json:"roles"`
+	EmailVerified  bool       `json:"email_verified"`
+	SuspendedAt    *time.Time `json:"suspended_at"`
+	FollowersCount int        `json:"followers_count"`
+	FolloweesCount int        `json:"followees_count"`
+}
+
+//RoleStore guarda los roles de los usuarios.
+type RoleStore interface {
+	//UserRoles devuelve los roles del usuario ordenados.
+	UserRoles(ctx context.Context, userID int64) ([]string, error)
+
+	//SetUserRoles reemplaza los roles del usuario.
+	SetUserRoles(ctx context.Context, userID int64, roles []string) error
+}
+
+//AdminStore consulta y modifica cuentas para la administración.
+type AdminStore interface {
+	//AdminUsers devuelve hasta first usuarios ordenados por username,
+	//filtrados por search y posteriores al username after.
+	AdminUsers(ctx context.Context, search string, first int, after string) ([]AdminUser, error)
+
+	//SetUserSuspended suspende al usuario desde suspendedAt, o lo reactiva
+	//si es nil. Devuelve ErrUserNotFound si no existe.
+	SetUserSuspended(ctx context.Context, userID int64, suspendedAt *time.Time) error
+
+	//UserSuspended dice si el usuario está suspendido.
+	UserSuspended(ctx context.Context, userID int64) (bool, error)
+
+	//DeleteUser borra al usuario y todo lo suyo, también las recuperaciones
+	//pedidas para su email, y descuenta sus follows de los contadores de los
+	//demás usuarios. Su historial de usernames se queda, con el username
+	//actual dejado en deletedAt, para que sigan reservados.
+	DeleteUser(ctx context.Context, userID int64, deletedAt time.Time) error
+}
+
+//Can dice si el usuario autenticado tiene el permiso perm.
+func (s *Service) Can(ctx context.Context, perm string) (bool, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return false, ErrUnauthenticated
+	}
+
+	roles, err := s.store.UserRoles(ctx, uid)
+	if err != nil {
+		return false, fmt.Errorf("no se pudieron consultar los roles: %v", err)
+	}
+
+	for _, r := range roles {
+		for _, p := range rolePermissions[r] {
+			if p == perm {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+//AdminUsers busca usuarios con sus datos privados, roles y estado.
+func (s *Service) AdminUsers(ctx context.Context, search string, first int, after string) ([]AdminUser, error) {
+	if err := s.requirePermission(ctx, PermListUsers); err != nil {
+		return nil, err
+	}
+
+	uu, err := s.store.AdminUsers(ctx, strings.TrimSpace(search), normalizePageSize(first), strings.TrimSpace(after))
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar los usuarios: %v", err)
+	}
+
+	return uu, nil
+}
+
+//SuspendUser suspende la cuenta de username y cierra todas sus sesiones.
+//Una cuenta suspendida no puede iniciar sesión.
+func (s *Service) SuspendUser(ctx context.Context, username, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > 255 {
+		return ErrInvalidReason
+	}
+
+	uid, err := s.moderationTarget(ctx, PermSuspendUsers, username)
+	if err != nil {
+		return err
+	}
+
+	if err = s.audit(ctx, "user.suspend", uid, reason); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err = s.store.SetUserSuspended(ctx, uid, &now); err != nil {
+		return fmt.Errorf("no se pudo suspender al usuario: %v", err)
+	}
+
+	return s.revokeUserTokens(ctx, uid)
+}
+
+//UnsuspendUser reactiva la cuenta de username.
+func (s *Service) UnsuspendUser(ctx context.Context, username string) error {
+	uid, err := s.moderationTarget(ctx, PermSuspendUsers, username)
+	if err != nil {
+		return err
+	}
+
+	if err = s.audit(ctx, "user.unsuspend", uid, ""); err != nil {
+		return err
+	}
+
+	if err = s.store.SetUserSuspended(ctx, uid, nil); err != nil {
+		return fmt.Errorf("no se pudo reactivar al usuario: %v", err)
+	}
+
+	return nil
+}
+
+//ForcePasswordReset invalida la contraseña de username, cierra sus sesiones
+//y le envía un token de recuperación para elegir otra.
+func (s *Service) ForcePasswordReset(ctx context.Context, username string) error {
+	uid, err := s.moderationTarget(ctx, PermResetPasswords, username)
+	if err != nil {
+		return err
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if err = s.audit(ctx, "user.force_password_reset", uid, ""); err != nil {
+		return err
+	}
+
+	//un hash vacío no coincide con ninguna contraseña
+	if err = s.store.UpdatePassword(ctx, uid, ""); err != nil {
+		return fmt.Errorf("no se pudo invalidar la contraseña: %v", err)
+	}
+
+	if err = s.revokeUserTokens(ctx, uid); err != nil {
+		return err
+	}
+
+	return s.sendPasswordReset(ctx, User{ID: uid, Username: username}, email, time.Now())
+}
+
+//AdminUnlockLogin borra los intentos de login fallidos de username.
+func (s *Service) AdminUnlockLogin(ctx context.Context, username string) error {
+	uid, err := s.moderationTarget(ctx, PermUnlockLogins, username)
+	if err != nil {
+		return err
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if err = s.audit(ctx, "user.unlock_login", uid, ""); err != nil {
+		return err
+	}
+
+	return s.UnlockLogin(ctx, email, "")
+}
+
+//DeleteUser borra la cuenta de username.
+func (s *Service) DeleteUser(ctx context.Context, username string) error {
+	uid, err := s.moderationTarget(ctx, PermDeleteUsers, username)
+	if err != nil {
+		return err
+	}
+
+	if err = s.audit(ctx, "user.delete", uid, username); err != nil {
+		return err
+	}
+
+	return s.deleteUser(ctx, uid)
+}
+
+//SetUserRoles reemplaza los roles de username.
+func (s *Service) SetUserRoles(ctx context.Context, username string, roles []string) ([]string, error) {
+	roles, err := NormalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := s.moderationTarget(ctx, PermManageRoles, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.audit(ctx, "user.set_roles", uid, strings.Join(roles, ",")); err != nil {
+		return nil, err
+	}
+
+	if err = s.store.SetUserRoles(ctx, uid, roles); err != nil {
+		return nil, fmt.Errorf("no se pudieron guardar los roles: %v", err)
+	}
+
+	return roles, nil
+}
+
+//NormalizeRoles valida los roles, quita repetidos y los ordena.
+func NormalizeRoles(roles []string) ([]string, error) {
+	seen := make(map[string]bool, len(roles))
+	out := []string{}
+	for _, r := range roles {
+		r = strings.TrimSpace(r)
+		if _, ok := rolePermissions[r]; !ok {
+			return nil, ErrInvalidRole
+		}
+
+		if !seen[r] {
+			seen[r] = true
+			out = append(out, r)
+		}
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
+
+//requirePermission devuelve ErrUnauthenticated o ErrForbidden si el usuario
+//autenticado no tiene el permiso perm.
+func (s *Service) requirePermission(ctx context.Context, perm string) error {
+	ok, err := s.Can(ctx, perm)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+//moderationTarget revisa el permiso perm y devuelve el id de username, que
+//no puede ser el usuario autenticado y debe tener un rango menor que el suyo.
+func (s *Service) moderationTarget(ctx context.Context, perm, username string) (int64, error) {
+	if err := s.requirePermission(ctx, perm); err != nil {
+		return 0, err
+	}
+
+	username = strings.TrimSpace(username)
+	if !rxUsername.MatchString(username) {
+		return 0, ErrInvalideUsername
+	}
+
+	uid, err := s.store.UserIDByUsername(ctx, username)
+	if err == ErrUserNotFound {
+		return 0, ErrUserNotFound
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	me, _ := authUserID(ctx)
+	if me == uid {
+		return 0, ErrCannotModerateSelf
+	}
+
+	myRank, err := s.roleRank(ctx, me)
+	if err != nil {
+		return 0, err
+	}
+
+	rank, err := s.roleRank(ctx, uid)
+	if err != nil {
+		return 0, err
+	}
+
+	if rank >= myRank {
+		return 0, ErrForbidden
+	}
+
+	return uid, nil
+}
+
+//roleRank devuelve el rango del rol más alto del usuario.
+func (s *Service) roleRank(ctx context.Context, uid int64) (int, error) {
+	roles, err := s.store.UserRoles(ctx, uid)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudieron consultar los roles: %v", err)
+	}
+
+	rank := 0
+	for _, r := range roles {
+		if roleRanks[r] > rank {
+			rank = roleRanks[r]
+		}
+	}
+
+	return rank, nil
+}
+
+//checkSuspended devuelve ErrAccountSuspended si el usuario está suspendido.
+func (s *Service) checkSuspended(ctx context.Context, uid int64) error {
+	suspended, err := s.store.UserSuspended(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar si la cuenta está suspendida: %v", err)
+	}
+
+	if suspended {
+		return ErrAccountSuspended
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//AuditEntry es una acción administrativa registrada. ActorID es cero cuando
+//la acción se hizo desde la línea de comandos.
+type AuditEntry struct {
+	ID        int64     `json:"id"`
+	ActorID   int64     `json:"actor_id"`
+	Action    string    `json:"action"`
+	TargetID  int64     `json:"target_id"`
+	Details   string    `json:"details,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+//AuditStore guarda el registro de acciones administrativas.
+type AuditStore interface {
+	//RecordAudit agrega una entrada al registro.
+	RecordAudit(ctx context.Context, e AuditEntry) error
+
+	//AuditLog devuelve hasta first entradas de la más reciente a la más
+	//antigua, anteriores al id before si no es cero.
+	AuditLog(ctx context.Context, first int, before int64) ([]AuditEntry, error)
+}
+
+//AuditLog devuelve el registro de acciones administrativas.
+func (s *Service) AuditLog(ctx context.Context, first int, before int64) ([]AuditEntry, error) {
+	if err := s.requirePermission(ctx, PermReadAudit); err != nil {
+		return nil, err
+	}
+
+	ee, err := s.store.AuditLog(ctx, normalizePageSize(first), before)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar el registro de auditoría: %v", err)
+	}
+
+	return ee, nil
+}
+
+//audit registra una acción del usuario autenticado sobre targetID. Se llama
+//antes de hacer la acción para que no quede ninguna sin registrar.
+func (s *Service) audit(ctx context.Context, action string, targetID int64, details string) error {
+	actorID, _ := authUserID(ctx)
+	client, _ := ctx.Value(KeyClientInfo).(ClientInfo)
+
+	err := s.store.RecordAudit(ctx, AuditEntry{
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		IP:        client.IP,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo registrar la acción %s: %v", action, err)
+	}
+
+	return nil
+}
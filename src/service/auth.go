@@ -2,24 +2,27 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
-	"strconv"
+	"log"
 	"strings"
 	"time"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type key string
 
 var (
-	//TokenLifespan calcule the time of life of the Token.
-	TokenLifespan = time.Hour * 24 * 14
+	//TokenLifespan calcule the time of life of the Token de acceso por defecto.
+	TokenLifespan = time.Minute * 15
+
+	//RefreshTokenLifespan es el tiempo de vida por defecto de un refresh token.
+	RefreshTokenLifespan = time.Hour * 24 * 14
+
+	//KeyPrincipal es la clave del Principal autenticado en el contexto
+	KeyPrincipal key = "principal"
 
-	//KeyAuthUser es la Clave para ayutorizar al usuuario en el contexto
-	KeyAuthUser key = "auth_user_id"
+	//KeyClientInfo es la clave de los datos del cliente (ClientInfo) en el contexto
+	KeyClientInfo key = "client_info"
 
 	// KeyUnauthenticated es cuando el usuario no está autenticado en el contexto
 	KeyUnauthenticated = errors.New("unauthenticated")
@@ -27,12 +30,24 @@ var (
 
 //LoginOutput respuesta del servidor.
 type LoginOutput struct {
-	Token     string    `json:"token,omitempty"`
-	ExpiresAt time.Time `json:"expires_at,omitempty"`
-	AuthUser  User      `json:"auth_user,omitempty"`
+	Token                 string    `json:"token,omitempty"`
+	ExpiresAt             time.Time `json:"expires_at,omitempty"`
+	RefreshToken          string    `json:"refresh_token,omitempty"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at,omitempty"`
+	AuthUser              User      `json:"auth_user,omitempty"`
+
+	//TwoFactor llega en lugar de los tokens cuando el usuario tiene la
+	//verificación en dos pasos; el login se termina con LoginTwoFactor.
+	TwoFactor *TwoFactorLogin `json:"two_factor,omitempty"`
+
+	//DeletionCancelled dice si el login canceló el borrado pendiente de la
+	//cuenta.
+	DeletionCancelled bool `json:"deletion_cancelled,omitempty"`
 }
 
-//Login implementa la seguridad
+//Login implementa la seguridad. Los intentos fallidos se cuentan por cuenta
+//y por IP y, pasado un límite, bloquean el login por un tiempo que crece con
+//cada fallo.
 func (s *Service) Login(ctx context.Context, email, password string) (LoginOutput, error) {
 	var out LoginOutput
 
@@ -46,67 +61,153 @@ func (s *Service) Login(ctx context.Context, email, password string) (LoginOutpu
 		return out, ErrInvalidPassword
 	}
 
-	query := "SELECT id, username, password from user where email=?"
-
-	var key string
-
-	err := s.db.QueryRowContext(ctx, query, email).Scan(&out.AuthUser.ID, &out.AuthUser.Username, &key)
+	if err := s.checkLoginThrottle(ctx, email); err != nil {
+		return out, err
+	}
 
-	if err == sql.ErrNoRows {
-		return out, ErrUserNotFound
+	user, key, err := s.store.Credentials(ctx, email)
+	if err == ErrUserNotFound {
+		s.compareDummyPassword(password)
+		return out, s.loginFailed(ctx, email)
 	}
 
 	if err != nil {
 		return out, fmt.Errorf("No se encontro ningun registro user: %v", err)
 	}
 
-	hashedPasswordFromDatabase := []byte(key)
-	val := bcrypt.CompareHashAndPassword(hashedPasswordFromDatabase, []byte(password))
+	out.AuthUser = user
+	ok, rehash := s.cfg.PasswordHasher.Verify(password, key)
+	if !ok {
+		return out, s.loginFailed(ctx, email)
+	}
 
-	if val != nil {
-		return out, ErrUserNotFound
+	if rehash {
+		s.rehashPassword(ctx, user.ID, password, key)
 	}
 
-	out.Token, err = s.codec.EncodeToString(strconv.FormatInt(out.AuthUser.ID, 10))
+	if err = s.checkSuspended(ctx, out.AuthUser.ID); err != nil {
+		return out, err
+	}
+
+	if err = s.requireVerified(ctx, out.AuthUser.ID, RestrictLogin); err != nil {
+		return out, err
+	}
 
+	twoFactor, err := s.twoFactorRequired(ctx, out.AuthUser.ID)
 	if err != nil {
-		return out, fmt.Errorf("No se pudo generar el token: %v", err)
+		return out, err
 	}
 
-	out.ExpiresAt = time.Now().Add(TokenLifespan)
+	if twoFactor {
+		c, err := s.startTwoFactorChallenge(ctx, out.AuthUser.ID)
+		if err != nil {
+			return LoginOutput{}, err
+		}
+
+		//los intentos fallidos se borran cuando pase el segundo paso
+		return LoginOutput{TwoFactor: c}, nil
+	}
+
+	if err = s.loginSucceeded(ctx, email); err != nil {
+		return out, err
+	}
+
+	if err = s.startLogin(ctx, &out, AuthMethodPassword); err != nil {
+		return out, err
+	}
 
 	return out, nil
 }
 
-//AuthUserID Evaluar token
-func (s *Service) AuthUserID(token string) (int64, error) {
-	str, err := s.codec.DecodeToString(token)
+//rehashPassword cambia un hash con parámetros viejos por uno con los
+//configurados, aprovechando que se tiene la contraseña. Si falla el login
+//sigue y se intenta en el siguiente.
+func (s *Service) rehashPassword(ctx context.Context, uid int64, password, oldHash string) {
+	hash, err := s.cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		log.Printf("no se pudo rehashear la contraseña del usuario %d: %v", uid, err)
+		return
+	}
+
+	//si la contraseña cambió mientras tanto no se pisa
+	if _, err = s.store.ReplacePasswordHash(ctx, uid, oldHash, hash); err != nil {
+		log.Printf("no se pudo guardar el nuevo hash del usuario %d: %v", uid, err)
+	}
+}
+
+//startLogin abre una sesión nueva para out.AuthUser, iniciada con method, y
+//llena out con sus tokens. Cancela el borrado pendiente de la cuenta.
+func (s *Service) startLogin(ctx context.Context, out *LoginOutput, method string) error {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return fmt.Errorf("No se pudo generar el token: %v", err)
+	}
+
+	if err = s.startSession(ctx, sessionID, out.AuthUser.ID, method, time.Now()); err != nil {
+		return err
+	}
+
+	if out.DeletionCancelled, err = s.cancelDeletion(ctx, out.AuthUser.ID); err != nil {
+		return err
+	}
+
+	return s.issueTokens(ctx, out, sessionID, method)
+}
+
+//AuthUserID Evaluar token. Devuelve el principal con los claims del token, o
+//el del token personal, y rechaza los tokens revocados, de sesiones cerradas
+//o de aplicaciones OAuth que el usuario ya no autoriza.
+func (s *Service) AuthUserID(ctx context.Context, token string) (Principal, error) {
+	if strings.HasPrefix(token, personalAccessTokenPrefix) {
+		return s.authPersonalAccessToken(ctx, token)
+	}
+
+	str, err := s.codec.Decode(token)
 
 	if err != nil {
-		return 0, fmt.Errorf("No se puede decodificar el token: %v", err)
+		return Principal{}, fmt.Errorf("No se puede decodificar el token: %v", err)
 	}
 
-	i, err := strconv.ParseInt(str, 10, 64)
+	c, err := parseClaims(str)
+
+	if err != nil {
+		return Principal{}, fmt.Errorf("No se puede obtener el id del usuario en el token: %v", err)
+	}
 
+	p := c.principal()
+	revoked, err := s.store.TokenRevoked(ctx, p.UserID, p.TokenID, p.IssuedAt)
 	if err != nil {
-		return 0, fmt.Errorf("No se puede obtener el id del usuario en el token: %v", err)
+		return Principal{}, fmt.Errorf("No se pudo verificar la revocación del token: %v", err)
+	}
+
+	if revoked {
+		return Principal{}, ErrTokenRevoked
 	}
 
-	return i, nil
+	if p.SessionID != "" {
+		if err = s.checkSession(ctx, p.SessionID, p.UserID); err != nil {
+			return Principal{}, err
+		}
+	}
+
+	if p.ClientID != "" {
+		if err = s.checkOAuthConsent(ctx, p.UserID, p.ClientID, p.IssuedAt); err != nil {
+			return Principal{}, err
+		}
+	}
+
+	return p, nil
 }
 
 // AuthUser crea una consulta sobre el contexto
 func (s *Service) AuthUser(ctx context.Context) (User, error) {
-	var u User
-	uid, ok := ctx.Value(KeyAuthUser).(int64)
+	uid, ok := authUserID(ctx)
 
 	if !ok {
-		return u, ErrUnauthenticated
+		return User{}, ErrUnauthenticated
 	}
-	query := "SELECT username FROM user WHERE id=?"
-	err := s.db.QueryRowContext(ctx, query, uid).Scan(&u.Username)
-
-	if err == sql.ErrNoRows {
+	u, err := s.store.UserByID(ctx, uid)
+	if err == ErrUserNotFound {
 		return u, ErrUserNotFound
 	}
 
@@ -115,6 +216,7 @@ func (s *Service) AuthUser(ctx context.Context) (User, error) {
 	}
 
 	u.ID = uid
+	s.fillAvatarURL(&u)
 	return u, nil
 
 }
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//claimsVersion es la versión actual del contenido de los tokens de acceso.
+const claimsVersion = 1
+
+const (
+	//ScopeAll permite todo lo que puede hacer el usuario. Es el alcance de
+	//los tokens que salen del login.
+	ScopeAll = "*"
+
+	//AuthMethodPassword es un login solo con contraseña.
+	AuthMethodPassword = "pwd"
+
+	//AuthMethodTOTP es un login con contraseña y código TOTP.
+	AuthMethodTOTP = "otp"
+
+	//AuthMethodRecoveryCode es un login con contraseña y código de respaldo.
+	AuthMethodRecoveryCode = "rc"
+
+	//AuthMethodOAuth es un token emitido a una aplicación OAuth.
+	AuthMethodOAuth = "oauth"
+
+	//AuthMethodOIDC es un login con un proveedor OpenID Connect externo.
+	AuthMethodOIDC = "oidc"
+)
+
+//Principal es quien hace una petición autenticada: el usuario y lo que dice
+//el token con el que se autenticó. withAuth lo guarda en el contexto con
+//KeyPrincipal.
+type Principal struct {
+	UserID     int64
+	TokenID    string
+	SessionID  string
+	IssuedAt   time.Time
+	Scopes     []string
+	AuthMethod string
+
+	//ClientID es la aplicación OAuth a la que se emitió el token, si la hay.
+	ClientID string
+}
+
+//HasScope dice si el principal tiene el alcance scope.
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+//PrincipalFromContext devuelve el principal de una petición autenticada.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(KeyPrincipal).(Principal)
+	return p, ok
+}
+
+//authUserID devuelve el id del usuario autenticado en el contexto.
+func authUserID(ctx context.Context) (int64, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	return p.UserID, ok
+}
+
+//claims es el contenido de un token de acceso. Se codifica como JSON con
+//nombres cortos para que el token no crezca; v permite cambiar el formato
+//sin invalidar los tokens que ya circulan.
+type claims struct {
+	Version    int      `json:"v"`
+	UserID     int64    `json:"uid"`
+	TokenID    string   `json:"jti"`
+	IssuedAt   int64    `json:"iat"`
+	Scopes     []string `json:"scp,omitempty"`
+	SessionID  string   `json:"sid,omitempty"`
+	AuthMethod string   `json:"amr,omitempty"`
+	ClientID   string   `json:"cid,omitempty"`
+}
+
+//newClaims arma los claims del principal p.
+func newClaims(p Principal) claims {
+	return claims{
+		Version:    claimsVersion,
+		UserID:     p.UserID,
+		TokenID:    p.TokenID,
+		IssuedAt:   p.IssuedAt.UnixNano() / int64(time.Millisecond),
+		Scopes:     p.Scopes,
+		SessionID:  p.SessionID,
+		AuthMethod: p.AuthMethod,
+		ClientID:   p.ClientID,
+	}
+}
+
+func (c claims) encode() (string, error) {
+	b, err := json.Marshal(c)
+	return string(b), err
+}
+
+func (c claims) principal() Principal {
+	return Principal{
+		UserID:     c.UserID,
+		TokenID:    c.TokenID,
+		SessionID:  c.SessionID,
+		IssuedAt:   time.Unix(0, c.IssuedAt*int64(time.Millisecond)),
+		Scopes:     c.Scopes,
+		AuthMethod: c.AuthMethod,
+		ClientID:   c.ClientID,
+	}
+}
+
+//parseClaims lee el contenido de un token de acceso. Acepta también el
+//formato anterior "uid:token:iat:sesión" de los tokens emitidos antes de
+//los claims versionados.
+func parseClaims(s string) (claims, error) {
+	var c claims
+
+	if !strings.HasPrefix(s, "{") {
+		return parseLegacyClaims(s)
+	}
+
+	if err := json.Unmarshal([]byte(s), &c); err != nil {
+		return c, fmt.Errorf("claims inválidos: %v", err)
+	}
+
+	if c.Version != claimsVersion {
+		return c, fmt.Errorf("versión de claims no soportada: %d", c.Version)
+	}
+
+	if c.UserID == 0 || c.TokenID == "" {
+		return c, errors.New("faltan claims obligatorios")
+	}
+
+	return c, nil
+}
+
+func parseLegacyClaims(s string) (claims, error) {
+	var c claims
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 || parts[1] == "" || parts[3] == "" {
+		return c, errors.New("formato de token inválido")
+	}
+
+	uid, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return c, err
+	}
+
+	ms, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return c, err
+	}
+
+	c.UserID = uid
+	c.TokenID = parts[1]
+	c.IssuedAt = ms
+	c.SessionID = parts[3]
+	c.Scopes = []string{ScopeAll}
+	c.AuthMethod = AuthMethodPassword
+	return c, nil
+}
@@ -0,0 +1,506 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Estados de una exportación de datos.
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+	DataExportExpired = "expired"
+)
+
+const (
+	//maxDataExports es cuántas exportaciones se pueden pedir en
+	//dataExportWindow.
+	maxDataExports   = 3
+	dataExportWindow = time.Hour * 24
+
+	//dataExportTimeout es cuánto puede tardar una exportación antes de que
+	//otra pasada la vuelva a tomar, por si el servidor se reinició a medias.
+	dataExportTimeout = time.Minute * 10
+
+	//downloadLinkLifespan es el tiempo de vida de cada link de descarga.
+	downloadLinkLifespan = time.Minute * 15
+)
+
+var (
+	//ErrDataExportNotFound cuando la exportación no existe o es de otro
+	//usuario.
+	ErrDataExportNotFound = errors.New("exportación no encontrada")
+
+	//ErrTooManyDataExports cuando se piden demasiadas exportaciones.
+	ErrTooManyDataExports = errors.New("demasiadas exportaciones, intente más tarde")
+
+	//ErrInvalidDownloadToken cuando el link de descarga no es válido, expiró
+	//o la exportación ya no está disponible.
+	ErrInvalidDownloadToken = errors.New("link de descarga inválido o expirado")
+
+	//ErrDataExportsUnavailable cuando no hay almacenamiento para las
+	//exportaciones.
+	ErrDataExportsUnavailable = errors.New("no se pueden exportar datos")
+)
+
+//DataExport es una exportación de los datos de un usuario. DownloadToken
+//solo llega, cuando la exportación está lista, al consultar su estado.
+type DataExport struct {
+	ID                string     `json:"id"`
+	UserID            int64      `json:"-"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	StartedAt         *time.Time `json:"-"`
+	FinishedAt        *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	DownloadToken     string     `json:"-"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
+
+//DataExportDownload es un link de descarga de una exportación. Solo se
+//guarda el hash de su token.
+type DataExportDownload struct {
+	TokenHash string
+	ExportID  string
+	UserID    int64
+	ExpiresAt time.Time
+}
+
+//DataExportStore guarda las exportaciones de datos.
+type DataExportStore interface {
+	//CreateDataExport guarda una exportación nueva.
+	CreateDataExport(ctx context.Context, e DataExport) error
+
+	//DataExport devuelve la exportación o ErrDataExportNotFound.
+	DataExport(ctx context.Context, id string) (DataExport, error)
+
+	//DataExports devuelve todas las exportaciones del usuario.
+	DataExports(ctx context.Context, userID int64) ([]DataExport, error)
+
+	//ActiveDataExport devuelve la exportación pendiente o en curso del
+	//usuario, o ErrDataExportNotFound.
+	ActiveDataExport(ctx context.Context, userID int64) (DataExport, error)
+
+	//CountDataExports cuenta las exportaciones del usuario creadas desde
+	//since.
+	CountDataExports(ctx context.Context, userID int64, since time.Time) (int, error)
+
+	//ClaimDataExport toma la exportación pendiente más antigua, o una en
+	//curso que empezó antes de staleBefore, y la marca en curso desde now.
+	//Devuelve ErrDataExportNotFound si no hay ninguna.
+	ClaimDataExport(ctx context.Context, staleBefore, now time.Time) (DataExport, error)
+
+	//FinishDataExport cambia el estado de la exportación al terminar o
+	//expirar y borra sus links de descarga; expiresAt es nil si no queda
+	//archivo.
+	FinishDataExport(ctx context.Context, id, status string, finishedAt time.Time, expiresAt *time.Time) error
+
+	//CreateDataExportDownload guarda un link de descarga más y borra los de
+	//la exportación que expiraron antes de now. Los demás siguen sirviendo.
+	CreateDataExportDownload(ctx context.Context, d DataExportDownload, now time.Time) error
+
+	//DataExportDownload devuelve el link de descarga con ese hash o
+	//ErrInvalidDownloadToken.
+	DataExportDownload(ctx context.Context, tokenHash string) (DataExportDownload, error)
+
+	//ExpiredDataExports devuelve hasta limit exportaciones listas que
+	//expiraron antes de now.
+	ExpiredDataExports(ctx context.Context, now time.Time, limit int) ([]DataExport, error)
+}
+
+//RequestDataExport pide una exportación de los datos del usuario
+//autenticado. Se genera en segundo plano; si ya hay una pendiente devuelve
+//esa.
+func (s *Service) RequestDataExport(ctx context.Context) (DataExport, error) {
+	var out DataExport
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	if s.cfg.Exports == nil {
+		return out, ErrDataExportsUnavailable
+	}
+
+	out, err := s.store.ActiveDataExport(ctx, uid)
+	if err == nil {
+		return out, nil
+	}
+
+	if err != ErrDataExportNotFound {
+		return out, fmt.Errorf("no se pudo consultar la exportación en curso: %v", err)
+	}
+
+	now := time.Now()
+	n, err := s.store.CountDataExports(ctx, uid, now.Add(-dataExportWindow))
+	if err != nil {
+		return out, fmt.Errorf("no se pudieron contar las exportaciones: %v", err)
+	}
+
+	if n >= maxDataExports {
+		return out, ErrTooManyDataExports
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el id de la exportación: %v", err)
+	}
+
+	out = DataExport{ID: id, UserID: uid, Status: DataExportPending, CreatedAt: now}
+	if err = s.store.CreateDataExport(ctx, out); err != nil {
+		return out, fmt.Errorf("no se pudo guardar la exportación: %v", err)
+	}
+
+	return out, nil
+}
+
+//DataExport devuelve el estado de una exportación del usuario autenticado.
+//Si está lista genera un link de descarga nuevo que dura
+//downloadLinkLifespan; los que se dieron antes siguen sirviendo hasta que
+//expiren.
+func (s *Service) DataExport(ctx context.Context, id string) (DataExport, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return DataExport{}, ErrUnauthenticated
+	}
+
+	e, err := s.store.DataExport(ctx, id)
+	if err == ErrDataExportNotFound || err == nil && e.UserID != uid {
+		return DataExport{}, ErrDataExportNotFound
+	}
+
+	if err != nil {
+		return e, fmt.Errorf("no se pudo consultar la exportación: %v", err)
+	}
+
+	if e.Status != DataExportReady {
+		return e, nil
+	}
+
+	//todavía no pasa la limpieza, pero ya no se puede descargar
+	if e.ExpiresAt != nil && time.Now().After(*e.ExpiresAt) {
+		e.Status = DataExportExpired
+		e.DownloadExpiresAt = nil
+		return e, nil
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return e, fmt.Errorf("no se pudo generar el link de descarga: %v", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(downloadLinkLifespan)
+	if e.ExpiresAt != nil && e.ExpiresAt.Before(expiresAt) {
+		expiresAt = *e.ExpiresAt
+	}
+
+	d := DataExportDownload{TokenHash: hashToken(token), ExportID: e.ID, UserID: uid, ExpiresAt: expiresAt}
+	if err = s.store.CreateDataExportDownload(ctx, d, now); err != nil {
+		return e, fmt.Errorf("no se pudo guardar el link de descarga: %v", err)
+	}
+
+	e.DownloadToken = token
+	e.DownloadExpiresAt = &expiresAt
+	return e, nil
+}
+
+//DownloadDataExport abre el ZIP de la exportación con el token de su link
+//de descarga. No necesita autenticación para que el link sirva en el
+//navegador.
+func (s *Service) DownloadDataExport(ctx context.Context, id, token string) (io.ReadCloser, error) {
+	if s.cfg.Exports == nil {
+		return nil, ErrDataExportsUnavailable
+	}
+
+	d, err := s.store.DataExportDownload(ctx, hashToken(token))
+	if err == ErrInvalidDownloadToken {
+		return nil, ErrInvalidDownloadToken
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar el link de descarga: %v", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(d.ExportID), []byte(id)) != 1 || time.Now().After(d.ExpiresAt) {
+		return nil, ErrInvalidDownloadToken
+	}
+
+	e, err := s.store.DataExport(ctx, id)
+	if err == ErrDataExportNotFound {
+		return nil, ErrInvalidDownloadToken
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar la exportación: %v", err)
+	}
+
+	if e.Status != DataExportReady || e.ExpiresAt == nil || time.Now().After(*e.ExpiresAt) {
+		return nil, ErrInvalidDownloadToken
+	}
+
+	f, err := s.cfg.Exports.Open(ctx, dataExportKey(e))
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir la exportación: %v", err)
+	}
+
+	return f, nil
+}
+
+//ProcessDataExport genera la siguiente exportación pendiente y devuelve si
+//había una. Una exportación que falla queda como DataExportFailed.
+func (s *Service) ProcessDataExport(ctx context.Context) (bool, error) {
+	if s.cfg.Exports == nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	e, err := s.store.ClaimDataExport(ctx, now.Add(-dataExportTimeout), now)
+	if err == ErrDataExportNotFound {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("no se pudo tomar una exportación pendiente: %v", err)
+	}
+
+	status := DataExportReady
+	var expiresAt *time.Time
+	if err = s.writeDataExport(ctx, e); err != nil {
+		log.Printf("no se pudo generar la exportación %s: %v", e.ID, err)
+		status = DataExportFailed
+	} else {
+		t := time.Now().Add(s.cfg.DataExportLifespan)
+		expiresAt = &t
+	}
+
+	if err = s.store.FinishDataExport(ctx, e.ID, status, time.Now(), expiresAt); err != nil {
+		return true, fmt.Errorf("no se pudo terminar la exportación: %v", err)
+	}
+
+	return true, nil
+}
+
+//PurgeExpiredDataExports borra los archivos de las exportaciones que
+//expiraron y devuelve cuántas borró.
+func (s *Service) PurgeExpiredDataExports(ctx context.Context) (int, error) {
+	if s.cfg.Exports == nil {
+		return 0, nil
+	}
+
+	now := time.Now()
+	ee, err := s.store.ExpiredDataExports(ctx, now, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("no se pudieron consultar las exportaciones expiradas: %v", err)
+	}
+
+	for i, e := range ee {
+		if err = s.cfg.Exports.Delete(ctx, dataExportKey(e)); err != nil {
+			return i, fmt.Errorf("no se pudo borrar la exportación %s: %v", e.ID, err)
+		}
+
+		if err = s.store.FinishDataExport(ctx, e.ID, DataExportExpired, now, nil); err != nil {
+			return i, fmt.Errorf("no se pudo marcar la exportación %s: %v", e.ID, err)
+		}
+	}
+
+	return len(ee), nil
+}
+
+//deleteUserDataExports borra los archivos de las exportaciones del usuario.
+func (s *Service) deleteUserDataExports(ctx context.Context, uid int64) error {
+	if s.cfg.Exports == nil {
+		return nil
+	}
+
+	ee, err := s.store.DataExports(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudieron consultar las exportaciones: %v", err)
+	}
+
+	for _, e := range ee {
+		if e.Status != DataExportReady {
+			continue
+		}
+
+		if err = s.cfg.Exports.Delete(ctx, dataExportKey(e)); err != nil {
+			return fmt.Errorf("no se pudo borrar la exportación %s: %v", e.ID, err)
+		}
+	}
+
+	return nil
+}
+
+//exportSection es un grupo de datos del ZIP. Se guarda como nombre.json y,
+//si tiene filas, también como nombre.csv.
+type exportSection struct {
+	name string
+	json interface{}
+	csv  [][]string
+}
+
+//writeDataExport genera el ZIP con los datos del usuario y lo guarda.
+func (s *Service) writeDataExport(ctx context.Context, e DataExport) error {
+	sections, err := s.exportSections(ctx, e.UserID)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	now := time.Now()
+	create := func(name string) (io.Writer, error) {
+		return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+	}
+
+	for _, sec := range sections {
+		w, err := create(sec.name + ".json")
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(sec.json); err != nil {
+			return err
+		}
+
+		if sec.csv == nil {
+			continue
+		}
+
+		if w, err = create(sec.name + ".csv"); err != nil {
+			return err
+		}
+
+		if err = csv.NewWriter(w).WriteAll(escapeCSVFormulas(sec.csv)); err != nil {
+			return err
+		}
+	}
+
+	if err = zw.Close(); err != nil {
+		return err
+	}
+
+	return s.cfg.Exports.Put(ctx, dataExportKey(e), "application/zip", &buf)
+}
+
+//exportSections junta los datos del usuario. Los datos nuevos que guarde la
+//aplicación de un usuario se agregan aquí.
+func (s *Service) exportSections(ctx context.Context, uid int64) ([]exportSection, error) {
+	profile, err := s.ownProfile(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+
+	followers, err := s.allFollows(ctx, uid, s.store.Followers)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar los seguidores: %v", err)
+	}
+
+	followees, err := s.allFollows(ctx, uid, s.store.Followees)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar los seguidos: %v", err)
+	}
+
+	sessions, err := s.store.Sessions(ctx, uid, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar las sesiones: %v", err)
+	}
+
+	//las listas vacías van como [] y no como null
+	if sessions == nil {
+		sessions = []Session{}
+	}
+
+	sessionRows := [][]string{{"id", "auth_method", "ip", "user_agent", "created_at", "last_seen_at", "expires_at"}}
+	for _, ss := range sessions {
+		sessionRows = append(sessionRows, []string{ss.ID, ss.AuthMethod, ss.IP, ss.UserAgent,
+			ss.CreatedAt.UTC().Format(time.RFC3339), ss.LastSeenAt.UTC().Format(time.RFC3339),
+			ss.ExpiresAt.UTC().Format(time.RFC3339)})
+	}
+
+	return []exportSection{
+		{name: "profile", json: profile, csv: [][]string{
+			{"username", "email", "display_name", "bio", "location", "website", "birthday",
+				"followers_count", "followees_count", "avatar_url", "cover_url"},
+			{profile.Username, profile.Email, profile.DisplayName, profile.Bio, profile.Location, profile.Website,
+				profile.Birthday, strconv.Itoa(profile.FollowersCount), strconv.Itoa(profile.FolloweesCount),
+				profile.AvatarURL, profile.CoverURL},
+		}},
+		{name: "followers", json: followers, csv: usernameRows(followers)},
+		{name: "followees", json: followees, csv: usernameRows(followees)},
+		{name: "sessions", json: sessions, csv: sessionRows},
+	}, nil
+}
+
+//allFollows junta todas las páginas de list, solo con el username y el
+//avatar de cada usuario.
+func (s *Service) allFollows(ctx context.Context, uid int64,
+	list func(context.Context, int64, int64, int, string) ([]UserProfile, error)) ([]User, error) {
+	out := []User{}
+	after := ""
+	for {
+		page, err := list(ctx, 0, uid, maxPageSize, after)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range page {
+			u := User{Username: p.Username, Avatar: p.Avatar}
+			s.fillAvatarURL(&u)
+			out = append(out, u)
+		}
+
+		if len(page) < maxPageSize {
+			return out, nil
+		}
+
+		after = page[len(page)-1].Username
+	}
+}
+
+//escapeCSVFormulas antepone ' a las celdas que una hoja de cálculo tomaría
+//como fórmula, porque el perfil tiene texto libre del usuario.
+func escapeCSVFormulas(rows [][]string) [][]string {
+	out := make([][]string, len(rows))
+	for i, row := range rows {
+		out[i] = make([]string, len(row))
+		for j, cell := range row {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				cell = "'" + cell
+			}
+
+			out[i][j] = cell
+		}
+	}
+
+	return out
+}
+
+func usernameRows(uu []User) [][]string {
+	rows := [][]string{{"username"}}
+	for _, u := range uu {
+		rows = append(rows, []string{u.Username})
+	}
+
+	return rows
+}
+
+func dataExportKey(e DataExport) string {
+	return fmt.Sprintf("exports/%d/%s.zip", e.UserID, e.ID)
+}
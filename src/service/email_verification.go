@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	//emailVerificationLifespan es el tiempo de vida de un token de verificación.
+	emailVerificationLifespan = time.Hour * 24 * 2
+
+	//maxEmailVerifications es cuántos correos de verificación se envían por
+	//usuario en emailVerificationWindow.
+	maxEmailVerifications   = 3
+	emailVerificationWindow = time.Hour
+
+	//RestrictLogin impide iniciar sesión sin verificar el email.
+	RestrictLogin = "login"
+
+	//RestrictFollow impide seguir usuarios sin verificar el email.
+	RestrictFollow = "follow"
+)
+
+var (
+	//ErrInvalidVerificationToken cuando el token de verificación no existe, expiró o ya se usó.
+	ErrInvalidVerificationToken = errors.New("token de verificación inválido")
+
+	//ErrEmailNotVerified cuando la acción necesita el email verificado.
+	ErrEmailNotVerified = errors.New("debe verificar su email primero")
+
+	//ErrEmailAlreadyVerified cuando se pide verificar un email que ya lo está.
+	ErrEmailAlreadyVerified = errors.New("el email ya está verificado")
+
+	//ErrTooManyVerificationRequests cuando se piden demasiados correos de verificación.
+	ErrTooManyVerificationRequests = errors.New("demasiadas solicitudes de verificación, intente más tarde")
+)
+
+//EmailVerificationToken es un token enviado a Email para confirmar que le
+//pertenece al usuario. Solo se guarda su hash.
+type EmailVerificationToken struct {
+	Hash      string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+//EmailVerificationStore guarda los tokens de verificación y el estado del
+//email de cada usuario.
+type EmailVerificationStore interface {
+	//CreateEmailVerificationToken guarda un token nuevo.
+	CreateEmailVerificationToken(ctx context.Context, t EmailVerificationToken) error
+
+	//EmailVerificationToken devuelve el token con ese hash o
+	//ErrInvalidVerificationToken.
+	EmailVerificationToken(ctx context.Context, hash string) (EmailVerificationToken, error)
+
+	//UseEmailVerificationToken marca el token como usado solo si no se había
+	//usado y devuelve si lo marcó.
+	UseEmailVerificationToken(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+
+	//CountEmailVerificationTokens cuenta los tokens del usuario creados desde since.
+	CountEmailVerificationTokens(ctx context.Context, userID int64, since time.Time) (int, error)
+
+	//EmailStatus devuelve el email del usuario y si está verificado.
+	EmailStatus(ctx context.Context, userID int64) (string, bool, error)
+
+	//MarkEmailVerified marca el email del usuario como verificado si sigue
+	//siendo email, y devuelve si lo marcó.
+	MarkEmailVerified(ctx context.Context, userID int64, email string, at time.Time) (bool, error)
+}
+
+//VerifyEmail confirma el email con el token que se envió por correo.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidVerificationToken
+	}
+
+	hash := hashToken(token)
+	t, err := s.store.EmailVerificationToken(ctx, hash)
+	if err == ErrInvalidVerificationToken {
+		return ErrInvalidVerificationToken
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el token de verificación: %v", err)
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return ErrInvalidVerificationToken
+	}
+
+	ok, err := s.store.UseEmailVerificationToken(ctx, hash, now)
+	if err != nil {
+		return fmt.Errorf("no se pudo marcar el token de verificación: %v", err)
+	}
+
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	ok, err = s.store.MarkEmailVerified(ctx, t.UserID, t.Email, now)
+	if err != nil {
+		return fmt.Errorf("no se pudo verificar el email: %v", err)
+	}
+
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+//ResendEmailVerification envía otro correo de verificación. Si la petición
+//está autenticada se usa el usuario autenticado; si no, el usuario con ese
+//email, para que quien no puede iniciar sesión sin verificar pueda pedirlo.
+//Sin autenticación no se dice si el email existe o ya está verificado.
+func (s *Service) ResendEmailVerification(ctx context.Context, email string) error {
+	uid, auth := authUserID(ctx)
+	if !auth {
+		email = strings.TrimSpace(email)
+		if !rxEmail.MatchString(email) {
+			return ErrInvalideEmail
+		}
+
+		u, _, err := s.store.Credentials(ctx, email)
+		if err == ErrUserNotFound {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("no se pudo consultar el usuario: %v", err)
+		}
+		uid = u.ID
+	}
+
+	email, verified, err := s.store.EmailStatus(ctx, uid)
+	if err == ErrUserNotFound {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if verified && auth {
+		return ErrEmailAlreadyVerified
+	}
+
+	if verified {
+		return nil
+	}
+
+	n, err := s.store.CountEmailVerificationTokens(ctx, uid, time.Now().Add(-emailVerificationWindow))
+	if err != nil {
+		return fmt.Errorf("no se pudieron contar los correos de verificación: %v", err)
+	}
+
+	if n >= maxEmailVerifications {
+		return ErrTooManyVerificationRequests
+	}
+
+	return s.sendEmailVerification(ctx, uid, email)
+}
+
+//sendEmailVerification crea un token de verificación para email y lo envía.
+func (s *Service) sendEmailVerification(ctx context.Context, uid int64, email string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return fmt.Errorf("no se pudo generar el token de verificación: %v", err)
+	}
+
+	now := time.Now()
+	err = s.store.CreateEmailVerificationToken(ctx, EmailVerificationToken{
+		Hash:      hashToken(token),
+		UserID:    uid,
+		Email:     email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailVerificationLifespan),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo guardar el token de verificación: %v", err)
+	}
+
+	err = s.mailer.Send(ctx, Mail{
+		To:      email,
+		Subject: "Verifique su email",
+		Body: fmt.Sprintf("Hola,\n\n"+
+			"Use este token para verificar su email:\n\n%s\n\n"+
+			"Si no creó una cuenta, ignore este correo.\n", token),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo enviar el correo de verificación: %v", err)
+	}
+
+	return nil
+}
+
+//requireVerified devuelve ErrEmailNotVerified si la acción está restringida
+//para usuarios sin email verificado y uid no lo ha verificado.
+func (s *Service) requireVerified(ctx context.Context, uid int64, action string) error {
+	if !s.cfg.restricted(action) {
+		return nil
+	}
+
+	_, verified, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar si el email está verificado: %v", err)
+	}
+
+	if !verified {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/Mynor2397/social-network/src/imaging"
+)
+
+const (
+	//maxImagePixels es el máximo de pixeles de una imagen subida, para no
+	//reservar demasiada memoria al decodificarla.
+	maxImagePixels = 40 * 1000 * 1000
+
+	//imageQuality es la calidad JPEG de las imágenes generadas.
+	imageQuality = 85
+)
+
+var (
+	//avatarSizes y coverSizes son los tamaños que se generan de cada
+	//imagen; el primero es el de AvatarURL y CoverURL y los demás son las
+	//miniaturas.
+	avatarSizes = []image.Point{{400, 400}, {200, 200}, {96, 96}, {48, 48}}
+	coverSizes  = []image.Point{{1500, 500}, {600, 200}}
+)
+
+var (
+	//ErrImageTooLarge cuando la imagen pesa o mide más de lo permitido.
+	ErrImageTooLarge = errors.New("la imagen es demasiado grande")
+
+	//ErrUnsupportedImage cuando el archivo no es una imagen JPEG, PNG o GIF.
+	ErrUnsupportedImage = errors.New("la imagen debe ser JPEG, PNG o GIF")
+
+	//ErrImagesUnavailable cuando no hay almacenamiento para las imágenes.
+	ErrImagesUnavailable = errors.New("no se pueden subir imágenes")
+)
+
+//BlobStorage guarda archivos públicos por su llave, una ruta relativa como
+//avatars/1/abc_400x400.jpg. El paquete blob tiene una implementación en
+//disco.
+type BlobStorage interface {
+	Put(ctx context.Context, key, contentType string, r io.Reader) error
+	Delete(ctx context.Context, key string) error
+
+	//Open abre el archivo para leerlo.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	//URL devuelve la dirección pública del archivo.
+	URL(key string) string
+}
+
+//ImageStore guarda las imágenes de perfil de los usuarios como el prefijo de
+//las llaves de sus archivos.
+type ImageStore interface {
+	//SetAvatar cambia el avatar del usuario, vacío para quitarlo, y devuelve
+	//el anterior.
+	SetAvatar(ctx context.Context, userID int64, key string) (string, error)
+
+	//SetCover cambia la portada del usuario, vacía para quitarla, y devuelve
+	//la anterior.
+	SetCover(ctx context.Context, userID int64, key string) (string, error)
+
+	//UserImages devuelve el avatar y la portada del usuario.
+	UserImages(ctx context.Context, userID int64) (string, string, error)
+}
+
+//SetAvatar cambia el avatar del usuario autenticado por la imagen de r y
+//devuelve su perfil. La imagen se vuelve a codificar como JPEG, sin sus
+//metadatos, en todos los tamaños de avatarSizes.
+func (s *Service) SetAvatar(ctx context.Context, r io.Reader) (UserProfile, error) {
+	return s.setImage(ctx, r, "avatars", avatarSizes, s.store.SetAvatar)
+}
+
+//SetCover cambia la portada del usuario autenticado, como SetAvatar.
+func (s *Service) SetCover(ctx context.Context, r io.Reader) (UserProfile, error) {
+	return s.setImage(ctx, r, "covers", coverSizes, s.store.SetCover)
+}
+
+//DeleteAvatar quita el avatar del usuario autenticado.
+func (s *Service) DeleteAvatar(ctx context.Context) (UserProfile, error) {
+	return s.deleteImage(ctx, avatarSizes, s.store.SetAvatar)
+}
+
+//DeleteCover quita la portada del usuario autenticado.
+func (s *Service) DeleteCover(ctx context.Context) (UserProfile, error) {
+	return s.deleteImage(ctx, coverSizes, s.store.SetCover)
+}
+
+func (s *Service) setImage(ctx context.Context, r io.Reader, kind string, sizes []image.Point,
+	set func(context.Context, int64, string) (string, error)) (UserProfile, error) {
+	var out UserProfile
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	if s.cfg.Blobs == nil {
+		return out, ErrImagesUnavailable
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(s.cfg.MaxImageSize)+1))
+	if err != nil {
+		return out, fmt.Errorf("no se pudo leer la imagen: %v", err)
+	}
+
+	if len(data) > s.cfg.MaxImageSize {
+		return out, ErrImageTooLarge
+	}
+
+	img, err := imaging.Decode(data, maxImagePixels)
+	if err == imaging.ErrUnsupportedFormat {
+		return out, ErrUnsupportedImage
+	}
+
+	if err == imaging.ErrTooManyPixels {
+		return out, ErrImageTooLarge
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo decodificar la imagen: %v", err)
+	}
+
+	token, err := randomToken(12)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el nombre de la imagen: %v", err)
+	}
+
+	key := fmt.Sprintf("%s/%d/%s", kind, uid, token)
+	flat := imaging.Flatten(img)
+	for _, size := range sizes {
+		var buf bytes.Buffer
+		if err = imaging.EncodeJPEG(&buf, imaging.Fill(flat, size.X, size.Y), imageQuality); err != nil {
+			return out, fmt.Errorf("no se pudo codificar la imagen: %v", err)
+		}
+
+		if err = s.cfg.Blobs.Put(ctx, imageKey(key, size), "image/jpeg", &buf); err != nil {
+			s.deleteImageFiles(ctx, key, sizes)
+			return out, fmt.Errorf("no se pudo guardar la imagen: %v", err)
+		}
+	}
+
+	old, err := set(ctx, uid, key)
+	if err != nil {
+		s.deleteImageFiles(ctx, key, sizes)
+		return out, fmt.Errorf("no se pudo cambiar la imagen: %v", err)
+	}
+
+	s.deleteImageFiles(ctx, old, sizes)
+	return s.ownProfile(ctx, uid)
+}
+
+func (s *Service) deleteImage(ctx context.Context, sizes []image.Point,
+	set func(context.Context, int64, string) (string, error)) (UserProfile, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return UserProfile{}, ErrUnauthenticated
+	}
+
+	old, err := set(ctx, uid, "")
+	if err != nil {
+		return UserProfile{}, fmt.Errorf("no se pudo quitar la imagen: %v", err)
+	}
+
+	s.deleteImageFiles(ctx, old, sizes)
+	return s.ownProfile(ctx, uid)
+}
+
+//deleteUserImages borra los archivos del avatar y la portada del usuario.
+func (s *Service) deleteUserImages(ctx context.Context, uid int64) error {
+	avatar, cover, err := s.store.UserImages(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudieron consultar las imágenes: %v", err)
+	}
+
+	s.deleteImageFiles(ctx, avatar, avatarSizes)
+	s.deleteImageFiles(ctx, cover, coverSizes)
+	return nil
+}
+
+//deleteImageFiles borra los archivos de todos los tamaños de la imagen. Si
+//falla solo quedan archivos huérfanos, así que no devuelve el error.
+func (s *Service) deleteImageFiles(ctx context.Context, key string, sizes []image.Point) {
+	if key == "" || s.cfg.Blobs == nil {
+		return
+	}
+
+	for _, size := range sizes {
+		if err := s.cfg.Blobs.Delete(ctx, imageKey(key, size)); err != nil {
+			log.Printf("no se pudo borrar la imagen %s: %v", imageKey(key, size), err)
+		}
+	}
+}
+
+//ownProfile devuelve el perfil del usuario autenticado.
+func (s *Service) ownProfile(ctx context.Context, uid int64) (UserProfile, error) {
+	u, err := s.store.UserByID(ctx, uid)
+	if err != nil {
+		return UserProfile{}, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	out, err := s.store.UserProfile(ctx, uid, u.Username)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el perfil: %v", err)
+	}
+
+	out.Me = true
+	s.fillImageURLs(&out)
+	return out, nil
+}
+
+//fillAvatarURL llena AvatarURL y AvatarThumbnails a partir de Avatar.
+func (s *Service) fillAvatarURL(u *User) {
+	u.AvatarURL, u.AvatarThumbnails = s.imageURLs(u.Avatar, avatarSizes)
+}
+
+//fillImageURLs llena las direcciones del avatar y la portada del perfil.
+func (s *Service) fillImageURLs(u *UserProfile) {
+	s.fillAvatarURL(&u.User)
+	u.CoverURL, u.CoverThumbnails = s.imageURLs(u.Cover, coverSizes)
+}
+
+//imageURLs devuelve la dirección del tamaño principal de la imagen y las de
+//sus miniaturas por tamaño, como "96x96".
+func (s *Service) imageURLs(key string, sizes []image.Point) (string, map[string]string) {
+	if key == "" || s.cfg.Blobs == nil {
+		return "", nil
+	}
+
+	thumbnails := make(map[string]string, len(sizes)-1)
+	for _, size := range sizes[1:] {
+		thumbnails[fmt.Sprintf("%dx%d", size.X, size.Y)] = s.cfg.Blobs.URL(imageKey(key, size))
+	}
+
+	return s.cfg.Blobs.URL(imageKey(key, sizes[0])), thumbnails
+}
+
+func imageKey(key string, size image.Point) string {
+	return fmt.Sprintf("%s_%dx%d.jpg", key, size.X, size.Y)
+}
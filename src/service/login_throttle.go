@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	//ErrInvalidCredentials cuando el email no existe o la contraseña no
+	//coincide. Es el mismo error en los dos casos para no filtrar qué cuentas hay.
+	ErrInvalidCredentials = errors.New("email o contraseña incorrectos")
+
+	//ErrAccountLocked cuando la cuenta tiene demasiados intentos fallidos.
+	ErrAccountLocked = errors.New("cuenta bloqueada temporalmente por intentos fallidos")
+
+	//ErrTooManyLoginAttempts cuando la IP tiene demasiados intentos fallidos.
+	ErrTooManyLoginAttempts = errors.New("demasiados intentos de inicio de sesión, intente más tarde")
+)
+
+//LoginThrottledError es el error del login cuando la cuenta (ErrAccountLocked)
+//o la IP (ErrTooManyLoginAttempts) están bloqueadas, con el tiempo que falta
+//para poder intentar de nuevo.
+type LoginThrottledError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return e.Err.Error()
+}
+
+//Unwrap devuelve ErrAccountLocked o ErrTooManyLoginAttempts.
+func (e *LoginThrottledError) Unwrap() error {
+	return e.Err
+}
+
+//LoginFailures son los intentos fallidos seguidos de una llave: una cuenta
+//("email:...") o una IP ("ip:...").
+type LoginFailures struct {
+	Key           string
+	Count         int
+	LastFailureAt time.Time
+}
+
+//LoginAttemptStore cuenta los intentos de login fallidos.
+type LoginAttemptStore interface {
+	//RecordLoginFailure suma un intento fallido a la llave y devuelve el
+	//total. Si el último fallo fue antes de at-window la cuenta empieza de nuevo.
+	RecordLoginFailure(ctx context.Context, key string, at time.Time, window time.Duration) (LoginFailures, error)
+
+	//LoginFailures devuelve los intentos fallidos de la llave, en cero si no hay.
+	LoginFailures(ctx context.Context, key string) (LoginFailures, error)
+
+	//ClearLoginFailures borra los intentos fallidos de la llave.
+	ClearLoginFailures(ctx context.Context, key string) error
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+//compareDummyPassword gasta el mismo tiempo que una comparación real, para
+//que un email que no existe no responda más rápido que una contraseña mala.
+//El hash de relleno se genera con el hasher configurado.
+func (s *Service) compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = s.cfg.PasswordHasher.Hash("contraseña de relleno")
+	})
+
+	s.cfg.PasswordHasher.Verify(password, dummyHash)
+}
+
+//UnlockLogin borra los intentos fallidos de una cuenta, por su email, o de
+//una IP, para desbloquearla antes de tiempo.
+func (s *Service) UnlockLogin(ctx context.Context, email, ip string) error {
+	var keys []string
+	if email = strings.TrimSpace(email); email != "" {
+		keys = append(keys, accountThrottleKey(email))
+	}
+
+	if ip = strings.TrimSpace(ip); ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+
+	for _, k := range keys {
+		if err := s.store.ClearLoginFailures(ctx, k); err != nil {
+			return fmt.Errorf("no se pudieron borrar los intentos fallidos de %s: %v", k, err)
+		}
+	}
+
+	return nil
+}
+
+//checkLoginThrottle devuelve un *LoginThrottledError si la IP de la petición
+//o la cuenta están bloqueadas.
+func (s *Service) checkLoginThrottle(ctx context.Context, email string) error {
+	now := time.Now()
+	client, _ := ctx.Value(KeyClientInfo).(ClientInfo)
+	if client.IP != "" {
+		f, err := s.store.LoginFailures(ctx, ipThrottleKey(client.IP))
+		if err != nil {
+			return fmt.Errorf("no se pudieron consultar los intentos fallidos: %v", err)
+		}
+
+		if until := s.lockedUntil(f, s.cfg.LoginIPMaxFailures); now.Before(until) {
+			return &LoginThrottledError{Err: ErrTooManyLoginAttempts, RetryAfter: until.Sub(now)}
+		}
+	}
+
+	f, err := s.store.LoginFailures(ctx, accountThrottleKey(email))
+	if err != nil {
+		return fmt.Errorf("no se pudieron consultar los intentos fallidos: %v", err)
+	}
+
+	if until := s.lockedUntil(f, s.cfg.LoginMaxFailures); now.Before(until) {
+		return &LoginThrottledError{Err: ErrAccountLocked, RetryAfter: until.Sub(now)}
+	}
+
+	return nil
+}
+
+//loginFailed registra el intento fallido para la cuenta y la IP y devuelve
+//ErrInvalidCredentials.
+func (s *Service) loginFailed(ctx context.Context, email string) error {
+	if err := s.recordLoginFailure(ctx, email); err != nil {
+		return err
+	}
+
+	return ErrInvalidCredentials
+}
+
+//recordLoginFailure registra un intento fallido para la cuenta y la IP.
+func (s *Service) recordLoginFailure(ctx context.Context, email string) error {
+	now := time.Now()
+	keys := []string{accountThrottleKey(email)}
+	if client, _ := ctx.Value(KeyClientInfo).(ClientInfo); client.IP != "" {
+		keys = append(keys, ipThrottleKey(client.IP))
+	}
+
+	for _, k := range keys {
+		if _, err := s.store.RecordLoginFailure(ctx, k, now, s.cfg.LoginFailureWindow); err != nil {
+			return fmt.Errorf("no se pudo registrar el intento fallido: %v", err)
+		}
+	}
+
+	return nil
+}
+
+//checkCurrentPassword verifica la contraseña de la cuenta para confirmar una
+//acción de un usuario autenticado. Los fallos cuentan para el bloqueo de la
+//cuenta como los del login, para que una sesión robada no sirva para probar
+//contraseñas.
+func (s *Service) checkCurrentPassword(ctx context.Context, email, password, hash string) error {
+	if err := s.checkLoginThrottle(ctx, email); err != nil {
+		return err
+	}
+
+	if ok, _ := s.cfg.PasswordHasher.Verify(password, hash); !ok {
+		if err := s.recordLoginFailure(ctx, email); err != nil {
+			return err
+		}
+
+		return ErrInvalidCurrentPassword
+	}
+
+	return nil
+}
+
+//loginSucceeded borra los intentos fallidos de la cuenta. Los de la IP se
+//quedan, para que una cuenta propia no sirva para probar contraseñas de otras.
+func (s *Service) loginSucceeded(ctx context.Context, email string) error {
+	if err := s.store.ClearLoginFailures(ctx, accountThrottleKey(email)); err != nil {
+		return fmt.Errorf("no se pudieron borrar los intentos fallidos: %v", err)
+	}
+
+	return nil
+}
+
+//lockedUntil calcula hasta cuándo está bloqueada la llave: a partir de max
+//fallos el bloqueo dura LoginLockout y se duplica con cada fallo más, hasta
+//LoginMaxLockout.
+func (s *Service) lockedUntil(f LoginFailures, max int) time.Time {
+	if f.Count < max {
+		return time.Time{}
+	}
+
+	d := s.cfg.LoginMaxLockout
+	if n := f.Count - max; n < 32 {
+		if b := s.cfg.LoginLockout << uint(n); b > 0 && b < d {
+			d = b
+		}
+	}
+
+	return f.LastFailureAt.Add(d)
+}
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
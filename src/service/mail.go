@@ -0,0 +1,18 @@
+package service
+
+import (
+	"context"
+)
+
+//Mail es un correo para un usuario.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+//Mailer envía correos. El paquete mail tiene implementaciones que escriben
+//los correos en el log o en archivos, para desarrollo y pruebas.
+type Mailer interface {
+	Send(ctx context.Context, m Mail) error
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	//maxOAuthClients es cuántas aplicaciones OAuth puede registrar un usuario.
+	maxOAuthClients = 20
+
+	//maxRedirectURIs es cuántas URIs de redirección puede tener una aplicación.
+	maxRedirectURIs = 10
+)
+
+var (
+	//ErrOAuthClientNotFound cuando la aplicación no existe o es de otro usuario.
+	ErrOAuthClientNotFound = errors.New("aplicación no encontrada")
+
+	//ErrInvalidClientName cuando el nombre de la aplicación está vacío o es muy largo.
+	ErrInvalidClientName = errors.New("nombre de aplicación inválido")
+
+	//ErrInvalidRedirectURI cuando una URI de redirección no es https, o http
+	//a localhost, o no hay ninguna.
+	ErrInvalidRedirectURI = errors.New("URI de redirección inválida")
+
+	//ErrTooManyOAuthClients cuando el usuario ya tiene el máximo de aplicaciones.
+	ErrTooManyOAuthClients = errors.New("demasiadas aplicaciones, borre alguna")
+
+	//ErrOAuthConsentNotFound cuando el usuario no ha autorizado a la aplicación.
+	ErrOAuthConsentNotFound = errors.New("la aplicación no está autorizada")
+)
+
+//OAuthClient es una aplicación de terceros registrada por un usuario. Las
+//aplicaciones públicas, como las móviles o de navegador, no tienen secreto;
+//todas usan PKCE.
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	OwnerID      int64     `json:"-"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"-"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+//CreatedOAuthClient es una aplicación recién registrada, con su secreto.
+type CreatedOAuthClient struct {
+	OAuthClient
+	Secret string `json:"client_secret,omitempty"`
+}
+
+//OAuthConsent es la autorización que dio un usuario a una aplicación.
+type OAuthConsent struct {
+	UserID     int64     `json:"-"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+//OAuthStore guarda las aplicaciones OAuth, las autorizaciones de los
+//usuarios y los códigos y refresh tokens que se les emiten.
+type OAuthStore interface {
+	//CreateOAuthClient guarda una aplicación nueva.
+	CreateOAuthClient(ctx context.Context, c OAuthClient) error
+
+	//OAuthClient devuelve la aplicación o ErrOAuthClientNotFound.
+	OAuthClient(ctx context.Context, id string) (OAuthClient, error)
+
+	//OAuthClients devuelve las aplicaciones del usuario, de la más reciente
+	//a la más antigua.
+	OAuthClients(ctx context.Context, ownerID int64) ([]OAuthClient, error)
+
+	//DeleteOAuthClient borra la aplicación si es del usuario, junto con sus
+	//autorizaciones, códigos y refresh tokens, y devuelve si la borró.
+	DeleteOAuthClient(ctx context.Context, ownerID int64, id string) (bool, error)
+
+	//SaveOAuthConsent crea la autorización o le cambia los alcances,
+	//conservando su CreatedAt.
+	SaveOAuthConsent(ctx context.Context, c OAuthConsent) error
+
+	//OAuthConsent devuelve la autorización o ErrOAuthConsentNotFound.
+	OAuthConsent(ctx context.Context, userID int64, clientID string) (OAuthConsent, error)
+
+	//OAuthConsents devuelve las autorizaciones del usuario con el nombre de
+	//cada aplicación.
+	OAuthConsents(ctx context.Context, userID int64) ([]OAuthConsent, error)
+
+	//DeleteOAuthConsent borra la autorización y los refresh tokens de esa
+	//aplicación para el usuario, y devuelve si la borró.
+	DeleteOAuthConsent(ctx context.Context, userID int64, clientID string) (bool, error)
+
+	//CreateOAuthCode guarda un código de autorización nuevo.
+	CreateOAuthCode(ctx context.Context, c OAuthCode) error
+
+	//OAuthCode devuelve el código con ese hash o ErrOAuthCodeNotFound.
+	OAuthCode(ctx context.Context, hash string) (OAuthCode, error)
+
+	//UseOAuthCode marca el código como usado solo si no se había usado y
+	//devuelve si lo marcó.
+	UseOAuthCode(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+
+	//CreateOAuthRefreshToken guarda un refresh token nuevo.
+	CreateOAuthRefreshToken(ctx context.Context, t OAuthRefreshToken) error
+
+	//OAuthRefreshToken devuelve el refresh token con ese hash o
+	//ErrOAuthRefreshTokenNotFound.
+	OAuthRefreshToken(ctx context.Context, hash string) (OAuthRefreshToken, error)
+
+	//RevokeOAuthRefreshToken revoca el refresh token solo si no estaba
+	//revocado y devuelve si lo revocó.
+	RevokeOAuthRefreshToken(ctx context.Context, hash string, revokedAt time.Time) (bool, error)
+
+	//RevokeOAuthRefreshTokens revoca los refresh tokens de la aplicación
+	//para el usuario.
+	RevokeOAuthRefreshTokens(ctx context.Context, userID int64, clientID string, revokedAt time.Time) error
+}
+
+//CreateOAuthClient registra una aplicación del usuario autenticado. El
+//secreto de las aplicaciones confidenciales solo se muestra aquí.
+func (s *Service) CreateOAuthClient(ctx context.Context, name string, redirectURIs []string, public bool) (CreatedOAuthClient, error) {
+	var out CreatedOAuthClient
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 50 {
+		return out, ErrInvalidClientName
+	}
+
+	if len(redirectURIs) == 0 || len(redirectURIs) > maxRedirectURIs {
+		return out, ErrInvalidRedirectURI
+	}
+
+	uris := make([]string, 0, len(redirectURIs))
+	for _, u := range redirectURIs {
+		u = strings.TrimSpace(u)
+		if !validRedirectURI(u) {
+			return out, ErrInvalidRedirectURI
+		}
+
+		uris = append(uris, u)
+	}
+
+	cc, err := s.store.OAuthClients(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudieron consultar las aplicaciones: %v", err)
+	}
+
+	if len(cc) >= maxOAuthClients {
+		return out, ErrTooManyOAuthClients
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el id de la aplicación: %v", err)
+	}
+
+	out.OAuthClient = OAuthClient{
+		ID:           id,
+		OwnerID:      uid,
+		Name:         name,
+		RedirectURIs: uris,
+		Public:       public,
+		CreatedAt:    time.Now(),
+	}
+
+	if !public {
+		out.Secret, err = randomToken(32)
+		if err != nil {
+			return out, fmt.Errorf("no se pudo generar el secreto de la aplicación: %v", err)
+		}
+
+		out.SecretHash = hashToken(out.Secret)
+	}
+
+	if err = s.store.CreateOAuthClient(ctx, out.OAuthClient); err != nil {
+		return out, fmt.Errorf("no se pudo guardar la aplicación: %v", err)
+	}
+
+	return out, nil
+}
+
+//OAuthClients devuelve las aplicaciones del usuario autenticado.
+func (s *Service) OAuthClients(ctx context.Context) ([]OAuthClient, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	cc, err := s.store.OAuthClients(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar las aplicaciones: %v", err)
+	}
+
+	return cc, nil
+}
+
+//DeleteOAuthClient borra una aplicación del usuario autenticado. Los tokens
+//que se le emitieron dejan de servir.
+func (s *Service) DeleteOAuthClient(ctx context.Context, id string) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	ok, err := s.store.DeleteOAuthClient(ctx, uid, id)
+	if err != nil {
+		return fmt.Errorf("no se pudo borrar la aplicación: %v", err)
+	}
+
+	if !ok {
+		return ErrOAuthClientNotFound
+	}
+
+	return nil
+}
+
+//OAuthConsents devuelve las aplicaciones autorizadas por el usuario autenticado.
+func (s *Service) OAuthConsents(ctx context.Context) ([]OAuthConsent, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	cc, err := s.store.OAuthConsents(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar las autorizaciones: %v", err)
+	}
+
+	return cc, nil
+}
+
+//RevokeOAuthConsent quita la autorización a una aplicación. Sus tokens de
+//acceso y refresh tokens dejan de servir.
+func (s *Service) RevokeOAuthConsent(ctx context.Context, clientID string) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	ok, err := s.store.DeleteOAuthConsent(ctx, uid, clientID)
+	if err != nil {
+		return fmt.Errorf("no se pudo borrar la autorización: %v", err)
+	}
+
+	if !ok {
+		return ErrOAuthConsentNotFound
+	}
+
+	return nil
+}
+
+//checkOAuthConsent rechaza los tokens de una aplicación que el usuario ya no
+//autoriza, o que se emitieron antes de volver a autorizarla.
+func (s *Service) checkOAuthConsent(ctx context.Context, uid int64, clientID string, issuedAt time.Time) error {
+	c, err := s.store.OAuthConsent(ctx, uid, clientID)
+	if err == ErrOAuthConsentNotFound {
+		return ErrTokenRevoked
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar la autorización: %v", err)
+	}
+
+	if issuedAt.Before(c.CreatedAt.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+//validRedirectURI acepta URIs absolutas https sin fragmento, y http solo a
+//localhost para poder probar en local.
+func validRedirectURI(raw string) bool {
+	if len(raw) > 255 {
+		return false
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+
+	return false
+}
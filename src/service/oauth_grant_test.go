@@ -0,0 +1,277 @@
+package service_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/keyring"
+	"github.com/Mynor2397/social-network/src/memory"
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+const (
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mJ92K9zg5Q1rJxk3bWhL8sY7vN0pQe"
+)
+
+//discardMailer no envía los correos.
+type discardMailer struct{}
+
+func (discardMailer) Send(ctx context.Context, msg service.Mail) error {
+	return nil
+}
+
+//newTestService crea un servicio con el almacenamiento en memoria.
+func newTestService(t *testing.T) (*service.Service, *memory.Store) {
+	t.Helper()
+
+	codec, err := keyring.New(keyring.File{
+		Active: "test",
+		Keys:   []keyring.Key{{ID: "test", Secret: "llave-de-prueba-de-32-caracteres", CreatedAt: time.Now()}},
+	}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := memory.New()
+	return service.New(store, codec, discardMailer{}, service.Config{}), store
+}
+
+//newTestUser crea un usuario y devuelve un contexto autenticado como él.
+func newTestUser(t *testing.T, s *service.Service, store *memory.Store, username string) context.Context {
+	t.Helper()
+
+	ctx := context.Background()
+	//CreateUser devuelve ErrUserOk cuando crea al usuario
+	if err := s.CreateUser(ctx, username+"@example.com", username, "Tortuga-verde-42"); err != service.ErrUserOk {
+		t.Fatal(err)
+	}
+
+	uid, err := store.UserIDByUsername(ctx, username)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return context.WithValue(ctx, service.KeyPrincipal, service.Principal{UserID: uid, IssuedAt: time.Now()})
+}
+
+//authorizeTestClient registra una aplicación confidencial, la autoriza con
+//PKCE y devuelve la aplicación y el código de autorización.
+func authorizeTestClient(t *testing.T, s *service.Service, ctx context.Context) (service.CreatedOAuthClient, string) {
+	t.Helper()
+
+	c, err := s.CreateOAuthClient(ctx, "app", []string{testRedirectURI}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte(testVerifier))
+	redirect, err := s.Authorize(ctx, service.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            c.ID,
+		RedirectURI:         testRedirectURI,
+		Scope:               service.ScopeUsersRead,
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(redirect)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := u.Query().Get("code")
+	if code == "" {
+		t.Fatalf("la redirección %q no trae el código", redirect)
+	}
+
+	return c, code
+}
+
+func codeRequest(c service.CreatedOAuthClient, code string) service.TokenRequest {
+	return service.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testVerifier,
+		ClientID:     c.ID,
+		ClientSecret: c.Secret,
+	}
+}
+
+func assertOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+
+	oerr, ok := err.(*service.OAuthError)
+	if !ok {
+		t.Fatalf("se esperaba un *OAuthError %s, se obtuvo %v", code, err)
+	}
+
+	if oerr.Code != code {
+		t.Fatalf("se esperaba %s, se obtuvo %s", code, oerr.Code)
+	}
+}
+
+func TestOAuthTokenRejectsWrongVerifier(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	req := codeRequest(c, code)
+	req.CodeVerifier = strings.Repeat("x", 43)
+	_, err := s.OAuthToken(ctx, req)
+	assertOAuthError(t, err, "invalid_grant")
+
+	//el código no se gasta con un verifier malo
+	if _, err = s.OAuthToken(ctx, codeRequest(c, code)); err != nil {
+		t.Fatalf("el verifier correcto debería funcionar: %v", err)
+	}
+}
+
+func TestOAuthCodeIsSingleUse(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	out, err := s.OAuthToken(ctx, codeRequest(c, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.OAuthToken(ctx, codeRequest(c, code))
+	assertOAuthError(t, err, "invalid_grant")
+
+	//reutilizar el código revoca lo que se emitió con él
+	_, err = s.OAuthToken(ctx, service.TokenRequest{
+		GrantType:    "refresh_token",
+		RefreshToken: out.RefreshToken,
+		ClientID:     c.ID,
+		ClientSecret: c.Secret,
+	})
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthRedirectURIMismatch(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	_, err := s.Authorize(ctx, service.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            c.ID,
+		RedirectURI:         "https://evil.example.com/callback",
+		Scope:               service.ScopeUsersRead,
+		CodeChallenge:       strings.Repeat("a", 43),
+		CodeChallengeMethod: "S256",
+	}, true)
+	assertOAuthError(t, err, "invalid_request")
+
+	req := codeRequest(c, code)
+	req.RedirectURI = testRedirectURI + "/otra"
+	_, err = s.OAuthToken(ctx, req)
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthRevokeAndIntrospect(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	out, err := s.OAuthToken(ctx, codeRequest(c, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, token := range []string{out.AccessToken, out.RefreshToken} {
+		info, err := s.IntrospectOAuthToken(ctx, c.ID, c.Secret, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !info.Active || info.Username != "ana" || info.ClientID != c.ID || info.Scope != service.ScopeUsersRead {
+			t.Fatalf("introspección inesperada: %+v", info)
+		}
+
+		if err = s.RevokeOAuthToken(ctx, c.ID, c.Secret, token); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err = s.IntrospectOAuthToken(ctx, c.ID, c.Secret, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if info.Active {
+			t.Fatalf("el token revocado sigue activo: %+v", info)
+		}
+	}
+
+	_, err = s.IntrospectOAuthToken(ctx, c.ID, "secreto-malo", out.AccessToken)
+	assertOAuthError(t, err, "invalid_client")
+}
+
+func TestOAuthCodeAfterLogoutAll(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	if err := s.LogoutAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.OAuthToken(ctx, codeRequest(c, code))
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthSuspendedUser(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	admin := newTestUser(t, s, store, "root")
+
+	p, _ := service.PrincipalFromContext(admin)
+	if err := store.SetUserRoles(admin, p.UserID, []string{service.RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, code := authorizeTestClient(t, s, ctx)
+	if err := s.SuspendUser(admin, "ana", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.OAuthToken(ctx, codeRequest(c, code))
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthRefreshSuspendedUser(t *testing.T) {
+	s, store := newTestService(t)
+	ctx := newTestUser(t, s, store, "ana")
+	c, code := authorizeTestClient(t, s, ctx)
+
+	out, err := s.OAuthToken(ctx, codeRequest(c, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//se suspende sin revocar los tokens para probar sólo la suspensión
+	p, _ := service.PrincipalFromContext(ctx)
+	now := time.Now()
+	if err = store.SetUserSuspended(ctx, p.UserID, &now); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.OAuthToken(ctx, service.TokenRequest{
+		GrantType:    "refresh_token",
+		RefreshToken: out.RefreshToken,
+		ClientID:     c.ID,
+		ClientSecret: c.Secret,
+	})
+	assertOAuthError(t, err, "invalid_grant")
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+//oidcLoginLifespan es cuánto tiene el usuario para volver del proveedor.
+const oidcLoginLifespan = 10 * time.Minute
+
+var (
+	//ErrIdentityProviderNotFound cuando no hay un proveedor con ese nombre.
+	ErrIdentityProviderNotFound = errors.New("proveedor de identidad no encontrado")
+
+	//ErrInvalidOIDCState cuando el state no existe, expiró, ya se usó o es
+	//de otro proveedor.
+	ErrInvalidOIDCState = errors.New("state inválido, inicie sesión de nuevo")
+
+	//ErrOIDCLoginFailed cuando el proveedor rechaza el código o el ID token
+	//no es válido.
+	ErrOIDCLoginFailed = errors.New("no se pudo iniciar sesión con el proveedor")
+
+	//ErrOIDCEmailNotVerified cuando una identidad nueva no trae un email
+	//verificado por el proveedor.
+	ErrOIDCEmailNotVerified = errors.New("el proveedor no verificó el email")
+
+	//ErrOIDCAccountConflict cuando ya existe una cuenta con el email pero no
+	//lo ha verificado, y enlazarla daría la cuenta a quien la registró.
+	ErrOIDCAccountConflict = errors.New("ya existe una cuenta con ese email, inicie sesión y verifíquelo")
+
+	//ErrIdentityNotFound cuando la identidad externa no está enlazada.
+	ErrIdentityNotFound = errors.New("identidad no encontrada")
+)
+
+//ExternalIdentity es el usuario que devuelve un proveedor de identidad.
+type ExternalIdentity struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+}
+
+//IdentityProvider es un proveedor OpenID Connect externo. El paquete oidc
+//tiene la implementación.
+type IdentityProvider interface {
+	//Name es el nombre del proveedor en las rutas.
+	Name() string
+
+	//AuthCodeURL devuelve la URL del proveedor a la que se manda al usuario.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+
+	//Exchange cambia el código por el ID token, lo verifica y devuelve la
+	//identidad.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (ExternalIdentity, error)
+}
+
+//OIDCLogin es un login con un proveedor que espera la vuelta del usuario.
+//Solo se guarda el hash del state.
+type OIDCLogin struct {
+	Hash         string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+	UsedAt       *time.Time
+}
+
+//Identity enlaza una identidad externa con un usuario.
+type Identity struct {
+	Provider  string
+	Subject   string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+}
+
+//IdentityStore guarda los logins con proveedores en curso y las identidades
+//externas enlazadas.
+type IdentityStore interface {
+	//CreateOIDCLogin guarda un login nuevo.
+	CreateOIDCLogin(ctx context.Context, l OIDCLogin) error
+
+	//OIDCLogin devuelve el login con ese hash o ErrInvalidOIDCState.
+	OIDCLogin(ctx context.Context, hash string) (OIDCLogin, error)
+
+	//UseOIDCLogin marca el login como usado solo si no se había usado y
+	//devuelve si lo marcó.
+	UseOIDCLogin(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+
+	//IdentityUserID devuelve el usuario enlazado a la identidad o
+	//ErrIdentityNotFound.
+	IdentityUserID(ctx context.Context, provider, subject string) (int64, error)
+
+	//LinkIdentity enlaza la identidad con el usuario.
+	LinkIdentity(ctx context.Context, i Identity) error
+}
+
+//IdentityProviders devuelve los nombres de los proveedores configurados.
+func (s *Service) IdentityProviders() []string {
+	names := make([]string, 0, len(s.cfg.IdentityProviders))
+	for _, p := range s.cfg.IdentityProviders {
+		names = append(names, p.Name())
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (s *Service) identityProvider(name string) (IdentityProvider, bool) {
+	for _, p := range s.cfg.IdentityProviders {
+		if p.Name() == name {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+//StartOIDCLogin empieza un login con el proveedor y devuelve la URL a la que
+//se manda al usuario y el state, que el cliente debe guardar para comprobar
+//la vuelta.
+func (s *Service) StartOIDCLogin(ctx context.Context, provider string) (string, string, error) {
+	p, ok := s.identityProvider(provider)
+	if !ok {
+		return "", "", ErrIdentityProviderNotFound
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("no se pudo generar el state: %v", err)
+	}
+
+	nonce, err := randomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("no se pudo generar el nonce: %v", err)
+	}
+
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", fmt.Errorf("no se pudo generar el code verifier: %v", err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("no se pudo armar la URL de %s: %v", provider, err)
+	}
+
+	now := time.Now()
+	l := OIDCLogin{
+		Hash:         hashToken(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginLifespan),
+	}
+	if err = s.store.CreateOIDCLogin(ctx, l); err != nil {
+		return "", "", fmt.Errorf("no se pudo guardar el login: %v", err)
+	}
+
+	return authURL, state, nil
+}
+
+//FinishOIDCLogin termina el login cuando el usuario vuelve del proveedor con
+//el código. Si la identidad no está enlazada se enlaza con la cuenta que
+//tenga el mismo email verificado, o se crea una cuenta nueva. Como en Login,
+//si el usuario tiene la verificación en dos pasos se devuelve el desafío.
+func (s *Service) FinishOIDCLogin(ctx context.Context, provider, state, code string) (LoginOutput, error) {
+	var out LoginOutput
+
+	p, ok := s.identityProvider(provider)
+	if !ok {
+		return out, ErrIdentityProviderNotFound
+	}
+
+	state = strings.TrimSpace(state)
+	if state == "" {
+		return out, ErrInvalidOIDCState
+	}
+
+	hash := hashToken(state)
+	l, err := s.store.OIDCLogin(ctx, hash)
+	if err == ErrInvalidOIDCState {
+		return out, ErrInvalidOIDCState
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el login: %v", err)
+	}
+
+	now := time.Now()
+	if l.Provider != provider || l.UsedAt != nil || now.After(l.ExpiresAt) {
+		return out, ErrInvalidOIDCState
+	}
+
+	ok, err = s.store.UseOIDCLogin(ctx, hash, now)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo marcar el login: %v", err)
+	}
+
+	if !ok {
+		return out, ErrInvalidOIDCState
+	}
+
+	id, err := p.Exchange(ctx, code, l.CodeVerifier, l.Nonce)
+	if err != nil {
+		log.Printf("login con %s fallido: %v", provider, err)
+		return out, ErrOIDCLoginFailed
+	}
+
+	uid, err := s.identityUser(ctx, provider, id)
+	if err != nil {
+		return out, err
+	}
+
+	out.AuthUser, err = s.store.UserByID(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	if err = s.checkSuspended(ctx, uid); err != nil {
+		return out, err
+	}
+
+	if err = s.requireVerified(ctx, uid, RestrictLogin); err != nil {
+		return out, err
+	}
+
+	twoFactor, err := s.twoFactorRequired(ctx, uid)
+	if err != nil {
+		return out, err
+	}
+
+	if twoFactor {
+		c, err := s.startTwoFactorChallenge(ctx, uid)
+		if err != nil {
+			return LoginOutput{}, err
+		}
+
+		return LoginOutput{TwoFactor: c}, nil
+	}
+
+	if err = s.startLogin(ctx, &out, AuthMethodOIDC); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+//identityUser devuelve el usuario enlazado a la identidad. Si no hay, la
+//enlaza con la cuenta del mismo email solo cuando ambos lados lo verificaron;
+//si no existe la cuenta la crea con el email ya verificado y sin contraseña.
+func (s *Service) identityUser(ctx context.Context, provider string, id ExternalIdentity) (int64, error) {
+	uid, err := s.store.IdentityUserID(ctx, provider, id.Subject)
+	if err == nil {
+		return uid, nil
+	}
+
+	if err != ErrIdentityNotFound {
+		return 0, fmt.Errorf("no se pudo consultar la identidad: %v", err)
+	}
+
+	email := strings.TrimSpace(id.Email)
+	if !id.EmailVerified || !rxEmail.MatchString(email) {
+		return 0, ErrOIDCEmailNotVerified
+	}
+
+	user, _, err := s.store.Credentials(ctx, email)
+	switch {
+	case err == nil:
+		_, verified, err := s.store.EmailStatus(ctx, user.ID)
+		if err != nil {
+			return 0, fmt.Errorf("no se pudo consultar si el email está verificado: %v", err)
+		}
+
+		if !verified {
+			return 0, ErrOIDCAccountConflict
+		}
+
+		uid = user.ID
+	case err == ErrUserNotFound:
+		if uid, err = s.createIdentityUser(ctx, email, id); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	err = s.store.LinkIdentity(ctx, Identity{
+		Provider:  provider,
+		Subject:   id.Subject,
+		UserID:    uid,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo enlazar la identidad: %v", err)
+	}
+
+	return uid, nil
+}
+
+//createIdentityUser crea la cuenta de una identidad nueva con un username
+//libre sacado de su perfil. La cuenta no tiene contraseña hasta que el
+//usuario la recupere.
+func (s *Service) createIdentityUser(ctx context.Context, email string, id ExternalIdentity) (int64, error) {
+	username, err := s.freeUsername(ctx, id.PreferredUsername, email[:strings.Index(email, "@")], id.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	uid, err := s.store.CreateUser(ctx, email, username, "")
+	if err == ErrInvalidUser {
+		return 0, ErrOIDCAccountConflict
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("no se pudo crear el usuario: %v", err)
+	}
+
+	if _, err = s.store.MarkEmailVerified(ctx, uid, email, time.Now()); err != nil {
+		return 0, fmt.Errorf("no se pudo verificar el email: %v", err)
+	}
+
+	return uid, nil
+}
+
+//freeUsername devuelve el primer candidato válido que no esté ocupado ni
+//reservado, o con números al final si lo está.
+func (s *Service) freeUsername(ctx context.Context, candidates ...string) (string, error) {
+	base := "user"
+	for _, c := range candidates {
+		if c = sanitizeUsername(c); c != "" {
+			base = c
+			break
+		}
+	}
+
+	username := base
+	for i := 0; i < 10; i++ {
+		_, err := s.store.UserIDByUsername(ctx, username)
+		if err != nil && err != ErrUserNotFound {
+			return "", fmt.Errorf("no se pudo consultar el username: %v", err)
+		}
+
+		if err == ErrUserNotFound {
+			reserved, err := s.usernameReserved(ctx, username)
+			if err != nil {
+				return "", err
+			}
+
+			if !reserved {
+				return username, nil
+			}
+		}
+
+		n, err := rand.Int(rand.Reader, big.NewInt(100000))
+		if err != nil {
+			return "", fmt.Errorf("no se pudo generar el username: %v", err)
+		}
+
+		if len(base) > 13 {
+			base = base[:13]
+		}
+		username = fmt.Sprintf("%s%05d", base, n)
+	}
+
+	return "", fmt.Errorf("no se encontró un username libre para %s", base)
+}
+
+//sanitizeUsername deja solo los caracteres que acepta rxUsername.
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case (r >= '0' && r <= '9') || r == '_' || r == '-':
+			if b.Len() == 0 {
+				continue
+			}
+		default:
+			continue
+		}
+
+		b.WriteRune(r)
+		if b.Len() == 18 {
+			break
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//Reglas de la política de contraseñas.
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleEntropy      = "entropy"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleBreached     = "breached"
+)
+
+//minPersonalInfoLength es el largo desde el que el username o el email no
+//pueden aparecer en la contraseña.
+const minPersonalInfoLength = 3
+
+//BreachedPasswords dice si una contraseña apareció en una filtración. El
+//paquete password tiene una implementación con una lista local.
+type BreachedPasswords interface {
+	Breached(password string) (bool, error)
+}
+
+//PasswordViolation es una regla de la política que la contraseña no cumple.
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+//PasswordPolicyError cuando la contraseña no cumple la política, con todas
+//las reglas que falló.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	mm := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		mm[i] = v.Message
+	}
+
+	return "contraseña inválida: " + strings.Join(mm, "; ")
+}
+
+//checkPasswordPolicy devuelve un *PasswordPolicyError si la contraseña es
+//corta, fácil de adivinar, contiene el username o el email, o está en la
+//lista de contraseñas filtradas.
+func (s *Service) checkPasswordPolicy(password, username, email string) error {
+	var e PasswordPolicyError
+	violate := func(rule, format string, args ...interface{}) {
+		e.Violations = append(e.Violations, PasswordViolation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < s.cfg.PasswordMinLength {
+		violate(PasswordRuleMinLength, "debe tener al menos %d caracteres", s.cfg.PasswordMinLength)
+	}
+
+	if passwordEntropy(password) < float64(s.cfg.PasswordMinEntropy) {
+		violate(PasswordRuleEntropy, "es muy fácil de adivinar, use más caracteres o de más tipos")
+	}
+
+	lower := strings.ToLower(password)
+	local := email
+	if i := strings.Index(email, "@"); i >= 0 {
+		local = email[:i]
+	}
+
+	for _, info := range []string{username, local} {
+		info = strings.ToLower(info)
+		if utf8.RuneCountInString(info) >= minPersonalInfoLength && strings.Contains(lower, info) {
+			violate(PasswordRulePersonalInfo, "no puede contener el username ni el email")
+			break
+		}
+	}
+
+	if s.cfg.BreachedPasswords != nil {
+		breached, err := s.cfg.BreachedPasswords.Breached(password)
+		if err != nil {
+			return fmt.Errorf("no se pudo revisar si la contraseña está filtrada: %v", err)
+		}
+
+		if breached {
+			violate(PasswordRuleBreached, "apareció en una filtración de contraseñas, use otra")
+		}
+	}
+
+	if len(e.Violations) > 0 {
+		return &e
+	}
+
+	return nil
+}
+
+//checkUserPasswordPolicy es checkPasswordPolicy para un usuario que ya
+//existe.
+func (s *Service) checkUserPasswordPolicy(ctx context.Context, uid int64, password string) error {
+	u, err := s.store.UserByID(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	return s.checkPasswordPolicy(password, u.Username, email)
+}
+
+//passwordEntropy estima los bits de entropía de la contraseña por los tipos
+//de caracteres que usa. Los caracteres que repiten el anterior o siguen una
+//secuencia con él, como "aaa" o "123", cuentan un solo bit.
+func passwordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, c := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if c.used {
+			pool += c.size
+		}
+	}
+
+	if pool == 0 {
+		return 0
+	}
+
+	bits := 0.0
+	perChar := math.Log2(float64(pool))
+	prev := rune(-1)
+	for _, r := range password {
+		d := unicode.ToLower(r) - unicode.ToLower(prev)
+		if d >= -1 && d <= 1 {
+			bits++
+		} else {
+			bits += perChar
+		}
+		prev = r
+	}
+
+	return bits
+}
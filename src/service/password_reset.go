@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrInvalidResetToken cuando el token de recuperación no existe, expiró o ya se usó.
+	ErrInvalidResetToken = errors.New("token de recuperación inválido")
+
+	//ErrTooManyResetRequests cuando se piden demasiadas recuperaciones para un email.
+	ErrTooManyResetRequests = errors.New("demasiadas solicitudes de recuperación, intente más tarde")
+)
+
+//PasswordResetToken es un token de recuperación de contraseña. Solo se guarda
+//su hash.
+type PasswordResetToken struct {
+	Hash      string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+//PasswordResetStore guarda los tokens de recuperación de contraseña.
+type PasswordResetStore interface {
+	//CreatePasswordResetToken guarda un token nuevo.
+	CreatePasswordResetToken(ctx context.Context, t PasswordResetToken) error
+
+	//PasswordResetToken devuelve el token con ese hash o ErrInvalidResetToken.
+	PasswordResetToken(ctx context.Context, hash string) (PasswordResetToken, error)
+
+	//UsePasswordResetToken marca el token como usado solo si no se había
+	//usado y devuelve si lo marcó.
+	UsePasswordResetToken(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+
+	//RecordPasswordResetRequest registra una solicitud de recuperación para
+	//el email, exista o no el usuario.
+	RecordPasswordResetRequest(ctx context.Context, email string, at time.Time) error
+
+	//CountPasswordResetRequests cuenta las solicitudes para el email desde since.
+	CountPasswordResetRequests(ctx context.Context, email string, since time.Time) (int, error)
+}
+
+//RequestPasswordReset envía un token de recuperación al email si pertenece a
+//un usuario. No dice si el email existe, para no filtrar qué cuentas hay.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+	if !rxEmail.MatchString(email) {
+		return ErrInvalideEmail
+	}
+
+	now := time.Now()
+	n, err := s.store.CountPasswordResetRequests(ctx, email, now.Add(-s.cfg.PasswordResetWindow))
+	if err != nil {
+		return fmt.Errorf("no se pudieron contar las solicitudes de recuperación: %v", err)
+	}
+
+	if n >= s.cfg.PasswordResetMaxRequests {
+		return ErrTooManyResetRequests
+	}
+
+	if err = s.store.RecordPasswordResetRequest(ctx, email, now); err != nil {
+		return fmt.Errorf("no se pudo registrar la solicitud de recuperación: %v", err)
+	}
+
+	u, _, err := s.store.Credentials(ctx, email)
+	if err == ErrUserNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	return s.sendPasswordReset(ctx, u, email, now)
+}
+
+//sendPasswordReset crea un token de recuperación para u y se lo envía a email.
+func (s *Service) sendPasswordReset(ctx context.Context, u User, email string, now time.Time) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return fmt.Errorf("no se pudo generar el token de recuperación: %v", err)
+	}
+
+	err = s.store.CreatePasswordResetToken(ctx, PasswordResetToken{
+		Hash:      hashToken(token),
+		UserID:    u.ID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.cfg.PasswordResetLifespan),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo guardar el token de recuperación: %v", err)
+	}
+
+	err = s.mailer.Send(ctx, Mail{
+		To:      email,
+		Subject: "Recuperación de contraseña",
+		Body: fmt.Sprintf("Hola %s,\n\n"+
+			"Alguien pidió restablecer la contraseña de su cuenta. Use este token "+
+			"en los próximos %s para elegir una nueva:\n\n%s\n\n"+
+			"Si no fue usted, ignore este correo.\n", u.Username, s.cfg.PasswordResetLifespan, token),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo enviar el correo de recuperación: %v", err)
+	}
+
+	return nil
+}
+
+//ResetPassword cambia la contraseña con un token de recuperación y cierra
+//todas las sesiones del usuario.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return ErrInvalidPassword
+	}
+
+	hash := hashToken(token)
+	t, err := s.store.PasswordResetToken(ctx, hash)
+	if err == ErrInvalidResetToken {
+		return ErrInvalidResetToken
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el token de recuperación: %v", err)
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	//antes de gastar el token, para que pueda intentar con otra contraseña
+	if err = s.checkUserPasswordPolicy(ctx, t.UserID, password); err != nil {
+		return err
+	}
+
+	ok, err := s.store.UsePasswordResetToken(ctx, hash, now)
+	if err != nil {
+		return fmt.Errorf("no se pudo marcar el token de recuperación: %v", err)
+	}
+
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := s.cfg.PasswordHasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("no se pudo hashear la contraseña: %v", err)
+	}
+
+	if err = s.store.UpdatePassword(ctx, t.UserID, hashedPassword); err != nil {
+		return fmt.Errorf("no se pudo actualizar la contraseña: %v", err)
+	}
+
+	return s.revokeUserTokens(ctx, t.UserID)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	//personalAccessTokenPrefix distingue los tokens personales de los tokens
+	//de acceso de una sesión.
+	personalAccessTokenPrefix = "pat_"
+
+	//maxPersonalAccessTokens es cuántos tokens personales activos puede
+	//tener un usuario.
+	maxPersonalAccessTokens = 50
+
+	//maxPersonalAccessTokenDays es la vigencia más larga, en días, que puede
+	//tener un token personal. Es también la vigencia si no se pide otra.
+	maxPersonalAccessTokenDays = 365
+
+	//AuthMethodPersonalAccessToken es una petición hecha con un token personal.
+	AuthMethodPersonalAccessToken = "pat"
+
+	//ScopeUsersRead permite consultar usuarios y el usuario autenticado.
+	ScopeUsersRead = "users:read"
+
+	//ScopeFollowsWrite permite seguir y dejar de seguir usuarios.
+	ScopeFollowsWrite = "follows:write"
+)
+
+//PersonalAccessTokenScopes son los alcances que se pueden dar a un token
+//personal o a una aplicación OAuth. ScopeAll no está: lo que no tiene alcance propio, como manejar
+//sesiones o tokens, solo se puede hacer con una sesión.
+var PersonalAccessTokenScopes = []string{
+	ScopeUsersRead,
+	ScopeFollowsWrite,
+}
+
+var (
+	//ErrInvalidPersonalAccessToken cuando el token personal no existe, expiró
+	//o fue revocado.
+	ErrInvalidPersonalAccessToken = errors.New("token personal inválido")
+
+	//ErrPersonalAccessTokenNotFound cuando el token no existe o es de otro usuario.
+	ErrPersonalAccessTokenNotFound = errors.New("token personal no encontrado")
+
+	//ErrInvalidTokenName cuando el nombre del token está vacío o es muy largo.
+	ErrInvalidTokenName = errors.New("nombre de token inválido")
+
+	//ErrInvalidScope cuando se pide un alcance que no existe o ninguno.
+	ErrInvalidScope = errors.New("alcance inválido")
+
+	//ErrInvalidTokenExpiration cuando la vigencia del token no está entre
+	//uno y maxPersonalAccessTokenDays días.
+	ErrInvalidTokenExpiration = errors.New("vigencia de token inválida")
+
+	//ErrTooManyPersonalAccessTokens cuando el usuario ya tiene el máximo de tokens.
+	ErrTooManyPersonalAccessTokens = errors.New("demasiados tokens personales, revoque alguno")
+
+	//ErrInsufficientScope cuando el token no tiene el alcance de la ruta.
+	ErrInsufficientScope = errors.New("el token no tiene el alcance necesario")
+)
+
+//PersonalAccessToken es un token creado por el usuario para scripts y bots.
+//Solo se guarda su hash; el token se muestra una vez al crearlo.
+type PersonalAccessToken struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+//CreatedPersonalAccessToken es un token personal recién creado, con el token.
+type CreatedPersonalAccessToken struct {
+	PersonalAccessToken
+	Token string `json:"token"`
+}
+
+//PersonalAccessTokenStore guarda los tokens personales.
+type PersonalAccessTokenStore interface {
+	//CreatePersonalAccessToken guarda un token nuevo.
+	CreatePersonalAccessToken(ctx context.Context, t PersonalAccessToken) error
+
+	//PersonalAccessTokenByHash devuelve el token con ese hash o
+	//ErrInvalidPersonalAccessToken.
+	PersonalAccessTokenByHash(ctx context.Context, hash string) (PersonalAccessToken, error)
+
+	//PersonalAccessTokens devuelve los tokens del usuario que no han sido
+	//revocados, del más reciente al más antiguo.
+	PersonalAccessTokens(ctx context.Context, userID int64) ([]PersonalAccessToken, error)
+
+	//TouchPersonalAccessToken registra el uso del token.
+	TouchPersonalAccessToken(ctx context.Context, id string, usedAt time.Time) error
+
+	//RevokePersonalAccessToken revoca el token si es del usuario y no estaba
+	//revocado, y devuelve si lo revocó.
+	RevokePersonalAccessToken(ctx context.Context, userID int64, id string, revokedAt time.Time) (bool, error)
+}
+
+//CreatePersonalAccessToken crea un token personal con los alcances scopes
+//para el usuario autenticado, que expira en expiresInDays días. Si
+//expiresInDays es cero expira en maxPersonalAccessTokenDays días.
+func (s *Service) CreatePersonalAccessToken(ctx context.Context, name string, scopes []string, expiresInDays int) (CreatedPersonalAccessToken, error) {
+	var out CreatedPersonalAccessToken
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 50 {
+		return out, ErrInvalidTokenName
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return out, err
+	}
+
+	if expiresInDays == 0 {
+		expiresInDays = maxPersonalAccessTokenDays
+	}
+
+	if expiresInDays < 1 || expiresInDays > maxPersonalAccessTokenDays {
+		return out, ErrInvalidTokenExpiration
+	}
+
+	tt, err := s.store.PersonalAccessTokens(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudieron consultar los tokens personales: %v", err)
+	}
+
+	if len(tt) >= maxPersonalAccessTokens {
+		return out, ErrTooManyPersonalAccessTokens
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el token personal: %v", err)
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el token personal: %v", err)
+	}
+
+	now := time.Now()
+	expiresAt := now.AddDate(0, 0, expiresInDays)
+	out.Token = personalAccessTokenPrefix + secret
+	out.PersonalAccessToken = PersonalAccessToken{
+		ID:        id,
+		UserID:    uid,
+		Name:      name,
+		Hash:      hashToken(out.Token),
+		Scopes:    scopes,
+		CreatedAt: now,
+		ExpiresAt: &expiresAt,
+	}
+
+	if err = s.store.CreatePersonalAccessToken(ctx, out.PersonalAccessToken); err != nil {
+		return out, fmt.Errorf("no se pudo guardar el token personal: %v", err)
+	}
+
+	return out, nil
+}
+
+//PersonalAccessTokens devuelve los tokens personales del usuario autenticado.
+func (s *Service) PersonalAccessTokens(ctx context.Context) ([]PersonalAccessToken, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	tt, err := s.store.PersonalAccessTokens(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar los tokens personales: %v", err)
+	}
+
+	return tt, nil
+}
+
+//RevokePersonalAccessToken revoca un token personal del usuario autenticado.
+func (s *Service) RevokePersonalAccessToken(ctx context.Context, id string) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	ok, err := s.store.RevokePersonalAccessToken(ctx, uid, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("no se pudo revocar el token personal: %v", err)
+	}
+
+	if !ok {
+		return ErrPersonalAccessTokenNotFound
+	}
+
+	return nil
+}
+
+//authPersonalAccessToken devuelve el principal de un token personal. Los
+//tokens personales también caen con LogoutAll y el cambio de contraseña.
+func (s *Service) authPersonalAccessToken(ctx context.Context, token string) (Principal, error) {
+	t, err := s.store.PersonalAccessTokenByHash(ctx, hashToken(token))
+	if err == ErrInvalidPersonalAccessToken {
+		return Principal{}, ErrInvalidPersonalAccessToken
+	}
+
+	if err != nil {
+		return Principal{}, fmt.Errorf("no se pudo consultar el token personal: %v", err)
+	}
+
+	now := time.Now()
+	if t.RevokedAt != nil || (t.ExpiresAt != nil && now.After(*t.ExpiresAt)) {
+		return Principal{}, ErrInvalidPersonalAccessToken
+	}
+
+	revoked, err := s.store.TokenRevoked(ctx, t.UserID, t.ID, t.CreatedAt)
+	if err != nil {
+		return Principal{}, fmt.Errorf("No se pudo verificar la revocación del token: %v", err)
+	}
+
+	if revoked {
+		return Principal{}, ErrInvalidPersonalAccessToken
+	}
+
+	if t.LastUsedAt == nil || now.Sub(*t.LastUsedAt) >= sessionTouchInterval {
+		if err = s.store.TouchPersonalAccessToken(ctx, t.ID, now); err != nil {
+			log.Printf("no se pudo registrar el uso del token personal %s: %v", t.ID, err)
+		}
+	}
+
+	return Principal{
+		UserID:     t.UserID,
+		TokenID:    t.ID,
+		IssuedAt:   t.CreatedAt,
+		Scopes:     t.Scopes,
+		AuthMethod: AuthMethodPersonalAccessToken,
+	}, nil
+}
+
+//normalizeScopes valida los alcances de un token personal, quita repetidos
+//y los ordena.
+func normalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, sc := range scopes {
+		sc = strings.TrimSpace(sc)
+		if !validScope(sc) {
+			return nil, ErrInvalidScope
+		}
+
+		if !seen[sc] {
+			seen[sc] = true
+			out = append(out, sc)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	sort.Strings(out)
+	return out, nil
+}
+
+func validScope(scope string) bool {
+	for _, sc := range PersonalAccessTokenScopes {
+		if sc == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	//emailChangeLifespan es el tiempo de vida de un token de cambio de email.
+	emailChangeLifespan = time.Hour * 24
+
+	//maxEmailChanges es cuántos cambios de email se pueden pedir en
+	//emailChangeWindow.
+	maxEmailChanges   = 3
+	emailChangeWindow = time.Hour
+
+	//birthdayLayout es el formato de las fechas de nacimiento.
+	birthdayLayout = "2006-01-02"
+)
+
+var (
+	//ErrInvalidDisplayName cuando el nombre tiene más de 50 caracteres.
+	ErrInvalidDisplayName = errors.New("nombre inválido, máximo 50 caracteres")
+
+	//ErrInvalidBio cuando la biografía tiene más de 160 caracteres.
+	ErrInvalidBio = errors.New("biografía inválida, máximo 160 caracteres")
+
+	//ErrInvalidLocation cuando la ubicación tiene más de 50 caracteres.
+	ErrInvalidLocation = errors.New("ubicación inválida, máximo 50 caracteres")
+
+	//ErrInvalidWebsite cuando el sitio web no es una URL http o https.
+	ErrInvalidWebsite = errors.New("sitio web inválido")
+
+	//ErrInvalidBirthday cuando la fecha de nacimiento no es AAAA-MM-DD o no
+	//está en el pasado.
+	ErrInvalidBirthday = errors.New("fecha de nacimiento inválida")
+
+	//ErrInvalidCurrentPassword cuando falta la contraseña actual o no es
+	//correcta.
+	ErrInvalidCurrentPassword = errors.New("la contraseña actual no es correcta")
+
+	//ErrEmailTaken cuando el email nuevo ya es de otra cuenta.
+	ErrEmailTaken = errors.New("el email ya está en uso")
+
+	//ErrInvalidEmailChangeToken cuando el token de cambio de email no existe,
+	//expiró o ya se usó.
+	ErrInvalidEmailChangeToken = errors.New("token de cambio de email inválido")
+
+	//ErrTooManyEmailChanges cuando se piden demasiados cambios de email.
+	ErrTooManyEmailChanges = errors.New("demasiados cambios de email, intente más tarde")
+)
+
+//ProfileUpdate son los cambios al perfil del usuario autenticado. Los campos
+//en nil no se cambian y un texto vacío borra el campo. Para cambiar la
+//contraseña se necesita CurrentPassword, y también para cambiar el email si
+//la cuenta tiene contraseña.
+type ProfileUpdate struct {
+	DisplayName     *string `json:"display_name"`
+	Bio             *string `json:"bio"`
+	Location        *string `json:"location"`
+	Website         *string `json:"website"`
+	Birthday        *string `json:"birthday"`
+	Email           *string `json:"email"`
+	Password        *string `json:"password"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+//ProfileChanges son las columnas que cambian en la tabla user. Los campos
+//en nil no se cambian; Birthday vacío se guarda como NULL. UpdatedAt es
+//cuándo se hace el cambio.
+type ProfileChanges struct {
+	DisplayName  *string
+	Bio          *string
+	Location     *string
+	Website      *string
+	Birthday     *string
+	PasswordHash *string
+	UpdatedAt    time.Time
+}
+
+//EmailChangeToken es un token enviado al email nuevo para confirmar el
+//cambio. Solo se guarda su hash.
+type EmailChangeToken struct {
+	Hash      string
+	UserID    int64
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+//ProfileStore cambia el perfil de los usuarios.
+type ProfileStore interface {
+	//UpdateProfile cambia en una sola operación las columnas de c que no
+	//son nil. Si cambia la contraseña, en la misma operación marca como
+	//usados en c.UpdatedAt los tokens de recuperación y de cambio de email
+	//pendientes del usuario.
+	UpdateProfile(ctx context.Context, userID int64, c ProfileChanges) error
+
+	//CreateEmailChangeToken guarda un token nuevo.
+	CreateEmailChangeToken(ctx context.Context, t EmailChangeToken) error
+
+	//EmailChangeToken devuelve el token con ese hash o
+	//ErrInvalidEmailChangeToken.
+	EmailChangeToken(ctx context.Context, hash string) (EmailChangeToken, error)
+
+	//CountEmailChangeTokens cuenta los tokens del usuario creados desde since.
+	CountEmailChangeTokens(ctx context.Context, userID int64, since time.Time) (int, error)
+
+	//ConfirmEmailChange marca el token como usado y, en la misma operación,
+	//cambia el email del usuario por el del token, lo marca como verificado
+	//en usedAt y marca como usados los demás tokens de recuperación y de
+	//cambio de email pendientes del usuario. Devuelve false si el token ya
+	//se había usado y ErrEmailTaken si el email ya es de otra cuenta; en los
+	//dos casos no cambia nada.
+	ConfirmEmailChange(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+}
+
+//UpdateProfile cambia el perfil del usuario autenticado y devuelve el perfil
+//nuevo. El email no cambia hasta que se confirme con el token que se envía
+//al email nuevo. Cambiar la contraseña cierra todas las sesiones, también la
+//de la petición.
+func (s *Service) UpdateProfile(ctx context.Context, in ProfileUpdate) (UserProfile, error) {
+	var out UserProfile
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	var c ProfileChanges
+	var err error
+	if c.DisplayName, err = trimmedField(in.DisplayName, 50, ErrInvalidDisplayName); err != nil {
+		return out, err
+	}
+
+	if c.Bio, err = trimmedField(in.Bio, 160, ErrInvalidBio); err != nil {
+		return out, err
+	}
+
+	if c.Location, err = trimmedField(in.Location, 50, ErrInvalidLocation); err != nil {
+		return out, err
+	}
+
+	if c.Website, err = trimmedField(in.Website, 255, ErrInvalidWebsite); err != nil {
+		return out, err
+	}
+
+	if c.Website != nil && *c.Website != "" && !validWebsite(*c.Website) {
+		return out, ErrInvalidWebsite
+	}
+
+	if c.Birthday, err = trimmedField(in.Birthday, len(birthdayLayout), ErrInvalidBirthday); err != nil {
+		return out, err
+	}
+
+	if c.Birthday != nil && *c.Birthday != "" && !validBirthday(*c.Birthday) {
+		return out, ErrInvalidBirthday
+	}
+
+	user, err := s.store.UserByID(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	var newEmail string
+	if in.Email != nil {
+		newEmail = strings.TrimSpace(*in.Email)
+		if !rxEmail.MatchString(newEmail) {
+			return out, ErrInvalideEmail
+		}
+
+		if strings.EqualFold(newEmail, email) {
+			newEmail = ""
+		}
+	}
+
+	if in.Password != nil || newEmail != "" {
+		_, hash, err := s.store.Credentials(ctx, email)
+		if err != nil {
+			return out, fmt.Errorf("no se pudo consultar la contraseña: %v", err)
+		}
+
+		//las cuentas creadas con un proveedor externo no tienen contraseña
+		//y pueden cambiar el email sin ella
+		if in.Password != nil || hash != "" {
+			if err = s.checkCurrentPassword(ctx, email, in.CurrentPassword, hash); err != nil {
+				return out, err
+			}
+		}
+	}
+
+	if in.Password != nil {
+		password := strings.TrimSpace(*in.Password)
+		if password == "" {
+			return out, ErrInvalidPassword
+		}
+
+		if err = s.checkPasswordPolicy(password, user.Username, email); err != nil {
+			return out, err
+		}
+
+		hash, err := s.cfg.PasswordHasher.Hash(password)
+		if err != nil {
+			return out, fmt.Errorf("no se pudo hashear la contraseña: %v", err)
+		}
+
+		c.PasswordHash = &hash
+	}
+
+	if newEmail != "" {
+		if err = s.checkEmailChange(ctx, uid, newEmail); err != nil {
+			return out, err
+		}
+	}
+
+	c.UpdatedAt = time.Now()
+	if err = s.store.UpdateProfile(ctx, uid, c); err != nil {
+		return out, fmt.Errorf("no se pudo actualizar el perfil: %v", err)
+	}
+
+	if c.PasswordHash != nil {
+		if err = s.revokeUserTokens(ctx, uid); err != nil {
+			return out, err
+		}
+	}
+
+	if newEmail != "" {
+		if err = s.sendEmailChange(ctx, uid, email, newEmail); err != nil {
+			return out, err
+		}
+	}
+
+	return s.ownProfile(ctx, uid)
+}
+
+//checkEmailChange revisa que el email nuevo esté libre y que no se hayan
+//pedido demasiados cambios.
+func (s *Service) checkEmailChange(ctx context.Context, uid int64, email string) error {
+	_, _, err := s.store.Credentials(ctx, email)
+	if err == nil {
+		return ErrEmailTaken
+	}
+
+	if err != ErrUserNotFound {
+		return fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	n, err := s.store.CountEmailChangeTokens(ctx, uid, time.Now().Add(-emailChangeWindow))
+	if err != nil {
+		return fmt.Errorf("no se pudieron contar los cambios de email: %v", err)
+	}
+
+	if n >= maxEmailChanges {
+		return ErrTooManyEmailChanges
+	}
+
+	return nil
+}
+
+//sendEmailChange envía el token de confirmación al email nuevo y un aviso
+//al actual, por si el cambio no lo pidió el dueño de la cuenta.
+func (s *Service) sendEmailChange(ctx context.Context, uid int64, oldEmail, newEmail string) error {
+	token, err := randomToken(32)
+	if err != nil {
+		return fmt.Errorf("no se pudo generar el token de cambio de email: %v", err)
+	}
+
+	now := time.Now()
+	err = s.store.CreateEmailChangeToken(ctx, EmailChangeToken{
+		Hash:      hashToken(token),
+		UserID:    uid,
+		Email:     newEmail,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailChangeLifespan),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo guardar el token de cambio de email: %v", err)
+	}
+
+	err = s.mailer.Send(ctx, Mail{
+		To:      newEmail,
+		Subject: "Confirme su nuevo email",
+		Body: fmt.Sprintf("Hola,\n\n"+
+			"Use este token para confirmar que este es su nuevo email:\n\n%s\n\n"+
+			"Si no pidió el cambio, ignore este correo.\n", token),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo enviar el correo de cambio de email: %v", err)
+	}
+
+	//si el aviso falla el cambio sigue pendiente
+	err = s.mailer.Send(ctx, Mail{
+		To:      oldEmail,
+		Subject: "Cambio de email",
+		Body: fmt.Sprintf("Hola,\n\n"+
+			"Se pidió cambiar el email de su cuenta a %s.\n\n"+
+			"Si no fue usted, recupere su contraseña y cierre todas las sesiones.\n", newEmail),
+	})
+	if err != nil {
+		log.Printf("no se pudo avisar el cambio de email al usuario %d: %v", uid, err)
+	}
+
+	return nil
+}
+
+//ConfirmEmailChange cambia el email con el token que se envió al email
+//nuevo. No necesita autenticación porque el token prueba que el email es
+//del usuario.
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidEmailChangeToken
+	}
+
+	hash := hashToken(token)
+	t, err := s.store.EmailChangeToken(ctx, hash)
+	if err == ErrInvalidEmailChangeToken {
+		return ErrInvalidEmailChangeToken
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el token de cambio de email: %v", err)
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil || now.After(t.ExpiresAt) {
+		return ErrInvalidEmailChangeToken
+	}
+
+	ok, err := s.store.ConfirmEmailChange(ctx, hash, now)
+	if err == ErrEmailTaken {
+		return ErrEmailTaken
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo cambiar el email: %v", err)
+	}
+
+	if !ok {
+		return ErrInvalidEmailChangeToken
+	}
+
+	return nil
+}
+
+//trimmedField quita los espacios de v y devuelve err si queda más largo que
+//max caracteres.
+func trimmedField(v *string, max int, err error) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	t := strings.TrimSpace(*v)
+	if utf8.RuneCountInString(t) > max {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+func validWebsite(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.User == nil
+}
+
+func validBirthday(raw string) bool {
+	t, err := time.Parse(birthdayLayout, raw)
+	return err == nil && t.Year() >= 1900 && t.Before(time.Now())
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//ErrTokenRevoked cuando el token de acceso fue revocado.
+var ErrTokenRevoked = errors.New("el token fue revocado")
+
+//RevocationStore guarda los tokens de acceso revocados.
+type RevocationStore interface {
+	//RevokeToken revoca un token de acceso. expiresAt es cuando el token
+	//vence por sí solo y el registro ya no hace falta.
+	RevokeToken(ctx context.Context, userID int64, tokenID string, expiresAt time.Time) error
+
+	//RevokeUserTokens revoca todos los tokens del usuario emitidos hasta before.
+	RevokeUserTokens(ctx context.Context, userID int64, before time.Time) error
+
+	//TokenRevoked dice si el token tokenID del usuario, emitido en issuedAt,
+	//fue revocado por sí solo o junto con los demás tokens del usuario.
+	TokenRevoked(ctx context.Context, userID int64, tokenID string, issuedAt time.Time) (bool, error)
+}
+
+//Logout revoca el token de acceso con el que se hizo la petición y su sesión
+//y, si se envía, la familia del refresh token.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	uid := p.UserID
+	now := time.Now()
+	if err := s.store.RevokeToken(ctx, uid, p.TokenID, now.Add(s.cfg.TokenLifespan)); err != nil {
+		return fmt.Errorf("no se pudo revocar el token: %v", err)
+	}
+
+	if p.SessionID != "" {
+		if err := s.revokeSession(ctx, p.SessionID, now); err != nil {
+			return err
+		}
+	}
+
+	refreshToken = strings.TrimSpace(refreshToken)
+	if refreshToken == "" {
+		return nil
+	}
+
+	t, err := s.store.RefreshToken(ctx, hashToken(refreshToken))
+	if err == ErrInvalidRefreshToken || (err == nil && t.UserID != uid) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el refresh token: %v", err)
+	}
+
+	if err = s.store.RevokeRefreshTokenFamily(ctx, t.FamilyID, now); err != nil {
+		return fmt.Errorf("no se pudo revocar el refresh token: %v", err)
+	}
+
+	return nil
+}
+
+//LogoutAll revoca todos los tokens de acceso y refresh tokens del usuario.
+func (s *Service) LogoutAll(ctx context.Context) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	return s.revokeUserTokens(ctx, uid)
+}
+
+//revokeUserTokens cierra todas las sesiones del usuario. Se usa al cerrar
+//todas las sesiones y cada vez que cambia la contraseña.
+func (s *Service) revokeUserTokens(ctx context.Context, uid int64) error {
+	now := time.Now()
+	if err := s.store.RevokeUserTokens(ctx, uid, now); err != nil {
+		return fmt.Errorf("no se pudieron revocar los tokens del usuario: %v", err)
+	}
+
+	if err := s.store.RevokeUserRefreshTokens(ctx, uid, now); err != nil {
+		return fmt.Errorf("no se pudieron revocar los refresh tokens del usuario: %v", err)
+	}
+
+	if err := s.store.RevokeUserSessions(ctx, uid, now); err != nil {
+		return fmt.Errorf("no se pudieron revocar las sesiones del usuario: %v", err)
+	}
+
+	return nil
+}
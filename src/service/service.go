@@ -1,21 +1,185 @@
 package service
 
 import (
-	"database/sql"
+	"time"
 
-	"github.com/hako/branca"
+	"github.com/Mynor2397/social-network/src/password"
 )
 
 //Service es el core de la aplicación
 type Service struct {
-	db    *sql.DB
-	codec *branca.Branca
+	store  Store
+	codec  TokenCodec
+	mailer Mailer
+	cfg    Config
+}
+
+//Config son los parámetros del servicio. Los valores en cero se cambian por
+//los valores por defecto.
+type Config struct {
+	//TokenLifespan es el tiempo de vida de los tokens de acceso.
+	TokenLifespan time.Duration
+
+	//RefreshTokenLifespan es el tiempo de vida de los refresh tokens.
+	RefreshTokenLifespan time.Duration
+
+	//PasswordResetLifespan es el tiempo de vida de los tokens de recuperación.
+	PasswordResetLifespan time.Duration
+
+	//PasswordResetMaxRequests es cuántas recuperaciones se pueden pedir para
+	//un email en PasswordResetWindow.
+	PasswordResetMaxRequests int
+	PasswordResetWindow      time.Duration
+
+	//UnverifiedRestrictions son las acciones que no puede hacer un usuario
+	//sin el email verificado: RestrictLogin y RestrictFollow.
+	UnverifiedRestrictions []string
+
+	//TwoFactorIssuer es el nombre con el que aparece la cuenta en las
+	//aplicaciones de autenticación.
+	TwoFactorIssuer string
+
+	//LoginMaxFailures es cuántos intentos fallidos seguidos bloquean una
+	//cuenta y LoginIPMaxFailures cuántos bloquean una IP.
+	LoginMaxFailures   int
+	LoginIPMaxFailures int
+
+	//LoginLockout es el primer bloqueo; cada fallo más lo duplica hasta
+	//LoginMaxLockout.
+	LoginLockout    time.Duration
+	LoginMaxLockout time.Duration
+
+	//LoginFailureWindow es cuánto se recuerdan los intentos fallidos desde
+	//el último.
+	LoginFailureWindow time.Duration
+
+	//PasswordHasher hashea las contraseñas nuevas y verifica las guardadas.
+	PasswordHasher password.Hasher
+
+	//PasswordMinLength y PasswordMinEntropy, en bits, son lo mínimo que
+	//debe tener una contraseña nueva.
+	PasswordMinLength  int
+	PasswordMinEntropy int
+
+	//BreachedPasswords, si no es nil, rechaza las contraseñas filtradas.
+	BreachedPasswords BreachedPasswords
+
+	//IdentityProviders son los proveedores OpenID Connect con los que se
+	//puede iniciar sesión.
+	IdentityProviders []IdentityProvider
+
+	//UsernameChangeCooldown es cuánto hay que esperar entre dos cambios de
+	//username y UsernameReservation cuánto tiempo nadie más puede usar un
+	//username que se dejó.
+	UsernameChangeCooldown time.Duration
+	UsernameReservation    time.Duration
+
+	//Blobs guarda las imágenes de perfil; sin él no se pueden subir.
+	Blobs BlobStorage
+
+	//MaxImageSize es lo más que puede pesar, en bytes, una imagen subida.
+	MaxImageSize int
+
+	//DeletionGracePeriod es cuánto tarda en borrarse una cuenta
+	//después de pedirlo; mientras tanto se puede cancelar iniciando sesión.
+	DeletionGracePeriod time.Duration
+
+	//Exports guarda los ZIP de las exportaciones de datos; no debe ser
+	//público porque se descargan con un link que expira.
+	Exports BlobStorage
+
+	//DataExportLifespan es cuánto se guarda una exportación lista.
+	DataExportLifespan time.Duration
+}
+
+func (c Config) restricted(action string) bool {
+	for _, a := range c.UnverifiedRestrictions {
+		if a == action {
+			return true
+		}
+	}
+
+	return false
 }
 
 //New create a new service of connection
-func New(db *sql.DB, codec *branca.Branca) *Service {
+func New(store Store, codec TokenCodec, mailer Mailer, cfg Config) *Service {
+	if cfg.TokenLifespan <= 0 {
+		cfg.TokenLifespan = TokenLifespan
+	}
+
+	if cfg.RefreshTokenLifespan <= 0 {
+		cfg.RefreshTokenLifespan = RefreshTokenLifespan
+	}
+
+	if cfg.PasswordResetLifespan <= 0 {
+		cfg.PasswordResetLifespan = time.Hour
+	}
+
+	if cfg.PasswordResetMaxRequests <= 0 {
+		cfg.PasswordResetMaxRequests = 3
+	}
+
+	if cfg.PasswordResetWindow <= 0 {
+		cfg.PasswordResetWindow = time.Hour
+	}
+
+	if cfg.PasswordMinLength <= 0 {
+		cfg.PasswordMinLength = 8
+	}
+
+	if cfg.PasswordMinEntropy <= 0 {
+		cfg.PasswordMinEntropy = 40
+	}
+
+	if cfg.TwoFactorIssuer == "" {
+		cfg.TwoFactorIssuer = "Network"
+	}
+
+	if cfg.LoginMaxFailures <= 0 {
+		cfg.LoginMaxFailures = 5
+	}
+
+	if cfg.LoginIPMaxFailures <= 0 {
+		cfg.LoginIPMaxFailures = 20
+	}
+
+	if cfg.LoginLockout <= 0 {
+		cfg.LoginLockout = time.Minute
+	}
+
+	if cfg.LoginMaxLockout <= 0 {
+		cfg.LoginMaxLockout = time.Hour
+	}
+
+	if cfg.LoginFailureWindow <= 0 {
+		cfg.LoginFailureWindow = time.Hour
+	}
+
+	if cfg.UsernameChangeCooldown <= 0 {
+		cfg.UsernameChangeCooldown = time.Hour * 24 * 30
+	}
+
+	if cfg.UsernameReservation <= 0 {
+		cfg.UsernameReservation = time.Hour * 24 * 90
+	}
+
+	if cfg.MaxImageSize <= 0 {
+		cfg.MaxImageSize = 5 << 20
+	}
+
+	if cfg.DeletionGracePeriod <= 0 {
+		cfg.DeletionGracePeriod = time.Hour * 24 * 30
+	}
+
+	if cfg.DataExportLifespan <= 0 {
+		cfg.DataExportLifespan = time.Hour * 24 * 7
+	}
+
 	return &Service{
-		db:    db,
-		codec: codec,
+		store:  store,
+		codec:  codec,
+		mailer: mailer,
+		cfg:    cfg,
 	}
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+//sessionTouchInterval es cada cuánto se actualiza la última actividad de una
+//sesión, para no escribir en cada petición.
+const sessionTouchInterval = time.Minute
+
+//ErrSessionNotFound cuando la sesión no existe o es de otro usuario.
+var ErrSessionNotFound = errors.New("sesión no encontrada")
+
+//ClientInfo describe el dispositivo que hace la petición.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+//Session es un inicio de sesión en un dispositivo. Comparte el id con la
+//familia de refresh tokens que se emite al iniciar sesión.
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	AuthMethod string     `json:"auth_method"`
+	Current    bool       `json:"current"`
+}
+
+//SessionStore guarda las sesiones.
+type SessionStore interface {
+	//CreateSession guarda una sesión nueva.
+	CreateSession(ctx context.Context, s Session) error
+
+	//Session devuelve la sesión o ErrSessionNotFound.
+	Session(ctx context.Context, id string) (Session, error)
+
+	//Sessions devuelve las sesiones del usuario que no han sido revocadas ni
+	//han expirado en now, de la más reciente a la más antigua.
+	Sessions(ctx context.Context, userID int64, now time.Time) ([]Session, error)
+
+	//TouchSession registra actividad en la sesión desde ip.
+	TouchSession(ctx context.Context, id string, seenAt time.Time, ip string) error
+
+	//ExtendSession mueve la expiración de la sesión.
+	ExtendSession(ctx context.Context, id string, expiresAt time.Time) error
+
+	//RevokeSession revoca la sesión.
+	RevokeSession(ctx context.Context, id string, revokedAt time.Time) error
+
+	//RevokeUserSessions revoca todas las sesiones del usuario.
+	RevokeUserSessions(ctx context.Context, userID int64, revokedAt time.Time) error
+}
+
+//Sessions devuelve las sesiones activas del usuario autenticado y marca la
+//sesión de la petición actual.
+func (s *Service) Sessions(ctx context.Context) ([]Session, error) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	ss, err := s.store.Sessions(ctx, p.UserID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("no se pudieron consultar las sesiones: %v", err)
+	}
+
+	for i := range ss {
+		ss[i].Current = ss[i].ID == p.SessionID
+	}
+
+	return ss, nil
+}
+
+//RevokeSession cierra una sesión del usuario autenticado. Los tokens de
+//acceso de la sesión dejan de servir y su refresh token se revoca.
+func (s *Service) RevokeSession(ctx context.Context, id string) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	sess, err := s.store.Session(ctx, id)
+	if err == ErrSessionNotFound || (err == nil && (sess.UserID != uid || sess.RevokedAt != nil)) {
+		return ErrSessionNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar la sesión: %v", err)
+	}
+
+	return s.revokeSession(ctx, id, time.Now())
+}
+
+func (s *Service) revokeSession(ctx context.Context, id string, now time.Time) error {
+	if err := s.store.RevokeSession(ctx, id, now); err != nil {
+		return fmt.Errorf("no se pudo revocar la sesión: %v", err)
+	}
+
+	if err := s.store.RevokeRefreshTokenFamily(ctx, id, now); err != nil {
+		return fmt.Errorf("no se pudo revocar el refresh token de la sesión: %v", err)
+	}
+
+	return nil
+}
+
+//startSession crea la sesión de un login nuevo, iniciada con method, con los
+//datos del cliente que vienen en el contexto.
+func (s *Service) startSession(ctx context.Context, id string, uid int64, method string, now time.Time) error {
+	client, _ := ctx.Value(KeyClientInfo).(ClientInfo)
+	err := s.store.CreateSession(ctx, Session{
+		ID:         id,
+		UserID:     uid,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		AuthMethod: method,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.cfg.RefreshTokenLifespan),
+	})
+	if err != nil {
+		return fmt.Errorf("no se pudo crear la sesión: %v", err)
+	}
+
+	return nil
+}
+
+//checkSession verifica que la sesión del token siga activa y registra la
+//actividad.
+func (s *Service) checkSession(ctx context.Context, id string, uid int64) error {
+	sess, err := s.store.Session(ctx, id)
+	if err == ErrSessionNotFound {
+		return ErrTokenRevoked
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar la sesión: %v", err)
+	}
+
+	now := time.Now()
+	if sess.UserID != uid || sess.RevokedAt != nil || now.After(sess.ExpiresAt) {
+		return ErrTokenRevoked
+	}
+
+	if now.Sub(sess.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	client, _ := ctx.Value(KeyClientInfo).(ClientInfo)
+	if err = s.store.TouchSession(ctx, id, now, client.IP); err != nil {
+		log.Printf("no se pudo actualizar la actividad de la sesión %s: %v", id, err)
+	}
+
+	return nil
+}
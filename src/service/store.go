@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+)
+
+//Store es el almacenamiento del que depende el servicio. El paquete mysql
+//tiene la implementación de producción y el paquete memory una en memoria
+//para correr la API sin un servidor mysql.
+//
+//Las implementaciones devuelven ErrUserNotFound cuando el usuario buscado no
+//existe, para que el servicio pueda distinguirlo de un error de la base.
+type Store interface {
+	UserStore
+	FollowStore
+	CredentialStore
+	RefreshTokenStore
+	RevocationStore
+	SessionStore
+	PasswordResetStore
+	EmailVerificationStore
+	TwoFactorStore
+	LoginAttemptStore
+	PersonalAccessTokenStore
+	RoleStore
+	AdminStore
+	AuditStore
+	OAuthStore
+	IdentityStore
+	ProfileStore
+	UsernameStore
+	ImageStore
+	DeletionStore
+	DataExportStore
+}
+
+//UserStore guarda y consulta usuarios.
+type UserStore interface {
+	//CreateUser inserta un usuario con la contraseña ya hasheada y devuelve su
+	//id. Devuelve ErrInvalidUser si el email o el username ya existen.
+	CreateUser(ctx context.Context, email, username, passwordHash string) (int64, error)
+
+	//UpdatePassword cambia el hash de la contraseña del usuario.
+	UpdatePassword(ctx context.Context, userID int64, passwordHash string) error
+
+	//ReplacePasswordHash cambia el hash de la contraseña solo si sigue
+	//siendo oldHash y devuelve si lo cambió.
+	ReplacePasswordHash(ctx context.Context, userID int64, oldHash, newHash string) (bool, error)
+
+	//UserByID devuelve el id y el username del usuario.
+	UserByID(ctx context.Context, id int64) (User, error)
+
+	//UserIDByUsername devuelve el id del usuario con ese username.
+	UserIDByUsername(ctx context.Context, username string) (int64, error)
+
+	//UserProfile devuelve el perfil del usuario. Si viewerID no es cero
+	//llena Following y Followeed respecto a ese usuario.
+	UserProfile(ctx context.Context, viewerID int64, username string) (UserProfile, error)
+
+	//Users devuelve hasta first perfiles ordenados por username, filtrados por
+	//search y posteriores al username after. Following y Followeed se llenan
+	//como en UserProfile.
+	Users(ctx context.Context, viewerID int64, search string, first int, after string) ([]UserProfile, error)
+}
+
+//FollowStore guarda quién sigue a quién.
+type FollowStore interface {
+	//SetFollow aplica op en una sola transacción a si followerID sigue a
+	//followeeID, actualiza los contadores de ambos si cambió y devuelve el
+	//nuevo estado.
+	SetFollow(ctx context.Context, followerID, followeeID int64, op FollowOp) (ToggleFollowOutput, error)
+
+	//Followers y Followees devuelven hasta first perfiles de los seguidores
+	//de userID o de los usuarios que sigue, ordenados por username y
+	//posteriores al username after. Following y Followeed se llenan como en
+	//UserProfile.
+	Followers(ctx context.Context, viewerID, userID int64, first int, after string) ([]UserProfile, error)
+	Followees(ctx context.Context, viewerID, userID int64, first int, after string) ([]UserProfile, error)
+}
+
+//CredentialStore consulta las credenciales para iniciar sesión.
+type CredentialStore interface {
+	//Credentials devuelve el usuario con ese email y el hash de su contraseña.
+	Credentials(ctx context.Context, email string) (User, string, error)
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrInvalidRefreshToken cuando el refresh token no existe, expiró o fue revocado.
+	ErrInvalidRefreshToken = errors.New("refresh token inválido")
+
+	//ErrRefreshTokenReused cuando se presenta un refresh token que ya se usó.
+	//Se revoca toda la familia porque alguien más pudo haberlo robado.
+	ErrRefreshTokenReused = errors.New("refresh token reutilizado, inicie sesión de nuevo")
+)
+
+//RefreshToken es un refresh token guardado en el servidor. Solo se guarda el
+//hash del token; todos los tokens que salen de un mismo login comparten
+//FamilyID.
+type RefreshToken struct {
+	Hash      string
+	UserID    int64
+	FamilyID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+//RefreshTokenStore guarda los refresh tokens.
+type RefreshTokenStore interface {
+	//CreateRefreshToken guarda un refresh token nuevo.
+	CreateRefreshToken(ctx context.Context, t RefreshToken) error
+
+	//RefreshToken devuelve el refresh token con ese hash o
+	//ErrInvalidRefreshToken si no existe.
+	RefreshToken(ctx context.Context, hash string) (RefreshToken, error)
+
+	//UseRefreshToken marca el token como usado solo si no se había usado y
+	//devuelve si lo marcó.
+	UseRefreshToken(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+
+	//RevokeRefreshTokenFamily revoca todos los tokens de la familia.
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string, revokedAt time.Time) error
+
+	//RevokeUserRefreshTokens revoca todos los refresh tokens del usuario.
+	RevokeUserRefreshTokens(ctx context.Context, userID int64, revokedAt time.Time) error
+}
+
+//RefreshToken cambia un refresh token por un token de acceso nuevo y un
+//refresh token nuevo de la misma familia. Cada refresh token sirve una sola
+//vez; si se presenta uno ya usado se revoca toda la familia.
+func (s *Service) RefreshToken(ctx context.Context, token string) (LoginOutput, error) {
+	var out LoginOutput
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return out, ErrInvalidRefreshToken
+	}
+
+	hash := hashToken(token)
+	t, err := s.store.RefreshToken(ctx, hash)
+	if err == ErrInvalidRefreshToken {
+		return out, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el refresh token: %v", err)
+	}
+
+	now := time.Now()
+	if t.RevokedAt != nil || now.After(t.ExpiresAt) {
+		return out, ErrInvalidRefreshToken
+	}
+
+	if t.UsedAt != nil {
+		return out, s.refreshTokenReused(ctx, t, now)
+	}
+
+	ok, err := s.store.UseRefreshToken(ctx, hash, now)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo marcar el refresh token: %v", err)
+	}
+
+	if !ok {
+		return out, s.refreshTokenReused(ctx, t, now)
+	}
+
+	out.AuthUser, err = s.store.UserByID(ctx, t.UserID)
+	if err == ErrUserNotFound {
+		return out, ErrInvalidRefreshToken
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario del refresh token: %v", err)
+	}
+
+	method, err := s.refreshSession(ctx, t, now)
+	if err != nil {
+		return out, err
+	}
+
+	if err = s.issueTokens(ctx, &out, t.FamilyID, method); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+//refreshSession extiende la sesión del refresh token y devuelve cómo se
+//inició. Los refresh tokens emitidos antes de que existieran las sesiones no
+//tienen una, así que se crea.
+func (s *Service) refreshSession(ctx context.Context, t RefreshToken, now time.Time) (string, error) {
+	sess, err := s.store.Session(ctx, t.FamilyID)
+	if err == ErrSessionNotFound {
+		return AuthMethodPassword, s.startSession(ctx, t.FamilyID, t.UserID, AuthMethodPassword, now)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("no se pudo consultar la sesión: %v", err)
+	}
+
+	if err = s.store.ExtendSession(ctx, t.FamilyID, now.Add(s.cfg.RefreshTokenLifespan)); err != nil {
+		return "", fmt.Errorf("no se pudo extender la sesión: %v", err)
+	}
+
+	return sess.AuthMethod, nil
+}
+
+func (s *Service) refreshTokenReused(ctx context.Context, t RefreshToken, now time.Time) error {
+	log.Printf("refresh token reutilizado, revocando la familia %s del usuario %d", t.FamilyID, t.UserID)
+	if err := s.store.RevokeRefreshTokenFamily(ctx, t.FamilyID, now); err != nil {
+		return fmt.Errorf("no se pudo revocar la familia de refresh tokens: %v", err)
+	}
+
+	return ErrRefreshTokenReused
+}
+
+//issueTokens llena out con un token de acceso y un refresh token nuevo de la
+//familia familyID para out.AuthUser. La familia es también la sesión y
+//method es cómo se inició.
+func (s *Service) issueTokens(ctx context.Context, out *LoginOutput, familyID, method string) error {
+	now := time.Now()
+	s.fillAvatarURL(&out.AuthUser)
+
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return fmt.Errorf("No se pudo generar el token: %v", err)
+	}
+
+	out.Token, err = s.encodeClaims(newClaims(Principal{
+		UserID:     out.AuthUser.ID,
+		TokenID:    tokenID,
+		SessionID:  familyID,
+		IssuedAt:   now,
+		Scopes:     []string{ScopeAll},
+		AuthMethod: method,
+	}))
+	if err != nil {
+		return fmt.Errorf("No se pudo generar el token: %v", err)
+	}
+	out.ExpiresAt = now.Add(s.cfg.TokenLifespan)
+
+	out.RefreshToken, err = randomToken(32)
+	if err != nil {
+		return fmt.Errorf("No se pudo generar el refresh token: %v", err)
+	}
+	out.RefreshTokenExpiresAt = now.Add(s.cfg.RefreshTokenLifespan)
+
+	err = s.store.CreateRefreshToken(ctx, RefreshToken{
+		Hash:      hashToken(out.RefreshToken),
+		UserID:    out.AuthUser.ID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: out.RefreshTokenExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("No se pudo guardar el refresh token: %v", err)
+	}
+
+	return nil
+}
+
+//TokenCodec firma y verifica los tokens de acceso. El paquete keyring tiene
+//la implementación con branca y rotación de llaves.
+type TokenCodec interface {
+	//Encode firma payload en un token.
+	Encode(payload string) (string, error)
+
+	//Decode verifica el token, incluida su vigencia, y devuelve el payload.
+	Decode(token string) (string, error)
+}
+
+//encodeClaims firma los claims en un token de acceso.
+func (s *Service) encodeClaims(c claims) (string, error) {
+	payload, err := c.encode()
+	if err != nil {
+		return "", err
+	}
+
+	return s.codec.Encode(payload)
+}
+
+//randomToken devuelve n bytes aleatorios codificados en base64 para URLs.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+//hashToken es el hash con el que se guardan los tokens opacos.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,468 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mynor2397/social-network/src/totp"
+)
+
+const (
+	//twoFactorChallengeLifespan es el tiempo que hay para escribir el código
+	//después de la contraseña.
+	twoFactorChallengeLifespan = time.Minute * 5
+
+	//maxTwoFactorAttempts es cuántos códigos se pueden probar por desafío.
+	maxTwoFactorAttempts = 5
+
+	//totpSkew es cuántos periodos antes y después del actual se aceptan.
+	totpSkew = 1
+
+	//recoveryCodeCount es cuántos códigos de respaldo se generan.
+	recoveryCodeCount = 10
+)
+
+var (
+	//ErrTwoFactorNotEnabled cuando el usuario no tiene la verificación en dos pasos.
+	ErrTwoFactorNotEnabled = errors.New("la verificación en dos pasos no está activada")
+
+	//ErrTwoFactorAlreadyEnabled cuando se intenta activar dos veces.
+	ErrTwoFactorAlreadyEnabled = errors.New("la verificación en dos pasos ya está activada")
+
+	//ErrInvalidTwoFactorCode cuando el código TOTP o de respaldo no es válido.
+	ErrInvalidTwoFactorCode = errors.New("código de verificación inválido")
+
+	//ErrInvalidTwoFactorChallenge cuando el desafío no existe, expiró, ya se
+	//usó o se agotaron sus intentos.
+	ErrInvalidTwoFactorChallenge = errors.New("desafío de verificación inválido, inicie sesión de nuevo")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+//TOTP es el secreto de la verificación en dos pasos de un usuario. Solo está
+//activa cuando ConfirmedAt no es nil; LastStep es el último periodo aceptado,
+//para que un código no sirva dos veces.
+type TOTP struct {
+	UserID      int64
+	Secret      string
+	CreatedAt   time.Time
+	ConfirmedAt *time.Time
+	LastStep    int64
+}
+
+//TwoFactorChallenge es el paso intermedio de un login con verificación en dos
+//pasos: la contraseña ya se verificó y falta el código. Solo se guarda el
+//hash del token.
+type TwoFactorChallenge struct {
+	Hash      string
+	UserID    int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int
+	UsedAt    *time.Time
+}
+
+//TwoFactorStore guarda los secretos TOTP, los códigos de respaldo y los
+//desafíos de login.
+type TwoFactorStore interface {
+	//SaveTOTP guarda el secreto del usuario y reemplaza el anterior.
+	SaveTOTP(ctx context.Context, t TOTP) error
+
+	//TOTP devuelve el secreto del usuario o ErrTwoFactorNotEnabled.
+	TOTP(ctx context.Context, userID int64) (TOTP, error)
+
+	//UseTOTPStep guarda step como el último periodo aceptado solo si es
+	//posterior al anterior y devuelve si lo guardó.
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+
+	//DeleteTOTP borra el secreto y los códigos de respaldo del usuario.
+	DeleteTOTP(ctx context.Context, userID int64) error
+
+	//ReplaceRecoveryCodes cambia los códigos de respaldo del usuario por hashes.
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+
+	//UseRecoveryCode marca el código como usado solo si es del usuario y no
+	//se había usado, y devuelve si lo marcó.
+	UseRecoveryCode(ctx context.Context, userID int64, hash string, usedAt time.Time) (bool, error)
+
+	//CreateTwoFactorChallenge guarda un desafío nuevo.
+	CreateTwoFactorChallenge(ctx context.Context, c TwoFactorChallenge) error
+
+	//TwoFactorChallenge devuelve el desafío con ese hash o
+	//ErrInvalidTwoFactorChallenge.
+	TwoFactorChallenge(ctx context.Context, hash string) (TwoFactorChallenge, error)
+
+	//AttemptTwoFactorChallenge suma un intento al desafío solo si tiene menos
+	//de max y devuelve si lo sumó.
+	AttemptTwoFactorChallenge(ctx context.Context, hash string, max int) (bool, error)
+
+	//UseTwoFactorChallenge marca el desafío como usado solo si no se había
+	//usado y devuelve si lo marcó.
+	UseTwoFactorChallenge(ctx context.Context, hash string, usedAt time.Time) (bool, error)
+}
+
+//TwoFactorLogin es la respuesta del login cuando falta el código: el token
+//del desafío que se manda con el código a LoginTwoFactor.
+type TwoFactorLogin struct {
+	ChallengeToken string    `json:"challenge_token"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+//TwoFactorEnrollment es el secreto nuevo para configurar la aplicación de
+//autenticación, en texto y como URI otpauth:// para el código QR.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+//EnrollTwoFactor genera un secreto TOTP para el usuario autenticado. No se
+//activa hasta confirmarlo con ConfirmTwoFactor; pedirlo otra vez antes de
+//confirmar cambia el secreto.
+func (s *Service) EnrollTwoFactor(ctx context.Context) (TwoFactorEnrollment, error) {
+	var out TwoFactorEnrollment
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	t, err := s.store.TOTP(ctx, uid)
+	if err != nil && err != ErrTwoFactorNotEnabled {
+		return out, fmt.Errorf("no se pudo consultar la verificación en dos pasos: %v", err)
+	}
+
+	if err == nil && t.ConfirmedAt != nil {
+		return out, ErrTwoFactorAlreadyEnabled
+	}
+
+	u, err := s.store.UserByID(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return out, fmt.Errorf("no se pudo generar el secreto: %v", err)
+	}
+
+	err = s.store.SaveTOTP(ctx, TOTP{
+		UserID:    uid,
+		Secret:    secret,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return out, fmt.Errorf("no se pudo guardar el secreto: %v", err)
+	}
+
+	out.Secret = secret
+	out.URI = totp.URI(s.cfg.TwoFactorIssuer, u.Username, secret)
+	return out, nil
+}
+
+//ConfirmTwoFactor activa la verificación en dos pasos con un código del
+//secreto generado por EnrollTwoFactor y devuelve los códigos de respaldo.
+//Es la única vez que se muestran.
+func (s *Service) ConfirmTwoFactor(ctx context.Context, code string) ([]string, error) {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+
+	t, err := s.store.TOTP(ctx, uid)
+	if err == ErrTwoFactorNotEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo consultar la verificación en dos pasos: %v", err)
+	}
+
+	if t.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	now := time.Now()
+	step, ok := totp.Validate(t.Secret, code, now, totpSkew)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	t.ConfirmedAt = &now
+	t.LastStep = step
+	if err = s.store.SaveTOTP(ctx, t); err != nil {
+		return nil, fmt.Errorf("no se pudo activar la verificación en dos pasos: %v", err)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = recoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo generar un código de respaldo: %v", err)
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err = s.store.ReplaceRecoveryCodes(ctx, uid, hashes); err != nil {
+		return nil, fmt.Errorf("no se pudieron guardar los códigos de respaldo: %v", err)
+	}
+
+	return codes, nil
+}
+
+//DisableTwoFactor desactiva la verificación en dos pasos del usuario
+//autenticado. Pide un código TOTP o de respaldo.
+func (s *Service) DisableTwoFactor(ctx context.Context, code string) error {
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	t, err := s.store.TOTP(ctx, uid)
+	if err == ErrTwoFactorNotEnabled || (err == nil && t.ConfirmedAt == nil) {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar la verificación en dos pasos: %v", err)
+	}
+
+	if _, err = s.checkThrottledTwoFactorCode(ctx, t, code); err != nil {
+		return err
+	}
+
+	if err = s.store.DeleteTOTP(ctx, uid); err != nil {
+		return fmt.Errorf("no se pudo desactivar la verificación en dos pasos: %v", err)
+	}
+
+	return nil
+}
+
+//LoginTwoFactor termina un login que devolvió un desafío. code es un código
+//TOTP o uno de respaldo; cada desafío acepta pocos intentos y los fallos
+//cuentan para el bloqueo de la cuenta como los de la contraseña.
+func (s *Service) LoginTwoFactor(ctx context.Context, challengeToken, code string) (LoginOutput, error) {
+	var out LoginOutput
+
+	challengeToken = strings.TrimSpace(challengeToken)
+	if challengeToken == "" {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	hash := hashToken(challengeToken)
+	c, err := s.store.TwoFactorChallenge(ctx, hash)
+	if err == ErrInvalidTwoFactorChallenge {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el desafío: %v", err)
+	}
+
+	now := time.Now()
+	if c.UsedAt != nil || now.After(c.ExpiresAt) {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	email, _, err := s.store.EmailStatus(ctx, c.UserID)
+	if err == ErrUserNotFound {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if err = s.checkLoginThrottle(ctx, email); err != nil {
+		return out, err
+	}
+
+	ok, err := s.store.AttemptTwoFactorChallenge(ctx, hash, maxTwoFactorAttempts)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo registrar el intento: %v", err)
+	}
+
+	if !ok {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	t, err := s.store.TOTP(ctx, c.UserID)
+	if err == ErrTwoFactorNotEnabled || (err == nil && t.ConfirmedAt == nil) {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar la verificación en dos pasos: %v", err)
+	}
+
+	method, err := s.checkTwoFactorCode(ctx, t, code)
+	if err == ErrInvalidTwoFactorCode {
+		return out, s.twoFactorFailed(ctx, email)
+	}
+
+	if err != nil {
+		return out, err
+	}
+
+	ok, err = s.store.UseTwoFactorChallenge(ctx, hash, now)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo marcar el desafío: %v", err)
+	}
+
+	if !ok {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	out.AuthUser, err = s.store.UserByID(ctx, c.UserID)
+	if err == ErrUserNotFound {
+		return out, ErrInvalidTwoFactorChallenge
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	if err = s.loginSucceeded(ctx, email); err != nil {
+		return out, err
+	}
+
+	if err = s.checkSuspended(ctx, c.UserID); err != nil {
+		return out, err
+	}
+
+	if err = s.startLogin(ctx, &out, method); err != nil {
+		return out, err
+	}
+
+	return out, nil
+}
+
+//twoFactorRequired devuelve si el usuario tiene activa la verificación en
+//dos pasos.
+func (s *Service) twoFactorRequired(ctx context.Context, uid int64) (bool, error) {
+	t, err := s.store.TOTP(ctx, uid)
+	if err == ErrTwoFactorNotEnabled {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("no se pudo consultar la verificación en dos pasos: %v", err)
+	}
+
+	return t.ConfirmedAt != nil, nil
+}
+
+//startTwoFactorChallenge crea el desafío que se devuelve en lugar de los
+//tokens cuando el usuario tiene la verificación en dos pasos.
+func (s *Service) startTwoFactorChallenge(ctx context.Context, uid int64) (*TwoFactorLogin, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo generar el desafío: %v", err)
+	}
+
+	now := time.Now()
+	c := TwoFactorChallenge{
+		Hash:      hashToken(token),
+		UserID:    uid,
+		CreatedAt: now,
+		ExpiresAt: now.Add(twoFactorChallengeLifespan),
+	}
+	if err = s.store.CreateTwoFactorChallenge(ctx, c); err != nil {
+		return nil, fmt.Errorf("no se pudo guardar el desafío: %v", err)
+	}
+
+	return &TwoFactorLogin{ChallengeToken: token, ExpiresAt: c.ExpiresAt}, nil
+}
+
+//checkThrottledTwoFactorCode es checkTwoFactorCode para un usuario ya
+//autenticado: los códigos fallidos cuentan para el bloqueo de su cuenta, así
+//un token robado no sirve para probar códigos sin límite.
+func (s *Service) checkThrottledTwoFactorCode(ctx context.Context, t TOTP, code string) (string, error) {
+	email, _, err := s.store.EmailStatus(ctx, t.UserID)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo consultar el email: %v", err)
+	}
+
+	if err = s.checkLoginThrottle(ctx, email); err != nil {
+		return "", err
+	}
+
+	method, err := s.checkTwoFactorCode(ctx, t, code)
+	if err == ErrInvalidTwoFactorCode {
+		return "", s.twoFactorFailed(ctx, email)
+	}
+
+	return method, err
+}
+
+//twoFactorFailed registra el código fallido como un intento de login fallido
+//de la cuenta y devuelve ErrInvalidTwoFactorCode.
+func (s *Service) twoFactorFailed(ctx context.Context, email string) error {
+	if err := s.recordLoginFailure(ctx, email); err != nil {
+		return err
+	}
+
+	return ErrInvalidTwoFactorCode
+}
+
+//checkTwoFactorCode acepta un código TOTP que no se haya usado antes o un
+//código de respaldo sin usar, y devuelve cuál fue como método de autenticación.
+func (s *Service) checkTwoFactorCode(ctx context.Context, t TOTP, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", ErrInvalidTwoFactorCode
+	}
+
+	now := time.Now()
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(t.Secret, code, now, totpSkew)
+		if !ok || step <= t.LastStep {
+			return "", ErrInvalidTwoFactorCode
+		}
+
+		ok, err := s.store.UseTOTPStep(ctx, t.UserID, step)
+		if err != nil {
+			return "", fmt.Errorf("no se pudo registrar el código: %v", err)
+		}
+
+		if !ok {
+			return "", ErrInvalidTwoFactorCode
+		}
+
+		return AuthMethodTOTP, nil
+	}
+
+	ok, err := s.store.UseRecoveryCode(ctx, t.UserID, hashToken(normalizeRecoveryCode(code)), now)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo registrar el código de respaldo: %v", err)
+	}
+
+	if !ok {
+		return "", ErrInvalidTwoFactorCode
+	}
+
+	return AuthMethodRecoveryCode, nil
+}
+
+//recoveryCode devuelve un código de respaldo como "abcd-efgh".
+func recoveryCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	c := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	return c[:4] + "-" + c[4:], nil
+}
+
+//normalizeRecoveryCode quita guiones y espacios y pasa a minúsculas, para
+//aceptar el código como sea que se escriba.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -2,14 +2,11 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"log"
 	"regexp"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -54,17 +51,32 @@ type User struct {
 	ID       int64  `json:"id,omitempty"`
 	Username string `json:"username,omitempty"`
 	Password string `json:"password,omitempty"`
+
+	//Avatar es el prefijo de las llaves de los archivos del avatar.
+	Avatar           string            `json:"-"`
+	AvatarURL        string            `json:"avatar_url,omitempty"`
+	AvatarThumbnails map[string]string `json:"avatar_thumbnails,omitempty"`
 }
 
 //UserProfile model.
 type UserProfile struct {
 	User
 	Email          string `json:"email,omitempty"`
+	DisplayName    string `json:"display_name,omitempty"`
+	Bio            string `json:"bio,omitempty"`
+	Location       string `json:"location,omitempty"`
+	Website        string `json:"website,omitempty"`
+	Birthday       string `json:"birthday,omitempty"`
 	FollowersCount int    `json:"followers_count"`
 	FolloweesCount int    `json:"followees_count"`
 	Me             bool   `json:"me"`
 	Following      bool   `json:"following"`
 	Followeed      bool   `json:"followed"`
+
+	//Cover es el prefijo de las llaves de los archivos de la portada.
+	Cover           string            `json:"-"`
+	CoverURL        string            `json:"cover_url,omitempty"`
+	CoverThumbnails map[string]string `json:"cover_thumbnails,omitempty"`
 }
 
 //ToggleFollowOutput es la estructura para los seguidores
@@ -92,75 +104,103 @@ func (s *Service) CreateUser(ctx context.Context, email, username, password stri
 		return ErrInvalidPassword
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err := s.checkPasswordPolicy(password, username, email); err != nil {
+		return err
+	}
+
+	reserved, err := s.usernameReserved(ctx, username)
 	if err != nil {
-		log.Println("Password do not hashed")
+		return err
 	}
 
-	query := "INSERT INTO user (email, username, password) VALUES (?, ?, ?)"
-	_, err = s.db.ExecContext(ctx, query, email, username, string(hashedPassword))
+	if reserved {
+		return ErrUsernameReserved
+	}
 
+	hashedPassword, err := s.cfg.PasswordHasher.Hash(password)
 	if err != nil {
+		return fmt.Errorf("no se pudo hashear la contraseña: %v", err)
+	}
+
+	uid, err := s.store.CreateUser(ctx, email, username, hashedPassword)
+	if err == ErrInvalidUser {
 		return ErrInvalidUser
 	}
 
+	if err != nil {
+		return fmt.Errorf("no se pudo crear el usuario: %v", err)
+	}
+
+	//si el correo falla el usuario puede pedir otro
+	if err = s.sendEmailVerification(ctx, uid, email); err != nil {
+		log.Printf("no se pudo enviar la verificación al usuario %d: %v", uid, err)
+	}
+
 	return ErrUserOk
 }
 
 //User selecciona el usuario de la base de datos
 func (s *Service) User(ctx context.Context, username string) (UserProfile, error) {
-	var u UserProfile
-
 	username = strings.TrimSpace(username)
 	if !rxUsername.MatchString(username) {
-		return u, ErrInvalideUsername
-	}
-
-	uid, auth := ctx.Value(KeyAuthUser).(int64)
-	args := []interface{}{uid}
-	dest := []interface{}{&u.ID, &u.Email, &u.FolloweesCount, &u.FollowersCount}
-	query := "SELECT  id, email, followers_count, followees_count "
-	if auth {
-		query += ", " +
-			"followers.follower_id IS NOT NULL AS following, " +
-			"followees.followee_id IS NOT NULL AS followeed "
-		dest = append(dest, &u.Following, &u.Followeed)
+		return UserProfile{}, ErrInvalideUsername
 	}
 
-	query += "FROM user "
-	if auth {
-		query += "LEFT JOIN follows AS followers ON followers.follower_id = ? AND followers.followee_id = user.id " +
-			"LEFT JOIN follows AS followees ON followees.follower_id = user.id AND followees.followee_id = ? "
-
-		args = append(args, username, username)
-	}
-
-	query += "WHERE username = ? "
-	err := s.db.QueryRowContext(ctx, query, args...).Scan(dest...)
-	if err == sql.ErrNoRows {
-		return u, ErrUserNotFound
+	uid, auth := authUserID(ctx)
+	u, err := s.store.UserProfile(ctx, uid, username)
+	if err == ErrUserNotFound {
+		return u, s.movedUsername(ctx, username)
 	}
 
 	if err != nil {
 		return u, fmt.Errorf("El query de perfil de usuario a fallado: %v", err)
 	}
 
-	u.Username = username
 	u.Me = auth && uid == u.ID
 
 	if !u.Me {
 		u.ID = 0
 		u.Email = ""
+		u.Birthday = ""
 	}
 
+	s.fillImageURLs(&u)
 	return u, nil
 }
 
+//FollowOp es el cambio que hace SetFollow.
+type FollowOp int
+
+const (
+	//FollowToggle sigue al usuario si no lo seguía y si no deja de seguirlo.
+	FollowToggle FollowOp = iota
+
+	//FollowAdd y FollowRemove siguen o dejan de seguir al usuario; no
+	//cambian nada si ya estaba así.
+	FollowAdd
+	FollowRemove
+)
+
 //ToggleFollow para seguirse entre dos usuarios
 func (s *Service) ToggleFollow(ctx context.Context, username string) (ToggleFollowOutput, error) {
+	return s.setFollow(ctx, username, FollowToggle)
+}
+
+//Follow sigue al usuario. Repetirlo no cambia nada, así que se puede
+//reintentar.
+func (s *Service) Follow(ctx context.Context, username string) (ToggleFollowOutput, error) {
+	return s.setFollow(ctx, username, FollowAdd)
+}
+
+//Unfollow deja de seguir al usuario; como Follow, se puede reintentar.
+func (s *Service) Unfollow(ctx context.Context, username string) (ToggleFollowOutput, error) {
+	return s.setFollow(ctx, username, FollowRemove)
+}
+
+func (s *Service) setFollow(ctx context.Context, username string, op FollowOp) (ToggleFollowOutput, error) {
 	var out ToggleFollowOutput
 
-	followerID, ok := ctx.Value(KeyAuthUser).(int64)
+	followerID, ok := authUserID(ctx)
 
 	if !ok {
 		return out, ErrUnauthenticated
@@ -171,21 +211,9 @@ func (s *Service) ToggleFollow(ctx context.Context, username string) (ToggleFoll
 		return out, ErrInvalideUsername
 	}
 
-	//inicio de una transacción
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return out, fmt.Errorf("no se pudo iniciar la transaccion: %v", err)
-	}
-
-	defer tx.Rollback()
-	//fin de la transacción
-
-	var followeeID int64
-
-	query := "SELECT id FROM user WHERE username=?"
-	err = tx.QueryRowContext(ctx, query, username).Scan(&followeeID)
-	if err == sql.ErrNoRows {
-		return out, ErrUserNotFound
+	followeeID, err := s.store.UserIDByUsername(ctx, username)
+	if err == ErrUserNotFound {
+		return out, s.movedUsername(ctx, username)
 	}
 
 	if err != nil {
@@ -196,128 +224,97 @@ func (s *Service) ToggleFollow(ctx context.Context, username string) (ToggleFoll
 		return out, ErrForbiddenFollow
 	}
 
-	query = "SELECT EXISTS(SELECT 1 FROM follows WHERE follower_id=? AND followee_id=?)"
-	if err = tx.QueryRowContext(ctx, query, followerID, followeeID).Scan(&out.Following); err != nil {
-		return out, fmt.Errorf("No se pudo realizar la consulta de seguidor: %v", err)
+	if err = s.requireVerified(ctx, followerID, RestrictFollow); err != nil {
+		return out, err
 	}
 
-	//Para cuando un usario esté siguiendo y quiera dejar de seguir
+	out, err = s.store.SetFollow(ctx, followerID, followeeID, op)
+	if err != nil {
+		return out, err
+	}
 
 	if out.Following {
-		query = "DELETE FROM follows WHERE follower_id=? AND followee_id=?"
+		//TODO: notificacion de seguidores
+	}
+	return out, nil
+}
 
-		if _, err := tx.ExecContext(ctx, query, followerID, followeeID); err != nil {
-			return out, fmt.Errorf("No se pudo borrar los seguidores: %v", err)
-		}
+//Users busca usuarios por username, paginados por el último username visto.
+func (s *Service) Users(ctx context.Context, search string, first int, after string) ([]UserProfile, error) {
 
-		query = "UPDATE user SET followees_count = followees_count - 1 WHERE id=?"
-		if _, err = tx.ExecContext(ctx, query, followerID); err != nil {
-			return out, fmt.Errorf("no se pudo actualizar el contador de seguidos: %v", err)
-		}
+	search = strings.TrimSpace(search)
+	after = strings.TrimSpace(after)
+	first = normalizePageSize(first)
 
-		query = "call subfollowers(?)"
-		if err = tx.QueryRowContext(ctx, query, followeeID).Scan(&out.FollowersCount); err != nil {
-			return out, fmt.Errorf("No se pudo actualizar el contador de seguidores: %v", err)
-		}
+	uid, auth := authUserID(ctx)
 
-	} else { //cuando un usario quiera seguir a otro usuario
-		//inserta el usuario seguido
-		query = "INSERT INTO follows(follower_id, followee_id) VALUES (?, ?)"
-		if _, err = tx.ExecContext(ctx, query, followerID, followeeID); err != nil {
-			return out, fmt.Errorf("No se pudo insertar usuarios seguidos: %v", err)
-		}
+	all, err := s.store.Users(ctx, uid, search, first, after)
+	if err != nil {
+		return nil, fmt.Errorf("No se pudo completar el query seleccionar usuarios: %v", err)
+	}
 
-		//actualiza el contador de seguidores
-		query = "UPDATE user SET followees_count = followees_count + 1 WHERE id=?"
-		if _, err = tx.ExecContext(ctx, query, followerID); err != nil {
-			return out, fmt.Errorf("No se pudo actualizar el contador de seguidos: %v", err)
-		}
+	uu := make([]UserProfile, 0, len(all))
+	for _, u := range all {
+		u.Me = auth && uid == u.ID
+		s.fillImageURLs(&u)
 
-		query = "call addfollowers(?)"
-		if err = tx.QueryRowContext(ctx, query, followeeID).Scan(&out.FollowersCount); err != nil {
-			return out, fmt.Errorf("No se pudo actualizar el contador de seguidoores: %v", err)
-		}
-	}
+		if !u.Me {
+			u.ID = 0
+			u.Email = ""
+			u.Birthday = ""
 
-	if err = tx.Commit(); err != nil {
-		return out, fmt.Errorf("No se realizo un commit al toogle de seguir: %v", err)
+			uu = append(uu, u)
+		}
 	}
 
-	out.Following = !out.Following
+	return uu, nil
+}
 
-	if out.Following {
-		//TODO: notificacion de seguidores
-	}
-	return out, nil
+//Followers devuelve los seguidores del usuario, paginados por el último
+//username visto como Users.
+func (s *Service) Followers(ctx context.Context, username string, first int, after string) ([]UserProfile, error) {
+	return s.follows(ctx, username, first, after, s.store.Followers)
 }
 
-func (s *Service) Users(ctx context.Context, search string, first int, after string) ([]UserProfile, error) {
+//Followees devuelve los usuarios que sigue el usuario, paginados como
+//Followers.
+func (s *Service) Followees(ctx context.Context, username string, first int, after string) ([]UserProfile, error) {
+	return s.follows(ctx, username, first, after, s.store.Followees)
+}
 
-	search = strings.TrimSpace(search)
-	after = strings.TrimSpace(after)
-	first = normalizePageSize(first)
+func (s *Service) follows(ctx context.Context, username string, first int, after string,
+	list func(context.Context, int64, int64, int, string) ([]UserProfile, error)) ([]UserProfile, error) {
+	username = strings.TrimSpace(username)
+	if !rxUsername.MatchString(username) {
+		return nil, ErrInvalideUsername
+	}
 
-	uid, auth := ctx.Value(KeyAuthUser).(int64)
-
-	query, args, err := buildQuery(`
-		SELECT id, email, username, followers_count, followees_count 
-		{{if .auth}}
-		,followers.follower_id IS NOT NULL AS following  
-		,followees.followee_id IS NOT NULL AS followeed
-		{{end}}
-		FROM user 
-		{{if .auth}}
-		LEFT JOIN follows AS followers.follower_id = @uid AND followers.followee_id = user.id
-		LEFT JOIN follows AS followees.follower_id = user.id AND followees.followee_id = @uid
-		{{end}}
-		{{if or .search .after}}WHERE{{end}} 
-		{{if .search}} username LIKE '%' || @search || '%'{{end}}
-		{{if and .search .after}}AND{{end}}
-		{{if .after}}username > @after {{end}}
-		ORDER BY username ASC
-		LIMIT @first`, map[string]interface{}{
-		"auth":   auth,
-		"uid":    uid,
-		"search": search,
-		"first":  first,
-		"after":  after,
-	})
+	userID, err := s.store.UserIDByUsername(ctx, username)
+	if err == ErrUserNotFound {
+		return nil, s.movedUsername(ctx, username)
+	}
 
 	if err != nil {
-		return nil, fmt.Errorf("No se puede construir el query: %v", err)
+		return nil, fmt.Errorf("no se pudo consultar el usuario: %v", err)
 	}
 
-	log.Printf("users query: %s \nargs: %v\n", query, args)
-	rows, err := s.db.QueryContext(ctx, query, args...)
+	uid, auth := authUserID(ctx)
+	uu, err := list(ctx, uid, userID, normalizePageSize(first), strings.TrimSpace(after))
 	if err != nil {
-		return nil, fmt.Errorf("No se pudo completar el query seleccionar usuarios: %v", err)
+		return nil, fmt.Errorf("no se pudieron consultar los follows: %v", err)
 	}
 
-	defer rows.Close()
-	uu := make([]UserProfile, 0, first)
-	for rows.Next() {
-		var u UserProfile
-		dest := []interface{}{&u.ID, &u.Email, &u.Username, &u.FollowersCount, &u.FolloweesCount}
-		if auth {
-			dest = append(dest, &u.Following, &u.Followeed)
-		}
-
-		if err = rows.Scan(dest...); err != nil {
-			return nil, fmt.Errorf("No se pudo escanear el query usuarios: %v", err)
-
-		}
+	for i := range uu {
+		u := &uu[i]
 		u.Me = auth && uid == u.ID
+		s.fillImageURLs(u)
 
 		if !u.Me {
 			u.ID = 0
 			u.Email = ""
-
-			uu = append(uu, u)
+			u.Birthday = ""
 		}
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("No se pueden iterar las filas: %v", err)
-	}
 	return uu, nil
 }
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	//ErrUsernameTaken cuando otro usuario tiene el username.
+	ErrUsernameTaken = errors.New("el username ya está en uso")
+
+	//ErrUsernameReserved cuando otro usuario dejó el username hace poco y
+	//todavía lo tiene reservado.
+	ErrUsernameReserved = errors.New("el username está reservado, intente más tarde")
+
+	//ErrUsernameChangeCooldown cuando el usuario cambió su username hace
+	//poco.
+	ErrUsernameChangeCooldown = errors.New("ya cambió su username hace poco, intente más tarde")
+)
+
+//UsernameCooldownError es ErrUsernameChangeCooldown con el tiempo que falta
+//para poder cambiar el username de nuevo.
+type UsernameCooldownError struct {
+	RetryAfter time.Duration
+}
+
+func (e *UsernameCooldownError) Error() string {
+	return ErrUsernameChangeCooldown.Error()
+}
+
+//Unwrap devuelve ErrUsernameChangeCooldown.
+func (e *UsernameCooldownError) Unwrap() error {
+	return ErrUsernameChangeCooldown
+}
+
+//UsernameMovedError cuando se busca a un usuario por un username que ya
+//cambió; Username es el actual.
+type UsernameMovedError struct {
+	Username string
+}
+
+func (e *UsernameMovedError) Error() string {
+	return "el usuario ahora es " + e.Username
+}
+
+//UsernameStore guarda el historial de usernames de cada usuario.
+type UsernameStore interface {
+	//ChangeUsername cambia el username del usuario y guarda el anterior en
+	//el historial en una sola transacción, con el usuario bloqueado para que
+	//dos cambios a la vez no se salten la espera. Devuelve un
+	//*UsernameCooldownError si el usuario ya lo cambió después de
+	//cooldownSince, ErrUsernameTaken si otro usuario tiene el username y
+	//ErrUsernameReserved si otro usuario lo dejó después de reservedSince.
+	ChangeUsername(ctx context.Context, userID int64, username string, changedAt, cooldownSince, reservedSince time.Time) error
+
+	//UsernameOwner devuelve el id del último usuario que dejó el username o
+	//ErrUserNotFound si nadie lo ha dejado.
+	UsernameOwner(ctx context.Context, username string) (int64, error)
+
+	//UsernameReserved devuelve si un usuario distinto de exceptID dejó el
+	//username después de since.
+	UsernameReserved(ctx context.Context, username string, exceptID int64, since time.Time) (bool, error)
+
+	//PurgeUsernameHistory borra hasta limit usernames del historial de
+	//usuarios que ya no existen, dejados antes de before, y devuelve cuántos
+	//borró.
+	PurgeUsernameHistory(ctx context.Context, before time.Time, limit int) (int, error)
+}
+
+//ChangeUsername cambia el username del usuario autenticado y devuelve su
+//perfil. El username anterior queda en el historial: sigue llevando al
+//usuario y nadie más lo puede usar durante la reserva.
+func (s *Service) ChangeUsername(ctx context.Context, username string) (UserProfile, error) {
+	var out UserProfile
+
+	uid, ok := authUserID(ctx)
+	if !ok {
+		return out, ErrUnauthenticated
+	}
+
+	username = strings.TrimSpace(username)
+	if !rxUsername.MatchString(username) {
+		return out, ErrInvalideUsername
+	}
+
+	u, err := s.store.UserByID(ctx, uid)
+	if err != nil {
+		return out, fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	if username != u.Username {
+		now := time.Now()
+		err = s.store.ChangeUsername(ctx, uid, username, now, now.Add(-s.cfg.UsernameChangeCooldown), now.Add(-s.cfg.UsernameReservation))
+		if _, ok := err.(*UsernameCooldownError); ok || err == ErrUsernameTaken || err == ErrUsernameReserved {
+			return out, err
+		}
+
+		if err != nil {
+			return out, fmt.Errorf("no se pudo cambiar el username: %v", err)
+		}
+	}
+
+	return s.ownProfile(ctx, uid)
+}
+
+//movedUsername devuelve un *UsernameMovedError con el username actual del
+//último usuario que dejó el username, o ErrUserNotFound.
+func (s *Service) movedUsername(ctx context.Context, username string) error {
+	uid, err := s.store.UsernameOwner(ctx, username)
+	if err == ErrUserNotFound {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el historial de usernames: %v", err)
+	}
+
+	u, err := s.store.UserByID(ctx, uid)
+	if err == ErrUserNotFound {
+		return ErrUserNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("no se pudo consultar el usuario: %v", err)
+	}
+
+	return &UsernameMovedError{Username: u.Username}
+}
+
+//PurgeUsernameReservations borra del historial los usernames de cuentas
+//borradas cuya reserva terminó y devuelve cuántos borró. Hay que llamarla
+//periódicamente hasta que no quede ninguno.
+func (s *Service) PurgeUsernameReservations(ctx context.Context) (int, error) {
+	n, err := s.store.PurgeUsernameHistory(ctx, time.Now().Add(-s.cfg.UsernameReservation), purgeBatchSize)
+	if err != nil {
+		return n, fmt.Errorf("no se pudo borrar el historial de usernames: %v", err)
+	}
+
+	return n, nil
+}
+
+//usernameReserved devuelve si otro usuario dejó el username durante la
+//reserva.
+func (s *Service) usernameReserved(ctx context.Context, username string) (bool, error) {
+	reserved, err := s.store.UsernameReserved(ctx, username, 0, time.Now().Add(-s.cfg.UsernameReservation))
+	if err != nil {
+		return false, fmt.Errorf("no se pudo consultar la reserva del username: %v", err)
+	}
+
+	return reserved, nil
+}
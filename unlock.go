@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Mynor2397/social-network/src/config"
+	"github.com/Mynor2397/social-network/src/mysql"
+	"github.com/Mynor2397/social-network/src/service"
+)
+
+const unlockUsage = `uso: %s [banderas] unlock [-email email] [-ip ip]
+
+Borra los intentos de login fallidos de una cuenta o de una IP para
+desbloquearlas antes de que venza el bloqueo.
+`
+
+//runUnlock ejecuta el subcomando unlock y devuelve el código de salida.
+func runUnlock(cfg config.Config, args []string) int {
+	fs := flag.NewFlagSet("unlock", flag.ContinueOnError)
+	email := fs.String("email", "", "email de la cuenta a desbloquear")
+	ip := fs.String("ip", "", "IP a desbloquear")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), unlockUsage, os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if fs.NArg() > 0 || (*email == "" && *ip == "") {
+		fs.Usage()
+		return 2
+	}
+
+	if cfg.Storage != "mysql" {
+		fmt.Fprintln(os.Stderr, "unlock solo sirve con storage mysql")
+		return 2
+	}
+
+	db, err := mysql.Connect(cfg.Database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	s := service.New(mysql.NewStore(db), nil, nil, service.Config{})
+	if err = s.UnlockLogin(context.Background(), *email, *ip); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}